@@ -0,0 +1,222 @@
+/*
+ * ELF tree - Tree viewer for ELF library dependency
+ *
+ * Copyright (C) 2017-2018  Namhyung Kim <namhyung@gmail.com>
+ *
+ * Released under MIT license.
+ */
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"strconv"
+	"strings"
+)
+
+const (
+	AR_MAGIC      = "!<arch>\n"
+	AR_THIN_MAGIC = "!<thin>\n"
+	AR_HDR_SIZE   = 60
+)
+
+type ArMember struct {
+	name   string
+	offset int64 // offset of the header in the archive
+	size   int64
+	data   []byte // nil for thin archive members
+}
+
+type ArArchive struct {
+	thin    bool
+	symname string // name of the symbol index member
+	symtab  []byte // symbol index ("/" or "__.SYMDEF")
+	members []ArMember
+}
+
+func isArchive(data []byte) bool {
+	return bytes.HasPrefix(data, []byte(AR_MAGIC)) ||
+		bytes.HasPrefix(data, []byte(AR_THIN_MAGIC))
+}
+
+// parse System V (GNU) or BSD ar archive including GNU thin archive
+func parseArchive(data []byte) (*ArArchive, error) {
+	ar := &ArArchive{}
+
+	switch {
+	case bytes.HasPrefix(data, []byte(AR_MAGIC)):
+	case bytes.HasPrefix(data, []byte(AR_THIN_MAGIC)):
+		ar.thin = true
+	default:
+		return nil, errors.New("not an ar archive")
+	}
+
+	var longnames []byte
+
+	off := int64(len(AR_MAGIC))
+	for off+AR_HDR_SIZE <= int64(len(data)) {
+		hdr := data[off : off+AR_HDR_SIZE]
+		if string(hdr[58:60]) != "`\n" {
+			return nil, errors.New("bad ar member header")
+		}
+
+		name := strings.TrimRight(string(hdr[0:16]), " ")
+		size, err := strconv.ParseInt(strings.TrimSpace(string(hdr[48:58])), 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		if size < 0 {
+			return nil, errors.New("bad ar member size")
+		}
+
+		start := off + AR_HDR_SIZE
+		m := ArMember{offset: off, size: size}
+
+		// BSD style long name follows the header
+		if strings.HasPrefix(name, "#1/") {
+			n, err := strconv.Atoi(name[3:])
+			if err != nil || n < 0 || int64(n) > size || start+int64(n) > int64(len(data)) {
+				return nil, errors.New("bad ar long name")
+			}
+			name = strings.TrimRight(string(data[start:start+int64(n)]), "\x00")
+			start += int64(n)
+			size -= int64(n)
+		}
+
+		// thin archive keeps member data outside except for special ones
+		special := name == "/" || name == "//" || name == "/SYM64/"
+		end := start + size
+		if ar.thin && !special {
+			end = start
+		}
+		if end > int64(len(data)) {
+			return nil, errors.New("truncated ar archive")
+		}
+		body := data[start:end]
+
+		switch {
+		case name == "/" || name == "/SYM64/" || strings.HasPrefix(name, "__.SYMDEF"):
+			ar.symname = name
+			ar.symtab = body
+		case name == "//":
+			longnames = body
+		default:
+			// GNU style long name: /<offset> into the name table
+			if len(name) > 1 && name[0] == '/' {
+				idx, err := strconv.Atoi(name[1:])
+				if err == nil && idx < len(longnames) {
+					name = string(longnames[idx:])
+					if i := strings.Index(name, "\n"); i >= 0 {
+						name = name[:i]
+					}
+				}
+			}
+			m.name = strings.TrimSuffix(name, "/")
+			m.size = size
+			if !ar.thin {
+				m.data = body
+			}
+			ar.members = append(ar.members, m)
+		}
+
+		off = end
+		if off%2 == 1 {
+			off++
+		}
+	}
+	return ar, nil
+}
+
+func (ar *ArArchive) member(name string) *ArMember {
+	for i := range ar.members {
+		if ar.members[i].name == name {
+			return &ar.members[i]
+		}
+	}
+	return nil
+}
+
+// returns symbol names in the index with header offset of the defining member
+func (ar *ArArchive) symbols() ([]string, []int64) {
+	var names []string
+	var offsets []int64
+
+	data := ar.symtab
+
+	switch {
+	case ar.symname == "/" || ar.symname == "/SYM64/":
+		// big-endian count, member offsets and string table
+		size := 4
+		if ar.symname == "/SYM64/" {
+			size = 8
+		}
+		if len(data) < size {
+			return nil, nil
+		}
+
+		var count int
+		if size == 4 {
+			count = int(binary.BigEndian.Uint32(data))
+		} else {
+			count = int(binary.BigEndian.Uint64(data))
+		}
+		if count < 0 || size+count*size > len(data) {
+			return nil, nil
+		}
+
+		strtab := data[size+count*size:]
+		for i := 0; i < count; i++ {
+			p := data[size+i*size:]
+			if size == 4 {
+				offsets = append(offsets, int64(binary.BigEndian.Uint32(p)))
+			} else {
+				offsets = append(offsets, int64(binary.BigEndian.Uint64(p)))
+			}
+
+			end := bytes.IndexByte(strtab, 0)
+			if end < 0 {
+				end = len(strtab)
+			}
+			names = append(names, string(strtab[:end]))
+			if end < len(strtab) {
+				end++
+			}
+			strtab = strtab[end:]
+		}
+
+	case strings.HasPrefix(ar.symname, "__.SYMDEF"):
+		// BSD ranlib: array of (string index, member offset) pairs
+		if len(data) < 4 {
+			return nil, nil
+		}
+		size := int(binary.LittleEndian.Uint32(data))
+		if size < 0 || 8+size > len(data) {
+			return nil, nil
+		}
+
+		ranlib := data[4 : 4+size]
+		strsize := int(binary.LittleEndian.Uint32(data[4+size:]))
+		strtab := data[8+size:]
+		if strsize < len(strtab) {
+			strtab = strtab[:strsize]
+		}
+
+		for len(ranlib) >= 8 {
+			strx := int(binary.LittleEndian.Uint32(ranlib[0:4]))
+			off := int64(binary.LittleEndian.Uint32(ranlib[4:8]))
+			ranlib = ranlib[8:]
+
+			if strx >= len(strtab) {
+				continue
+			}
+			name := strtab[strx:]
+			if end := bytes.IndexByte(name, 0); end >= 0 {
+				name = name[:end]
+			}
+			names = append(names, string(name))
+			offsets = append(offsets, off)
+		}
+	}
+	return names, offsets
+}
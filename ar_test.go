@@ -0,0 +1,135 @@
+/*
+ * ELF tree - Tree viewer for ELF library dependency
+ *
+ * Copyright (C) 2017-2018  Namhyung Kim <namhyung@gmail.com>
+ *
+ * Released under MIT license.
+ */
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"testing"
+)
+
+// append an ar member header and its data (with padding)
+func arMember(buf *bytes.Buffer, name string, data []byte) {
+	fmt.Fprintf(buf, "%-16s%-12s%-6s%-6s%-8s%-10d`\n", name, "0", "0", "0", "644", len(data))
+	buf.Write(data)
+	if len(data)%2 == 1 {
+		buf.WriteByte('\n')
+	}
+}
+
+// GNU ar archive with a symbol index and a long name
+func makeGnuArchive() []byte {
+	var buf bytes.Buffer
+	buf.WriteString(AR_MAGIC)
+
+	// two symbols: foo in a.o and bar in long-name-object.o
+	var symtab bytes.Buffer
+	binary.Write(&symtab, binary.BigEndian, uint32(2))
+	binary.Write(&symtab, binary.BigEndian, uint32(0)) // fixed below
+	binary.Write(&symtab, binary.BigEndian, uint32(0))
+	symtab.WriteString("foo\x00bar\x00")
+
+	longnames := "long-name-object.o/\n"
+
+	// member offsets
+	off1 := len(AR_MAGIC) + AR_HDR_SIZE + symtab.Len() + AR_HDR_SIZE + len(longnames)
+	off2 := off1 + AR_HDR_SIZE + 4
+	s := symtab.Bytes()
+	binary.BigEndian.PutUint32(s[4:], uint32(off1))
+	binary.BigEndian.PutUint32(s[8:], uint32(off2))
+
+	arMember(&buf, "/", s)
+	arMember(&buf, "//", []byte(longnames))
+	arMember(&buf, "a.o/", []byte("AAAA"))
+	arMember(&buf, "/0", []byte("BBBBB"))
+	return buf.Bytes()
+}
+
+func TestParseGnuArchive(t *testing.T) {
+	ar, err := parseArchive(makeGnuArchive())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(ar.members) != 2 {
+		t.Fatalf("got %d members, want 2", len(ar.members))
+	}
+	if m := ar.member("a.o"); m == nil || string(m.data) != "AAAA" {
+		t.Errorf("bad member a.o: %v", m)
+	}
+	if m := ar.member("long-name-object.o"); m == nil || string(m.data) != "BBBBB" {
+		t.Errorf("bad member long-name-object.o: %v", m)
+	}
+
+	names, offsets := ar.symbols()
+	if len(names) != 2 || names[0] != "foo" || names[1] != "bar" {
+		t.Fatalf("bad symbols: %v", names)
+	}
+	if offsets[0] != ar.members[0].offset || offsets[1] != ar.members[1].offset {
+		t.Errorf("bad symbol offsets: %v", offsets)
+	}
+}
+
+func TestParseBsdArchive(t *testing.T) {
+	var buf bytes.Buffer
+	buf.WriteString(AR_MAGIC)
+	arMember(&buf, "#1/20", []byte("long-bsd-name.o\x00\x00\x00\x00\x00CCC"))
+
+	ar, err := parseArchive(buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ar.members) != 1 {
+		t.Fatalf("got %d members, want 1", len(ar.members))
+	}
+
+	m := ar.members[0]
+	if m.name != "long-bsd-name.o" || string(m.data) != "CCC" || m.size != 3 {
+		t.Errorf("bad member: %q %q %d", m.name, m.data, m.size)
+	}
+}
+
+func TestParseBadArchive(t *testing.T) {
+	tests := map[string][]byte{}
+
+	if _, err := parseArchive([]byte("not an archive")); err == nil {
+		t.Error("no error for non-archive")
+	}
+
+	// truncate in the middle of the last member
+	data := makeGnuArchive()
+	tests["truncated"] = data[:len(data)-3]
+
+	// bad long name lengths in BSD archives
+	for _, name := range []string{"#1/x", "#1/-1", "#1/100"} {
+		var buf bytes.Buffer
+		buf.WriteString(AR_MAGIC)
+		arMember(&buf, name, []byte("abcd"))
+		tests[name] = buf.Bytes()
+	}
+
+	// the name is longer than the archive
+	var buf bytes.Buffer
+	buf.WriteString(AR_MAGIC)
+	arMember(&buf, "#1/8", []byte("abcdefgh"))
+	tests["truncated name"] = buf.Bytes()[:buf.Len()-4]
+
+	// negative member size
+	buf.Reset()
+	buf.WriteString(AR_MAGIC)
+	arMember(&buf, "a.o/", nil)
+	copy(buf.Bytes()[len(AR_MAGIC)+48:], "-10       ")
+	tests["negative size"] = buf.Bytes()
+
+	for name, data := range tests {
+		if _, err := parseArchive(data); err == nil {
+			t.Errorf("%s: no error", name)
+		}
+	}
+}
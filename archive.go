@@ -0,0 +1,360 @@
+/*
+ * ELF tree - Tree viewer for ELF library dependency
+ *
+ * Copyright (C) 2017-2018  Namhyung Kim <namhyung@gmail.com>
+ *
+ * Released under MIT license.
+ */
+package main
+
+import (
+	"bytes"
+	"debug/elf"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"sort"
+)
+
+// symbol name to the object (archive member or module) defining it
+var symIndex map[string]string
+
+// returns names of undefined global symbols
+func undefinedSymbols(syms []elf.Symbol) []string {
+	var undef []string
+	seen := make(map[string]bool)
+
+	for _, s := range syms {
+		if s.Section != elf.SHN_UNDEF || s.Name == "" {
+			continue
+		}
+		if elf.ST_BIND(s.Info) == elf.STB_LOCAL || seen[s.Name] {
+			continue
+		}
+		seen[s.Name] = true
+		undef = append(undef, s.Name)
+	}
+	sort.Strings(undef)
+	return undef
+}
+
+// returns names of global symbols defined in the object
+func definedSymbols(syms []elf.Symbol) []string {
+	var defs []string
+
+	for _, s := range syms {
+		if s.Section == elf.SHN_UNDEF || s.Name == "" {
+			continue
+		}
+		if elf.ST_BIND(s.Info) == elf.STB_LOCAL {
+			continue
+		}
+		if t := elf.ST_TYPE(s.Info); t == elf.STT_SECTION || t == elf.STT_FILE {
+			continue
+		}
+		defs = append(defs, s.Name)
+	}
+	return defs
+}
+
+func isArchiveFile(pathname string) bool {
+	f, err := sysfs.Open(pathname)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+
+	buf := make([]byte, len(AR_MAGIC))
+	if _, err := f.Read(buf); err != nil {
+		return false
+	}
+	return isArchive(buf)
+}
+
+// read contents of archive members (thin archive refers to external files)
+func readMember(ar *ArArchive, m *ArMember, pathname string) ([]byte, string, error) {
+	if !ar.thin {
+		return m.data, fmt.Sprintf("%s(%s)", realPath(pathname), m.name), nil
+	}
+
+	p := m.name
+	if !path.IsAbs(p) {
+		p = path.Join(path.Dir(pathname), p)
+	}
+
+	f, err := sysfs.Open(p)
+	if err != nil {
+		return nil, p, err
+	}
+	defer f.Close()
+
+	data, err := ioutil.ReadAll(f)
+	return data, realPath(p), err
+}
+
+// load archive members into deps and resolve symbols between them
+func loadArchive(pathname string) (*DepsNode, error) {
+	f, err := sysfs.Open(pathname)
+	if err != nil {
+		return nil, err
+	}
+	data, err := ioutil.ReadAll(f)
+	f.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	ar, err := parseArchive(data)
+	if err != nil {
+		return nil, err
+	}
+
+	archive := path.Base(pathname)
+	top := DepsInfo{path: realPath(pathname), link: "archive"}
+	if ar.thin {
+		top.link = "thin archive"
+	}
+
+	symIndex = make(map[string]string)
+	offsets := make(map[int64]string)
+	names := make(map[string]bool)
+
+	for i := range ar.members {
+		m := &ar.members[i]
+
+		// same name can be used by different members
+		name := fmt.Sprintf("%s(%s)", archive, m.name)
+		for n := 2; names[name]; n++ {
+			name = fmt.Sprintf("%s(%s#%d)", archive, m.name, n)
+		}
+		names[name] = true
+		offsets[m.offset] = name
+
+		body, p, err := readMember(ar, m, pathname)
+		if err != nil {
+			return nil, fmt.Errorf("%v: %s", err, p)
+		}
+
+		info := DepsInfo{path: p, link: "relocatable"}
+
+		ef, err := elf.NewFile(bytes.NewReader(body))
+		if err != nil {
+			// e.g. LLVM bitcode or other non-ELF member
+			info.link = "non-ELF"
+			deps[name] = info
+			top.libs = append(top.libs, name)
+			continue
+		}
+
+		info.mach = ef.Machine
+		info.bits = ef.Class
+		info.kind = ef.Type
+		info.abi = ef.OSABI
+		info.ver = ef.ABIVersion
+		info.endian = ef.ByteOrder
+		info.sect = ef.Sections
+		info.notes = readNotes(ef)
+
+		syms, err := ef.Symbols()
+		if err == nil {
+			info.syms = syms
+		}
+		info.undef = undefinedSymbols(syms)
+
+		if top.endian == nil {
+			top.mach = info.mach
+			top.bits = info.bits
+			top.endian = info.endian
+		}
+
+		deps[name] = info
+		top.libs = append(top.libs, name)
+	}
+
+	// the linker uses the symbol index; fall back to member symbols without it
+	syms, offs := ar.symbols()
+	for i, sym := range syms {
+		if _, ok := symIndex[sym]; ok {
+			continue
+		}
+		if name, ok := offsets[offs[i]]; ok {
+			symIndex[sym] = name
+		}
+	}
+	if len(symIndex) == 0 {
+		for _, name := range top.libs {
+			for _, sym := range definedSymbols(deps[name].syms) {
+				if _, ok := symIndex[sym]; !ok {
+					symIndex[sym] = name
+				}
+			}
+		}
+	}
+
+	// members providing undefined symbols are dependencies
+	for _, name := range top.libs {
+		info := deps[name]
+
+		seen := make(map[string]bool)
+		for _, sym := range info.undef {
+			provider, ok := symIndex[sym]
+			if !ok || provider == name || seen[provider] {
+				continue
+			}
+			seen[provider] = true
+			info.libs = append(info.libs, provider)
+		}
+		deps[name] = info
+	}
+
+	deps[archive] = top
+	return makeArchiveTree(archive), nil
+}
+
+// build tree of members from the resolved dependencies
+func makeArchiveTree(name string) *DepsNode {
+	root := &DepsNode{name: name}
+
+	expanded := make(map[string]bool)
+	list := []*DepsNode{root}
+
+	for len(list) > 0 {
+		dep := list[0]
+		list = list[1:]
+
+		// skip duplicate members like processDep()
+		if expanded[dep.name] {
+			continue
+		}
+		expanded[dep.name] = true
+
+		var L []*DepsNode
+		for _, lib := range deps[dep.name].libs {
+			N := &DepsNode{name: lib, parent: dep, depth: dep.depth + 1}
+
+			L = append(L, N)
+			dep.child = append(dep.child, N)
+		}
+		list = append(L, list...)
+	}
+	return root
+}
+
+// returns symbols which are not defined in the archive
+func externalSymbols(archive string) []string {
+	var ext []string
+	seen := make(map[string]bool)
+
+	for _, name := range deps[archive].libs {
+		for _, sym := range deps[name].undef {
+			if _, ok := symIndex[sym]; !ok && !seen[sym] {
+				seen[sym] = true
+				ext = append(ext, sym)
+			}
+		}
+	}
+	sort.Strings(ext)
+	return ext
+}
+
+// returns members pulled in to resolve the symbol and the symbol for each
+func pulledMembers(sym string) ([]string, map[string]string) {
+	first, ok := symIndex[sym]
+	if !ok {
+		return nil, nil
+	}
+
+	reason := map[string]string{first: sym}
+	members := []string{first}
+
+	for i := 0; i < len(members); i++ {
+		for _, s := range deps[members[i]].undef {
+			provider, ok := symIndex[s]
+			if !ok {
+				continue
+			}
+			if _, done := reason[provider]; done {
+				continue
+			}
+			reason[provider] = s
+			members = append(members, provider)
+		}
+	}
+	return members, reason
+}
+
+// returns undefined symbols with the member providing each
+func makeUndefStrings(info *DepsInfo) []string {
+	var undef []string
+	for _, sym := range info.undef {
+		if provider, ok := symIndex[sym]; ok {
+			undef = append(undef, fmt.Sprintf("  %s  => %s", symName(sym), provider))
+		} else {
+			undef = append(undef, "  "+symName(sym))
+		}
+	}
+	return undef
+}
+
+func printPull(sym string) {
+	members, reason := pulledMembers(sym)
+	if members == nil {
+		fmt.Printf("elftree: `%s` is not defined in the archive\n", sym)
+		return
+	}
+
+	fmt.Printf("Members pulled in by `%s`:\n", sym)
+	for _, m := range members {
+		fmt.Printf("  %-40s  (%s)\n", m, symName(reason[m]))
+	}
+}
+
+func printArchiveDetails(root *DepsNode) {
+	info := deps[root.name]
+
+	fmt.Println()
+	fmt.Printf("%s: %s\n", root.name, info.path)
+	fmt.Printf("  type:                     %s\n", info.link)
+	fmt.Printf("  members:                  %d\n", len(info.libs))
+	fmt.Printf("  indexed symbols:          %d\n", len(symIndex))
+
+	ext := externalSymbols(root.name)
+	fmt.Printf("  external symbols:         %d\n", len(ext))
+	for _, sym := range ext {
+		fmt.Printf("    %s\n", symName(sym))
+	}
+}
+
+func archiveMain(pathname string) {
+	root, err := loadArchive(pathname)
+	if err != nil {
+		fmt.Printf("elftree: %v: %s\n", err, pathname)
+		os.Exit(1)
+	}
+
+	if pullSym != "" {
+		printPull(pullSym)
+		return
+	}
+
+	if whyLib != "" {
+		printWhy(root, whyLib)
+		return
+	}
+
+	if showCycle {
+		printCycles()
+		return
+	}
+
+	if showTui {
+		ShowWithTUI(root)
+		return
+	}
+
+	printDepTree(root, nil)
+	if verbose {
+		printArchiveDetails(root)
+	}
+}
@@ -0,0 +1,104 @@
+/*
+ * ELF tree - Tree viewer for ELF library dependency
+ *
+ * Copyright (C) 2017-2018  Namhyung Kim <namhyung@gmail.com>
+ *
+ * Released under MIT license.
+ */
+package main
+
+import (
+	"encoding/binary"
+	"reflect"
+	"testing"
+)
+
+const ARCHIVE_FIXTURE = "testdata/libpull.a"
+
+func TestLoadArchive(t *testing.T) {
+	deps = make(map[string]DepsInfo)
+
+	root, err := loadArchive(ARCHIVE_FIXTURE)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if root.name != "libpull.a" || len(root.child) != 3 {
+		t.Fatalf("got %s with %d members", root.name, len(root.child))
+	}
+
+	// a.o needs b.o which needs c.o
+	var chain []string
+	for dn := root.child[0]; dn != nil; {
+		chain = append(chain, dn.name)
+		if len(dn.child) != 1 {
+			break
+		}
+		dn = dn.child[0]
+	}
+	want := []string{"libpull.a(a.o)", "libpull.a(b.o)", "libpull.a(c.o)"}
+	if !reflect.DeepEqual(chain, want) {
+		t.Errorf("tree: got %v, want %v", chain, want)
+	}
+
+	// from the symbol index
+	for sym, member := range map[string]string{
+		"a_func": "libpull.a(a.o)",
+		"b_func": "libpull.a(b.o)",
+		"c_func": "libpull.a(c.o)",
+		"c_data": "libpull.a(c.o)",
+	} {
+		if symIndex[sym] != member {
+			t.Errorf("%s: got %s, want %s", sym, symIndex[sym], member)
+		}
+	}
+
+	if ext := externalSymbols("libpull.a"); !reflect.DeepEqual(ext, []string{"puts"}) {
+		t.Errorf("external symbols: got %v", ext)
+	}
+}
+
+func TestPulledMembers(t *testing.T) {
+	deps = make(map[string]DepsInfo)
+	if _, err := loadArchive(ARCHIVE_FIXTURE); err != nil {
+		t.Fatal(err)
+	}
+
+	members, reason := pulledMembers("b_func")
+	want := []string{"libpull.a(b.o)", "libpull.a(c.o)"}
+	if !reflect.DeepEqual(members, want) {
+		t.Errorf("got %v, want %v", members, want)
+	}
+	if reason["libpull.a(c.o)"] != "c_func" {
+		t.Errorf("c.o is pulled by %s", reason["libpull.a(c.o)"])
+	}
+
+	if members, _ := pulledMembers("puts"); members != nil {
+		t.Errorf("got %v for undefined symbol", members)
+	}
+}
+
+func TestArchiveSymbolsBsd(t *testing.T) {
+	strtab := []byte("foo\x00bar\x00")
+
+	// (string index, member offset) pairs
+	var data []byte
+	data = binary.LittleEndian.AppendUint32(data, 16)
+	for _, v := range []uint32{0, 68, 4, 200} {
+		data = binary.LittleEndian.AppendUint32(data, v)
+	}
+	data = binary.LittleEndian.AppendUint32(data, uint32(len(strtab)))
+	data = append(data, strtab...)
+
+	ar := &ArArchive{symname: "__.SYMDEF SORTED", symtab: data}
+	names, offs := ar.symbols()
+	if !reflect.DeepEqual(names, []string{"foo", "bar"}) || !reflect.DeepEqual(offs, []int64{68, 200}) {
+		t.Errorf("got %v at %v", names, offs)
+	}
+
+	// truncated index
+	ar.symtab = data[:12]
+	if names, _ := ar.symbols(); names != nil {
+		t.Errorf("got %v from truncated index", names)
+	}
+}
@@ -0,0 +1,54 @@
+/*
+ * ELF tree - Tree viewer for ELF library dependency
+ *
+ * Copyright (C) 2017-2018  Namhyung Kim <namhyung@gmail.com>
+ *
+ * Released under MIT license.
+ */
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"io"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/ulikunitz/xz"
+)
+
+var (
+	MAGIC_GZIP  = []byte{0x1f, 0x8b}
+	MAGIC_XZ    = []byte{0xfd, '7', 'z', 'X', 'Z', 0x00}
+	MAGIC_ZSTD  = []byte{0x28, 0xb5, 0x2f, 0xfd}
+	MAGIC_BZIP2 = []byte("BZh")
+)
+
+// returns uncompressed stream of the data (detected by magic)
+func decompress(data []byte) (io.Reader, error) {
+	return decompressReader(bytes.NewReader(data))
+}
+
+func decompressReader(r io.Reader) (io.Reader, error) {
+	br := bufio.NewReader(r)
+	magic, _ := br.Peek(len(MAGIC_XZ)) // the longest
+
+	switch {
+	case bytes.HasPrefix(magic, MAGIC_GZIP):
+		return gzip.NewReader(br)
+	case bytes.HasPrefix(magic, MAGIC_XZ):
+		return xz.NewReader(br)
+	case bytes.HasPrefix(magic, MAGIC_ZSTD):
+		return zstd.NewReader(br)
+	case bytes.HasPrefix(magic, MAGIC_BZIP2):
+		return bzip2.NewReader(br), nil
+	default:
+		return br, nil
+	}
+}
+
+func isCompressed(data []byte) bool {
+	return bytes.HasPrefix(data, MAGIC_GZIP) || bytes.HasPrefix(data, MAGIC_XZ) ||
+		bytes.HasPrefix(data, MAGIC_ZSTD) || bytes.HasPrefix(data, MAGIC_BZIP2)
+}
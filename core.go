@@ -0,0 +1,632 @@
+/*
+ * ELF tree - Tree viewer for ELF library dependency
+ *
+ * Copyright (C) 2017-2018  Namhyung Kim <namhyung@gmail.com>
+ *
+ * Released under MIT license.
+ */
+package main
+
+import (
+	"bytes"
+	"debug/elf"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+	"syscall"
+)
+
+const (
+	AT_ENTRY        = 9
+	AT_SYSINFO_EHDR = 33
+	VDSO_NAME       = "[vdso]"
+)
+
+// directories having files named by build-id
+var buildIdDirs = []string{"/usr/lib/.build-id", "/usr/lib/debug/.build-id"}
+
+// file mapping in NT_FILE note
+type CoreFile struct {
+	start uint64
+	end   uint64
+	pgoff uint64 // in bytes
+	name  string
+}
+
+// object loaded at crash time
+type CoreObj struct {
+	path    string
+	base    uint64 // load bias
+	start   uint64 // first mapped address
+	buildId string // in memory
+	diskId  string // on disk
+	exists  bool
+	found   string // file having the same build-id (if the path doesn't)
+	vdso    bool
+}
+
+type CoreInfo struct {
+	f      *elf.File
+	path   string
+	pid    int
+	signal int
+	entry  uint64
+	vdso   uint64 // address of vDSO image
+	exe    string
+	files  []CoreFile
+	objs   []CoreObj
+}
+
+func (ci *CoreInfo) wordSize() uint64 {
+	if ci.f.Class == elf.ELFCLASS64 {
+		return 8
+	}
+	return 4
+}
+
+func (ci *CoreInfo) word(data []byte) uint64 {
+	if ci.f.Class == elf.ELFCLASS64 {
+		return ci.f.ByteOrder.Uint64(data)
+	}
+	return uint64(ci.f.ByteOrder.Uint32(data))
+}
+
+// read memory contents saved in the core file
+func (ci *CoreInfo) readMem(addr, size uint64) []byte {
+	for _, p := range ci.f.Progs {
+		if p.Type != elf.PT_LOAD {
+			continue
+		}
+		if addr < p.Vaddr || size > p.Filesz || addr-p.Vaddr > p.Filesz-size {
+			continue
+		}
+
+		buf := make([]byte, size)
+		if _, err := p.ReadAt(buf, int64(addr-p.Vaddr)); err != nil {
+			return nil
+		}
+		return buf
+	}
+	return nil
+}
+
+func (ci *CoreInfo) readPtr(addr uint64) (uint64, bool) {
+	buf := ci.readMem(addr, ci.wordSize())
+	if buf == nil {
+		return 0, false
+	}
+	return ci.word(buf), true
+}
+
+func (ci *CoreInfo) readString(addr uint64) string {
+	var str []byte
+
+	for len(str) < 4096 {
+		buf := ci.readMem(addr, 1)
+		if buf == nil || buf[0] == 0 {
+			break
+		}
+		str = append(str, buf[0])
+		addr++
+	}
+	return string(str)
+}
+
+func (ci *CoreInfo) parseFileNote(desc []byte) {
+	ws := ci.wordSize()
+	if uint64(len(desc)) < 2*ws {
+		return
+	}
+
+	count := ci.word(desc[0:])
+	pgsize := ci.word(desc[ws:])
+
+	// reject truncated or corrupted notes
+	if count > (uint64(len(desc))/ws-2)/3 {
+		return
+	}
+
+	names := desc[(2+3*count)*ws:]
+	if uint64(bytes.Count(names, []byte{0})) < count {
+		return
+	}
+
+	for i := uint64(0); i < count; i++ {
+		ent := desc[(2+3*i)*ws:]
+
+		var name string
+		for j, c := range names {
+			if c == 0 {
+				name = string(names[:j])
+				names = names[j+1:]
+				break
+			}
+		}
+
+		ci.files = append(ci.files, CoreFile{
+			start: ci.word(ent[0:]),
+			end:   ci.word(ent[ws:]),
+			pgoff: ci.word(ent[2*ws:]) * pgsize,
+			name:  name,
+		})
+	}
+}
+
+func (ci *CoreInfo) parseNotes() {
+	for _, n := range readNotes(ci.f) {
+		if n.name != "CORE" {
+			continue
+		}
+
+		switch n.kind {
+		case NT_PRSTATUS:
+			// use the first thread only
+			if ci.pid != 0 || len(n.desc) < 40 {
+				continue
+			}
+			ci.signal = int(ci.f.ByteOrder.Uint16(n.desc[12:]))
+			if ci.f.Class == elf.ELFCLASS64 {
+				ci.pid = int(ci.f.ByteOrder.Uint32(n.desc[32:]))
+			} else {
+				ci.pid = int(ci.f.ByteOrder.Uint32(n.desc[24:]))
+			}
+		case NT_AUXV:
+			ws := ci.wordSize()
+			for i := uint64(0); i+2*ws <= uint64(len(n.desc)); i += 2 * ws {
+				switch ci.word(n.desc[i:]) {
+				case AT_ENTRY:
+					ci.entry = ci.word(n.desc[i+ws:])
+				case AT_SYSINFO_EHDR:
+					ci.vdso = ci.word(n.desc[i+ws:])
+				}
+			}
+		case NT_FILE:
+			ci.parseFileNote(n.desc)
+		}
+	}
+
+	// the executable has a mapping of the entry point
+	for _, file := range ci.files {
+		if file.start <= ci.entry && ci.entry < file.end {
+			ci.exe = file.name
+			break
+		}
+	}
+}
+
+// find r_debug using DT_DEBUG in the dynamic section of the executable
+func (ci *CoreInfo) findRDebug() uint64 {
+	ef, err := elf.Open(ci.exe)
+	if err != nil {
+		return 0
+	}
+	defer ef.Close()
+
+	bias := ci.entry - ef.Entry
+	ws := ci.wordSize()
+
+	for _, p := range ef.Progs {
+		if p.Type != elf.PT_DYNAMIC {
+			continue
+		}
+
+		addr := p.Vaddr + bias
+		for i := uint64(0); i < p.Memsz/(2*ws); i++ {
+			tag, ok := ci.readPtr(addr + i*2*ws)
+			if !ok || elf.DynTag(tag) == elf.DT_NULL {
+				break
+			}
+			if elf.DynTag(tag) == elf.DT_DEBUG {
+				val, _ := ci.readPtr(addr + i*2*ws + ws)
+				return val
+			}
+		}
+	}
+	return 0
+}
+
+// walk the link_map chain in r_debug
+func (ci *CoreInfo) readLinkMap() bool {
+	rdebug := ci.findRDebug()
+	if rdebug == 0 {
+		return false
+	}
+
+	ws := ci.wordSize()
+
+	// struct r_debug { int r_version; struct link_map *r_map; ... }
+	lm, ok := ci.readPtr(rdebug + ws)
+	if !ok {
+		return false
+	}
+
+	// struct link_map { l_addr, l_name, l_ld, l_next, l_prev }
+	for i := 0; lm != 0 && i < 4096; i++ {
+		addr, ok1 := ci.readPtr(lm)
+		name, ok2 := ci.readPtr(lm + ws)
+		next, ok3 := ci.readPtr(lm + 3*ws)
+		if !ok1 || !ok2 || !ok3 {
+			break
+		}
+
+		// the first entry is the executable itself
+		if i > 0 {
+			obj := CoreObj{path: ci.readString(name), base: addr}
+
+			// vDSO has no file (and might have no name)
+			if obj.path == "" || (addr == ci.vdso && !strings.HasPrefix(obj.path, "/")) {
+				if obj.path == "" {
+					obj.path = VDSO_NAME
+				}
+				obj.start = ci.vdso
+				obj.vdso = true
+			}
+			ci.objs = append(ci.objs, obj)
+		}
+		lm = next
+	}
+	return len(ci.objs) > 0
+}
+
+// use mapped ELF files in NT_FILE if link_map is not available
+func (ci *CoreInfo) readFileMap() {
+	for _, file := range ci.files {
+		if file.pgoff != 0 || file.name == ci.exe {
+			continue
+		}
+		if !isDynamicElf(file.name) {
+			continue
+		}
+		ci.objs = append(ci.objs, CoreObj{path: file.name, start: file.start})
+	}
+
+	if ci.vdso != 0 {
+		ci.objs = append(ci.objs, CoreObj{path: VDSO_NAME, start: ci.vdso, vdso: true})
+	}
+}
+
+// read build-id from ELF image in memory
+func (ci *CoreInfo) memBuildId(obj *CoreObj) string {
+	hdr := ci.readMem(obj.start, 64)
+	if hdr == nil || string(hdr[0:4]) != elf.ELFMAG {
+		return ""
+	}
+
+	var phoff, phentsize, phnum, phsize uint64
+	if ci.f.Class == elf.ELFCLASS64 {
+		phoff = ci.f.ByteOrder.Uint64(hdr[32:])
+		phentsize = uint64(ci.f.ByteOrder.Uint16(hdr[54:]))
+		phnum = uint64(ci.f.ByteOrder.Uint16(hdr[56:]))
+		phsize = 56 // sizeof(Elf64_Phdr)
+	} else {
+		phoff = uint64(ci.f.ByteOrder.Uint32(hdr[28:]))
+		phentsize = uint64(ci.f.ByteOrder.Uint16(hdr[42:]))
+		phnum = uint64(ci.f.ByteOrder.Uint16(hdr[44:]))
+		phsize = 32 // sizeof(Elf32_Phdr)
+	}
+	if phentsize < phsize {
+		return ""
+	}
+
+	phdr := ci.readMem(obj.start+phoff, phentsize*phnum)
+	if phdr == nil {
+		return ""
+	}
+
+	type note struct{ vaddr, size uint64 }
+
+	var notes []note
+	lowest := ^uint64(0)
+
+	for i := uint64(0); i < phnum && (i+1)*phentsize <= uint64(len(phdr)); i++ {
+		ph := phdr[i*phentsize:]
+		typ := elf.ProgType(ci.f.ByteOrder.Uint32(ph[0:]))
+
+		var vaddr, filesz uint64
+		if ci.f.Class == elf.ELFCLASS64 {
+			vaddr = ci.f.ByteOrder.Uint64(ph[16:])
+			filesz = ci.f.ByteOrder.Uint64(ph[32:])
+		} else {
+			vaddr = uint64(ci.f.ByteOrder.Uint32(ph[8:]))
+			filesz = uint64(ci.f.ByteOrder.Uint32(ph[16:]))
+		}
+
+		if typ == elf.PT_LOAD && vaddr < lowest {
+			lowest = vaddr
+		}
+		if typ == elf.PT_NOTE {
+			notes = append(notes, note{vaddr, filesz})
+		}
+	}
+
+	bias := obj.start - (lowest &^ 0xfff)
+	for _, n := range notes {
+		data := ci.readMem(n.vaddr+bias, n.size)
+		if data == nil {
+			continue
+		}
+		if id := findBuildId(parseNotes(data, ci.f.ByteOrder)); id != "" {
+			return id
+		}
+	}
+	return ""
+}
+
+// find a file having the build-id in build-id directories or library paths
+func findByBuildId(id, name string) string {
+	if len(id) < 3 {
+		return ""
+	}
+
+	for _, dir := range buildIdDirs {
+		pathname := path.Join(dir, id[:2], id[2:])
+		if readBuildId(pathname) == id {
+			return realPath(pathname)
+		}
+	}
+
+	base := path.Base(name)
+	dirs := append(strings.Split(envlib, ":"), conflib...)
+	for _, dir := range append(dirs, deflib...) {
+		if dir == "" {
+			continue
+		}
+
+		pathname := path.Join(dir, base)
+		if readBuildId(pathname) == id {
+			return pathname
+		}
+	}
+	return ""
+}
+
+func (ci *CoreInfo) matchFiles() {
+	for i := range ci.objs {
+		obj := &ci.objs[i]
+
+		if obj.vdso {
+			if obj.base == 0 {
+				obj.base = obj.start
+			}
+			obj.buildId = ci.memBuildId(obj)
+			continue
+		}
+
+		// link_map might have a path via symlinks
+		resolved := realPath(obj.path)
+		for _, file := range ci.files {
+			if (file.name == obj.path || file.name == resolved) && file.pgoff == 0 {
+				obj.start = file.start
+				break
+			}
+		}
+		if obj.base == 0 && obj.start != 0 {
+			obj.base = obj.start
+		}
+
+		if _, err := os.Stat(obj.path); err == nil {
+			obj.exists = true
+			obj.diskId = readBuildId(obj.path)
+		}
+		if obj.start != 0 {
+			obj.buildId = ci.memBuildId(obj)
+		}
+
+		// the file might be moved or replaced after the crash
+		if obj.buildId != "" && obj.buildId != obj.diskId {
+			obj.found = findByBuildId(obj.buildId, obj.path)
+		}
+	}
+}
+
+func (obj *CoreObj) buildIdStatus() string {
+	if obj.vdso {
+		return "vdso"
+	}
+	if !obj.exists {
+		return "no file"
+	}
+	if obj.buildId == "" || obj.diskId == "" {
+		return "unknown"
+	}
+	if obj.buildId != obj.diskId {
+		return "MISMATCH"
+	}
+	return "match"
+}
+
+func readCore(pathname string) (*CoreInfo, error) {
+	f, err := elf.Open(pathname)
+	if err != nil {
+		return nil, err
+	}
+
+	ci := &CoreInfo{f: f, path: pathname}
+	ci.parseNotes()
+
+	if ci.exe == "" {
+		f.Close()
+		return nil, fmt.Errorf("cannot find executable in the core: %s", pathname)
+	}
+
+	if !ci.readLinkMap() {
+		ci.readFileMap()
+	}
+	ci.matchFiles()
+
+	return ci, nil
+}
+
+// object info from the core for files not available
+func (ci *CoreInfo) coreDepsInfo(pathname string, kind elf.Type) DepsInfo {
+	return DepsInfo{
+		path:   pathname,
+		mach:   ci.f.Machine,
+		bits:   ci.f.Class,
+		endian: ci.f.ByteOrder,
+		kind:   kind,
+		abi:    ci.f.OSABI,
+		link:   "dynamic",
+	}
+}
+
+// read the vDSO image in the core memory
+func (ci *CoreInfo) vdsoDepsInfo(obj *CoreObj) DepsInfo {
+	info := ci.coreDepsInfo(VDSO_NAME, elf.ET_DYN)
+
+	var data []byte
+	for _, p := range ci.f.Progs {
+		if p.Type == elf.PT_LOAD && p.Vaddr == obj.start {
+			data = ci.readMem(p.Vaddr, p.Filesz)
+		}
+	}
+	if data == nil {
+		return info
+	}
+
+	f, err := elf.NewFile(bytes.NewReader(data))
+	if err != nil {
+		return info
+	}
+
+	info.mach = f.Machine
+	info.bits = f.Class
+	info.kind = f.Type
+	info.endian = f.ByteOrder
+	info.prog = f.Progs
+	info.sect = f.Sections
+	info.notes = readNotes(f)
+
+	if dsym, err := f.DynamicSymbols(); err == nil {
+		info.dsym = dsym
+	}
+	readDynamic(f, &info)
+	return info
+}
+
+func isCoreFile(pathname string) bool {
+	f, err := elf.Open(pathname)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+
+	return f.Type == elf.ET_CORE
+}
+
+func printCoreInfo(ci *CoreInfo) {
+	fmt.Printf("core: %s (pid %d, signal %d: %v)\n", ci.path, ci.pid,
+		ci.signal, syscall.Signal(ci.signal))
+	fmt.Printf("exe:  %s", ci.exe)
+	if _, err := sysfs.Stat(ci.exe); err != nil {
+		fmt.Printf(" (not found)")
+	}
+	fmt.Println()
+	fmt.Println()
+
+	fmt.Println("Loaded objects:")
+	fmt.Printf("  %18s  %-8s  %s\n", "Base", "Build-ID", "Path")
+	for _, obj := range ci.objs {
+		fmt.Printf("  %#18x  %-8s  %s", obj.base, obj.buildIdStatus(), obj.path)
+		if obj.found != "" {
+			fmt.Printf(" (found: %s)", obj.found)
+		}
+		fmt.Println()
+	}
+}
+
+func coreMain(pathname string) {
+	ci, err := readCore(pathname)
+	if err != nil {
+		fmt.Printf("elftree: %v\n", err)
+		os.Exit(1)
+	}
+	defer ci.f.Close()
+
+	depsMarks = make(map[string]rune)
+	depsNotes = make(map[string]string)
+
+	// show loaded objects as children of the executable
+	root := &DepsNode{name: path.Base(ci.exe)}
+	deps_path = ci.exe
+
+	exe, err := sysfs.OpenElf(ci.exe)
+	if err == nil {
+		exe.Close()
+		processDep(root)
+	} else {
+		// the executable might be removed after the crash
+		deps[root.name] = ci.coreDepsInfo(ci.exe, elf.ET_EXEC)
+	}
+
+	root.child = nil
+	deps_list = nil
+
+	for _, obj := range ci.objs {
+		if obj.vdso {
+			deps[obj.path] = ci.vdsoDepsInfo(&obj)
+
+			dep := &DepsNode{name: obj.path, parent: root, depth: 1}
+			root.child = append(root.child, dep)
+
+			depsNotes[obj.path] = fmt.Sprintf("base %#x, in memory", obj.base)
+			continue
+		}
+
+		// use the file found by the build-id instead
+		name := obj.path
+		if obj.found != "" {
+			name = obj.found
+		} else if !obj.exists {
+			continue
+		}
+		if !isDynamicElf(name) {
+			continue
+		}
+
+		dep := &DepsNode{name: name, parent: root, depth: 1}
+		processDep(dep)
+
+		dep.child = nil
+		deps_list = nil
+
+		root.child = append(root.child, dep)
+
+		if obj.buildIdStatus() == "MISMATCH" && obj.found == "" {
+			depsMarks[name] = '!'
+		}
+		depsNotes[name] = fmt.Sprintf("base %#x, build-id %s", obj.base, obj.buildIdStatus())
+		if obj.found != "" {
+			depsNotes[name] += ", loaded from " + obj.path
+		}
+	}
+	depsNotes[root.name] = fmt.Sprintf("core of pid %d, signal %d", ci.pid, ci.signal)
+
+	if exe == nil {
+		// loaded objects are the dependencies
+		info := deps[root.name]
+		for _, dep := range root.child {
+			info.libs = append(info.libs, dep.name)
+		}
+		deps[root.name] = info
+
+		depsMarks[root.name] = '!'
+		depsNotes[root.name] += ", executable not found"
+	}
+
+	if showTui {
+		ShowWithTUI(root)
+		return
+	}
+
+	// details are not shown without the executable
+	f, _ := sysfs.OpenElf(ci.exe)
+	if f != nil {
+		defer f.Close()
+	}
+
+	printDepTree(root, f)
+	fmt.Println()
+	printCoreInfo(ci)
+}
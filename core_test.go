@@ -0,0 +1,231 @@
+/*
+ * ELF tree - Tree viewer for ELF library dependency
+ *
+ * Copyright (C) 2017-2018  Namhyung Kim <namhyung@gmail.com>
+ *
+ * Released under MIT license.
+ */
+package main
+
+import (
+	"bytes"
+	"debug/elf"
+	"encoding/binary"
+	"testing"
+)
+
+func testCoreInfo(class elf.Class) *CoreInfo {
+	f := &elf.File{FileHeader: elf.FileHeader{Class: class, ByteOrder: binary.LittleEndian}}
+	return &CoreInfo{f: f}
+}
+
+// NT_FILE: count, page size, (start, end, pgoff) * count, names
+func makeFileNote(class elf.Class, count uint64, files []CoreFile) []byte {
+	var desc []byte
+	put := func(v uint64) {
+		if class == elf.ELFCLASS64 {
+			desc = binary.LittleEndian.AppendUint64(desc, v)
+		} else {
+			desc = binary.LittleEndian.AppendUint32(desc, uint32(v))
+		}
+	}
+
+	put(count)
+	put(4096)
+	for _, f := range files {
+		put(f.start)
+		put(f.end)
+		put(f.pgoff / 4096)
+	}
+	for _, f := range files {
+		desc = append(desc, f.name...)
+		desc = append(desc, 0)
+	}
+	return desc
+}
+
+var coreFiles = []CoreFile{
+	{0x400000, 0x401000, 0, "/usr/bin/prog"},
+	{0x401000, 0x402000, 0x1000, "/usr/bin/prog"},
+	{0x7f0000000000, 0x7f0000020000, 0, "/lib/libc.so.6"},
+}
+
+func TestParseFileNote(t *testing.T) {
+	for _, class := range []elf.Class{elf.ELFCLASS32, elf.ELFCLASS64} {
+		ci := testCoreInfo(class)
+		ci.parseFileNote(makeFileNote(class, uint64(len(coreFiles)), coreFiles))
+
+		if len(ci.files) != len(coreFiles) {
+			t.Fatalf("%v: got %d files, want %d", class, len(ci.files), len(coreFiles))
+		}
+		for i, f := range ci.files {
+			want := coreFiles[i]
+			if class == elf.ELFCLASS32 {
+				want.start &= 0xffffffff
+				want.end &= 0xffffffff
+			}
+			if f != want {
+				t.Errorf("%v: file %d: got %+v, want %+v", class, i, f, want)
+			}
+		}
+	}
+}
+
+func TestParseBadFileNote(t *testing.T) {
+	class := elf.ELFCLASS64
+	note := makeFileNote(class, uint64(len(coreFiles)), coreFiles)
+
+	tests := map[string][]byte{
+		"empty":      nil,
+		"short":      note[:12],
+		"truncated":  note[:2*8+3*8*2],
+		"huge count": makeFileNote(class, 1<<61, coreFiles),
+		"big count":  makeFileNote(class, uint64(len(coreFiles)+1), coreFiles),
+	}
+
+	for name, desc := range tests {
+		ci := testCoreInfo(class)
+		ci.parseFileNote(desc)
+		if len(ci.files) != 0 {
+			t.Errorf("%s: got %d files", name, len(ci.files))
+		}
+	}
+}
+
+func TestBuildIdStatus(t *testing.T) {
+	tests := []struct {
+		obj  CoreObj
+		want string
+	}{
+		{CoreObj{vdso: true, buildId: "1234"}, "vdso"},
+		{CoreObj{buildId: "1234"}, "no file"},
+		{CoreObj{exists: true, buildId: "1234"}, "unknown"},
+		{CoreObj{exists: true, buildId: "1234", diskId: "5678"}, "MISMATCH"},
+		{CoreObj{exists: true, buildId: "1234", diskId: "1234"}, "match"},
+	}
+
+	for _, tt := range tests {
+		if got := tt.obj.buildIdStatus(); got != tt.want {
+			t.Errorf("%+v: got %q, want %q", tt.obj, got, tt.want)
+		}
+	}
+}
+
+func makeNote(namesz, descsz, kind uint32, payload string) []byte {
+	data := make([]byte, 12)
+	binary.LittleEndian.PutUint32(data[0:], namesz)
+	binary.LittleEndian.PutUint32(data[4:], descsz)
+	binary.LittleEndian.PutUint32(data[8:], kind)
+	return append(data, payload...)
+}
+
+func TestParseNotes(t *testing.T) {
+	good := makeNote(5, 4, NT_PRSTATUS, "CORE\x00\x00\x00\x00abcd")
+
+	notes := parseNotes(good, binary.LittleEndian)
+	if len(notes) != 1 || notes[0].name != "CORE" || string(notes[0].desc) != "abcd" {
+		t.Fatalf("got %+v", notes)
+	}
+
+	// sizes which wrap around when aligned in 32-bit
+	tests := map[string][]byte{
+		"huge namesz": makeNote(0xfffffffe, 4, NT_FILE, "CORE\x00\x00\x00\x00abcd"),
+		"huge descsz": makeNote(5, 0xfffffffd, NT_FILE, "CORE\x00\x00\x00\x00abcd"),
+		"both":        makeNote(0xffffffff, 0xffffffff, NT_FILE, "CORE\x00\x00\x00\x00abcd"),
+		"over data":   makeNote(5, 12, NT_FILE, "CORE\x00\x00\x00\x00abcd"),
+	}
+	for name, bad := range tests {
+		notes := parseNotes(append(append([]byte{}, good...), bad...), binary.LittleEndian)
+		if len(notes) != 1 {
+			t.Errorf("%s: got %d notes", name, len(notes))
+		}
+	}
+}
+
+func elfHeader64(typ elf.Type, phentsize, phnum int) []byte {
+	hdr := elf.Header64{
+		Type:      uint16(typ),
+		Machine:   uint16(elf.EM_X86_64),
+		Version:   uint32(elf.EV_CURRENT),
+		Phoff:     64,
+		Ehsize:    64,
+		Phentsize: uint16(phentsize),
+		Phnum:     uint16(phnum),
+	}
+	copy(hdr.Ident[:], elf.ELFMAG)
+	hdr.Ident[elf.EI_CLASS] = byte(elf.ELFCLASS64)
+	hdr.Ident[elf.EI_DATA] = byte(elf.ELFDATA2LSB)
+	hdr.Ident[elf.EI_VERSION] = byte(elf.EV_CURRENT)
+
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.LittleEndian, &hdr)
+	return buf.Bytes()
+}
+
+func progHeader64(typ elf.ProgType, off, vaddr, size uint64) []byte {
+	ph := elf.Prog64{Type: uint32(typ), Off: off, Vaddr: vaddr, Filesz: size, Memsz: size}
+
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.LittleEndian, &ph)
+	return buf.Bytes()
+}
+
+// core file with an ELF image (having a build-id note) mapped at 0x10000
+func makeCoreImage(t *testing.T, phentsize int) *CoreInfo {
+	note := makeNote(4, 4, NT_GNU_BUILD_ID, "GNU\x00\xde\xad\xbe\xef")
+
+	image := elfHeader64(elf.ET_DYN, phentsize, 2)
+	ph := progHeader64(elf.PT_LOAD, 0, 0, 0x200)
+	ph = append(ph, progHeader64(elf.PT_NOTE, 0x100, 0x100, uint64(len(note)))...)
+	if phentsize < len(ph)/2 {
+		ph = ph[:2*phentsize]
+	}
+	image = append(image, ph...)
+	image = append(image, make([]byte, 0x100-len(image))...)
+	image = append(image, note...)
+	image = append(image, make([]byte, 0x200-len(image))...)
+
+	core := elfHeader64(elf.ET_CORE, 56, 1)
+	core = append(core, progHeader64(elf.PT_LOAD, 0x100, 0x10000, uint64(len(image)))...)
+	core = append(core, make([]byte, 0x100-len(core))...)
+	core = append(core, image...)
+
+	f, err := elf.NewFile(bytes.NewReader(core))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &CoreInfo{f: f}
+}
+
+func TestMemBuildId(t *testing.T) {
+	ci := makeCoreImage(t, 56)
+	if id := ci.memBuildId(&CoreObj{start: 0x10000}); id != "deadbeef" {
+		t.Errorf("got build-id %q", id)
+	}
+
+	// program headers smaller than Elf64_Phdr
+	ci = makeCoreImage(t, 8)
+	if id := ci.memBuildId(&CoreObj{start: 0x10000}); id != "" {
+		t.Errorf("got build-id %q with small phentsize", id)
+	}
+
+	// out of the mapping or wrapping around
+	if ci.readMem(0x10000, 0x201) != nil || ci.readMem(0x10100, ^uint64(0)-0x80) != nil {
+		t.Error("read beyond the mapping")
+	}
+}
+
+func TestVdsoDepsInfo(t *testing.T) {
+	ci := makeCoreImage(t, 56)
+
+	info := ci.vdsoDepsInfo(&CoreObj{path: VDSO_NAME, start: 0x10000, vdso: true})
+	if info.kind != elf.ET_DYN || len(info.prog) != 2 || findBuildId(info.notes) != "deadbeef" {
+		t.Errorf("got %s with %d segments, build-id %q", info.kind, len(info.prog), findBuildId(info.notes))
+	}
+
+	// not in the core: still shown as a leaf
+	info = ci.vdsoDepsInfo(&CoreObj{path: VDSO_NAME, start: 0x20000, vdso: true})
+	if info.path != VDSO_NAME || info.endian == nil || len(info.prog) != 0 {
+		t.Errorf("got %+v", info)
+	}
+}
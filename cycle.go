@@ -0,0 +1,177 @@
+/*
+ * ELF tree - Tree viewer for ELF library dependency
+ *
+ * Copyright (C) 2017-2018  Namhyung Kim <namhyung@gmail.com>
+ *
+ * Released under MIT license.
+ */
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+const CYCLE_MARK = "↺"
+
+type sccState struct {
+	index   map[string]int
+	lowlink map[string]int
+	onStack map[string]bool
+	stack   []string
+	next    int
+	result  [][]string
+}
+
+// Tarjan's strongly connected components algorithm
+func (st *sccState) connect(name string) {
+	st.index[name] = st.next
+	st.lowlink[name] = st.next
+	st.next++
+
+	st.stack = append(st.stack, name)
+	st.onStack[name] = true
+
+	for _, lib := range deps[name].libs {
+		if _, ok := deps[lib]; !ok {
+			continue
+		}
+
+		if _, ok := st.index[lib]; !ok {
+			st.connect(lib)
+			if st.lowlink[lib] < st.lowlink[name] {
+				st.lowlink[name] = st.lowlink[lib]
+			}
+		} else if st.onStack[lib] && st.index[lib] < st.lowlink[name] {
+			st.lowlink[name] = st.index[lib]
+		}
+	}
+
+	if st.lowlink[name] != st.index[name] {
+		return
+	}
+
+	var scc []string
+	for {
+		n := len(st.stack) - 1
+		top := st.stack[n]
+
+		st.stack = st.stack[:n]
+		st.onStack[top] = false
+
+		scc = append(scc, top)
+		if top == name {
+			break
+		}
+	}
+	st.result = append(st.result, scc)
+}
+
+// returns strongly connected components which have a cycle
+func findSCC() [][]string {
+	st := &sccState{
+		index:   make(map[string]int),
+		lowlink: make(map[string]int),
+		onStack: make(map[string]bool),
+	}
+
+	var names []string
+	for k := range deps {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if _, ok := st.index[name]; !ok {
+			st.connect(name)
+		}
+	}
+
+	var sccs [][]string
+	for _, scc := range st.result {
+		if len(scc) == 1 && !needsLib(scc[0], scc[0]) {
+			continue
+		}
+		sort.Strings(scc)
+		sccs = append(sccs, scc)
+	}
+	return sccs
+}
+
+func needsLib(name, lib string) bool {
+	for _, l := range deps[name].libs {
+		if l == lib {
+			return true
+		}
+	}
+	return false
+}
+
+func walkCycles(start, name string, member map[string]bool, path []string,
+	visited map[string]bool, cycles [][]string) [][]string {
+	path = append(path, name)
+	visited[name] = true
+
+	for _, lib := range deps[name].libs {
+		if lib == start {
+			c := make([]string, len(path))
+			copy(c, path)
+			cycles = append(cycles, c)
+			continue
+		}
+		// only visit members later than start to find each cycle once
+		if !member[lib] || visited[lib] || lib < start {
+			continue
+		}
+		cycles = walkCycles(start, lib, member, path, visited, cycles)
+	}
+
+	visited[name] = false
+	return cycles
+}
+
+// returns every elementary cycle in the strongly connected component
+func findCycles(scc []string) [][]string {
+	member := make(map[string]bool)
+	for _, name := range scc {
+		member[name] = true
+	}
+
+	var cycles [][]string
+	for _, start := range scc {
+		cycles = walkCycles(start, start, member, nil, make(map[string]bool), cycles)
+	}
+	return cycles
+}
+
+func printCycles() {
+	sccs := findSCC()
+	if len(sccs) == 0 {
+		fmt.Println("no dependency cycle")
+		return
+	}
+
+	for i, scc := range sccs {
+		fmt.Printf("Cycle group #%d: %s\n", i+1, strings.Join(scc, " "))
+
+		for _, c := range findCycles(scc) {
+			var edges []string
+			for j, name := range c {
+				next := c[(j+1)%len(c)]
+				edges = append(edges, name+" -> "+next)
+			}
+			fmt.Printf("  %s %s\n", CYCLE_MARK, strings.Join(edges, ", "))
+		}
+	}
+}
+
+// check whether the node refers to one of its ancestors
+func isBackEdge(dn *DepsNode) bool {
+	for p := dn.parent; p != nil; p = p.parent {
+		if p.name == dn.name {
+			return true
+		}
+	}
+	return false
+}
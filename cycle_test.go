@@ -0,0 +1,79 @@
+/*
+ * ELF tree - Tree viewer for ELF library dependency
+ *
+ * Copyright (C) 2017-2018  Namhyung Kim <namhyung@gmail.com>
+ *
+ * Released under MIT license.
+ */
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+// a -> b -> c -> a and b -> d -> b make a cycle group, e needs itself
+func setCycleDeps() {
+	deps = map[string]DepsInfo{
+		"prog": {libs: []string{"a.so", "e.so", "f.so"}},
+		"a.so": {libs: []string{"b.so"}},
+		"b.so": {libs: []string{"c.so", "d.so"}},
+		"c.so": {libs: []string{"a.so", "f.so"}},
+		"d.so": {libs: []string{"b.so"}},
+		"e.so": {libs: []string{"e.so", "missing.so"}},
+		"f.so": {},
+	}
+}
+
+func TestFindSCC(t *testing.T) {
+	setCycleDeps()
+
+	want := [][]string{
+		{"a.so", "b.so", "c.so", "d.so"},
+		{"e.so"},
+	}
+	if got := findSCC(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	deps = map[string]DepsInfo{
+		"prog": {libs: []string{"a.so", "b.so"}},
+		"a.so": {libs: []string{"b.so"}},
+		"b.so": {},
+	}
+	if got := findSCC(); len(got) != 0 {
+		t.Errorf("got %v in acyclic deps", got)
+	}
+}
+
+func TestFindCycles(t *testing.T) {
+	setCycleDeps()
+
+	want := [][]string{
+		{"a.so", "b.so", "c.so"},
+		{"b.so", "d.so"},
+	}
+	got := findCycles([]string{"a.so", "b.so", "c.so", "d.so"})
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	want = [][]string{{"e.so"}}
+	if got := findCycles([]string{"e.so"}); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestIsBackEdge(t *testing.T) {
+	root := &DepsNode{name: "prog"}
+	a := &DepsNode{name: "a.so", parent: root, depth: 1}
+	b := &DepsNode{name: "b.so", parent: a, depth: 2}
+	back := &DepsNode{name: "a.so", parent: b, depth: 3}
+
+	if isBackEdge(b) {
+		t.Errorf("%s is not a back-edge", b.name)
+	}
+	if !isBackEdge(back) {
+		t.Errorf("%s is a back-edge", back.name)
+	}
+}
@@ -0,0 +1,1873 @@
+/*
+ * ELF tree - Tree viewer for ELF library dependency
+ *
+ * Copyright (C) 2017-2018  Namhyung Kim <namhyung@gmail.com>
+ *
+ * Released under MIT license.
+ */
+package main
+
+import (
+	"math"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// show demangled symbol names
+var demangleNames bool
+
+// returns demangled name if enabled (keeping @version)
+func symName(name string) string {
+	if !demangleNames {
+		return name
+	}
+	if i := strings.Index(name, "@"); i > 0 {
+		return demangle(name[:i]) + name[i:]
+	}
+	return demangle(name)
+}
+
+// demangle C++ (Itanium ABI) and Rust symbol names.
+// it returns the name as is when failed.
+func demangle(name string) string {
+	if strings.HasPrefix(name, "_R") {
+		if s, ok := demangleRust(name); ok {
+			return s
+		}
+		return name
+	}
+	if strings.HasPrefix(name, "_ZN") {
+		if s, ok := demangleRustLegacy(name); ok {
+			return s
+		}
+	}
+	if strings.HasPrefix(name, "_Z") {
+		if s, ok := demangleCxx(name); ok {
+			return s
+		}
+	}
+	return name
+}
+
+// error in demangling
+type demangleError struct{}
+
+//
+// Itanium C++ ABI
+//
+
+// cxxType prints itself as a declaration of the inner declarator
+type cxxType interface {
+	decl(inner string) string
+}
+
+type cxxName struct {
+	s string
+}
+
+type cxxQual struct {
+	t cxxType
+	q string // " const", " volatile", ...
+}
+
+type cxxPointer struct {
+	t  cxxType
+	op string // "*", "&" or "&&"
+}
+
+type cxxArray struct {
+	t   cxxType
+	dim string
+}
+
+type cxxFunc struct {
+	ret    cxxType // nil for non-template functions
+	params []cxxType
+	quals  string
+}
+
+type cxxMemPtr struct {
+	class cxxType
+	t     cxxType
+}
+
+type cxxPack struct {
+	t cxxType
+}
+
+// template argument pack
+type cxxArgs struct {
+	args []cxxType
+}
+
+func joinDecl(base, inner string) string {
+	if inner == "" {
+		return base
+	}
+	if c := inner[0]; c == '*' || c == '&' || c == ' ' {
+		return base + inner
+	}
+	return base + " " + inner
+}
+
+// space between the declarator and the name or parenthesis
+func spaceDecl(inner string) string {
+	if inner == "" {
+		return ""
+	}
+	if c := inner[0]; c == '*' || c == '&' || c == ' ' {
+		return inner
+	}
+	return " " + inner
+}
+
+func (n cxxName) decl(inner string) string {
+	return joinDecl(n.s, inner)
+}
+
+func (q *cxxQual) decl(inner string) string {
+	if t, ok := q.t.(*cxxQual); ok && t.q == q.q {
+		return t.decl(inner)
+	}
+	if a, ok := q.t.(*cxxArray); ok {
+		// qualifiers apply to the elements
+		return (&cxxArray{&cxxQual{a.t, q.q}, a.dim}).decl(inner)
+	}
+	if a, ok := q.t.(*cxxArgs); ok {
+		return a.each(func(t cxxType) cxxType { return &cxxQual{t, q.q} }).decl(inner)
+	}
+	if f, ok := q.t.(*cxxFunc); ok {
+		// cv-qualified function type (of a member function)
+		return (&cxxFunc{f.ret, f.params, f.quals + q.q}).decl(inner)
+	}
+	return q.t.decl(q.q + spaceDecl(inner))
+}
+
+func needParen(t cxxType) bool {
+	switch v := t.(type) {
+	case *cxxFunc, *cxxArray:
+		return true
+	case *cxxQual:
+		return needParen(v.t)
+	}
+	return false
+}
+
+func (p *cxxPointer) decl(inner string) string {
+	if a, ok := p.t.(*cxxArgs); ok {
+		return a.each(func(t cxxType) cxxType { return &cxxPointer{t, p.op} }).decl(inner)
+	}
+	// reference collapsing: only && to && remains rvalue reference
+	if r, ok := p.t.(*cxxPointer); ok && p.op != "*" && r.op != "*" {
+		if p.op == "&" {
+			return (&cxxPointer{r.t, "&"}).decl(inner)
+		}
+		return r.decl(inner)
+	}
+	if needParen(p.t) {
+		return p.t.decl("(" + p.op + inner + ")")
+	}
+	return p.t.decl(p.op + spaceDecl(inner))
+}
+
+func (a *cxxArray) decl(inner string) string {
+	if inner == "" {
+		return a.t.decl("[" + a.dim + "]")
+	}
+	if strings.HasSuffix(inner, "]") {
+		return a.t.decl(inner + "[" + a.dim + "]")
+	}
+	return a.t.decl(inner + " [" + a.dim + "]")
+}
+
+func cxxParams(params []cxxType) string {
+	if len(params) == 1 {
+		if n, ok := params[0].(cxxName); ok && n.s == "void" {
+			return "()"
+		}
+	}
+
+	var args []string
+	for _, t := range params {
+		if d := t.decl(""); d != "" {
+			args = append(args, d)
+		}
+	}
+	return "(" + strings.Join(args, ", ") + ")"
+}
+
+func (f *cxxFunc) decl(inner string) string {
+	s := inner + cxxParams(f.params) + f.quals
+	if f.ret == nil {
+		return s
+	}
+	return f.ret.decl(s)
+}
+
+func (m *cxxMemPtr) decl(inner string) string {
+	class := m.class.decl("")
+	if needParen(m.t) {
+		return m.t.decl("(" + class + "::*" + inner + ")")
+	}
+	return m.t.decl(class + "::*" + inner)
+}
+
+func (p *cxxPack) decl(inner string) string {
+	// expand the known pack
+	for t := p.t; ; {
+		switch v := t.(type) {
+		case *cxxArgs:
+			return p.t.decl(inner)
+		case *cxxPointer:
+			t = v.t
+			continue
+		case *cxxQual:
+			t = v.t
+			continue
+		}
+		break
+	}
+	return p.t.decl(inner) + "..."
+}
+
+func (a *cxxArgs) decl(inner string) string {
+	var args []string
+	for _, t := range a.args {
+		args = append(args, t.decl(inner))
+	}
+	return strings.Join(args, ", ")
+}
+
+// apply the type operator to each element in the pack
+func (a *cxxArgs) each(fn func(cxxType) cxxType) *cxxArgs {
+	var args []cxxType
+	for _, t := range a.args {
+		args = append(args, fn(t))
+	}
+	return &cxxArgs{args}
+}
+
+var cxxBuiltins = map[byte]string{
+	'v': "void", 'w': "wchar_t", 'b': "bool", 'c': "char",
+	'a': "signed char", 'h': "unsigned char", 's': "short",
+	't': "unsigned short", 'i': "int", 'j': "unsigned int",
+	'l': "long", 'm': "unsigned long", 'x': "long long",
+	'y': "unsigned long long", 'n': "__int128",
+	'o': "unsigned __int128", 'f': "float", 'd': "double",
+	'e': "long double", 'g': "__float128", 'z': "...",
+}
+
+var cxxBuiltinsD = map[byte]string{
+	'd': "decimal64", 'e': "decimal128", 'f': "decimal32",
+	'h': "half", 'i': "char32_t", 's': "char16_t", 'u': "char8_t",
+	'a': "auto", 'c': "decltype(auto)", 'n': "decltype(nullptr)",
+}
+
+var cxxOperators = map[string]string{
+	"nw": "new", "na": "new[]", "dl": "delete", "da": "delete[]",
+	"ps": "+", "ng": "-", "ad": "&", "de": "*", "co": "~",
+	"pl": "+", "mi": "-", "ml": "*", "dv": "/", "rm": "%",
+	"an": "&", "or": "|", "eo": "^", "aS": "=", "pL": "+=",
+	"mI": "-=", "mL": "*=", "dV": "/=", "rM": "%=", "aN": "&=",
+	"oR": "|=", "eO": "^=", "ls": "<<", "rs": ">>", "lS": "<<=",
+	"rS": ">>=", "eq": "==", "ne": "!=", "lt": "<", "gt": ">",
+	"le": "<=", "ge": ">=", "ss": "<=>", "nt": "!", "aa": "&&",
+	"oo": "||", "pp": "++", "mm": "--", "cm": ",", "pm": "->*",
+	"pt": "->", "cl": "()", "ix": "[]", "qu": "?",
+}
+
+// operators used as unary in expressions
+var cxxUnaryOps = map[string]bool{
+	"ps": true, "ng": true, "ad": true, "de": true, "co": true, "nt": true,
+}
+
+type cxxParser struct {
+	s    string
+	pos  int
+	subs []cxxType // substitution candidates
+	tmpl []cxxType // template arguments for T_
+}
+
+func demangleCxx(name string) (ret string, ok bool) {
+	defer func() {
+		if r := recover(); r != nil {
+			if _, ok := r.(demangleError); !ok {
+				panic(r)
+			}
+			ret, ok = name, false
+		}
+	}()
+
+	p := &cxxParser{s: name, pos: 2}
+	s := p.encoding()
+
+	// function clones by compiler: foo.constprop.0
+	for p.pos < len(p.s) && p.s[p.pos] == '.' {
+		end := p.pos + 1
+		for end < len(p.s) && p.s[end] != '.' {
+			end++
+		}
+		// numbers belong to the preceding clone suffix
+		for end+1 < len(p.s) && p.s[end] == '.' && isDigit(p.s[end+1]) {
+			end++
+			for end < len(p.s) && isDigit(p.s[end]) {
+				end++
+			}
+		}
+		s += " [clone " + p.s[p.pos:end] + "]"
+		p.pos = end
+	}
+
+	if p.pos != len(p.s) {
+		return name, false
+	}
+	return s, true
+}
+
+func isDigit(c byte) bool {
+	return '0' <= c && c <= '9'
+}
+
+func (p *cxxParser) fail() {
+	panic(demangleError{})
+}
+
+func (p *cxxParser) peek() byte {
+	if p.pos >= len(p.s) {
+		return 0
+	}
+	return p.s[p.pos]
+}
+
+func (p *cxxParser) peekAt(n int) byte {
+	if p.pos+n >= len(p.s) {
+		return 0
+	}
+	return p.s[p.pos+n]
+}
+
+func (p *cxxParser) next() byte {
+	if p.pos >= len(p.s) {
+		p.fail()
+	}
+	p.pos++
+	return p.s[p.pos-1]
+}
+
+func (p *cxxParser) consume(c byte) bool {
+	if p.peek() == c {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *cxxParser) expect(c byte) {
+	if !p.consume(c) {
+		p.fail()
+	}
+}
+
+func (p *cxxParser) hasPrefix(s string) bool {
+	return strings.HasPrefix(p.s[p.pos:], s)
+}
+
+// [n] <decimal>
+func (p *cxxParser) number() string {
+	start := p.pos
+	p.consume('n')
+	if !isDigit(p.peek()) {
+		p.fail()
+	}
+	for isDigit(p.peek()) {
+		p.pos++
+	}
+	return strings.Replace(p.s[start:p.pos], "n", "-", 1)
+}
+
+// [<number>] _ : returns -1 for absent number
+func (p *cxxParser) optNumber() int {
+	if p.consume('_') {
+		return -1
+	}
+	n, _ := strconv.Atoi(p.number())
+	p.expect('_')
+	return n
+}
+
+func (p *cxxParser) push(t cxxType) {
+	p.subs = append(p.subs, t)
+}
+
+// return type is omitted for the function of local names
+func (p *cxxParser) encoding() string {
+	return p.encodingRet(true)
+}
+
+func (p *cxxParser) encodingRet(showRet bool) string {
+	switch {
+	case p.peek() == 'T' || p.hasPrefix("GV") || p.hasPrefix("GR") || p.hasPrefix("GT"):
+		return p.specialName()
+	}
+
+	name, isTmpl, cdtor, quals := p.name(true)
+	if c := p.peek(); c == 0 || c == 'E' || c == '.' {
+		// variable or data
+		return name
+	}
+
+	f := &cxxFunc{quals: quals}
+	if isTmpl && !cdtor {
+		f.ret = p.typ()
+		if !showRet {
+			f.ret = nil
+		}
+	}
+	f.params = p.types()
+	return f.decl(name)
+}
+
+// types in the function parameters
+func (p *cxxParser) types() []cxxType {
+	var types []cxxType
+	for {
+		if c := p.peek(); c == 0 || c == 'E' || c == '.' {
+			break
+		}
+		types = append(types, p.typ())
+	}
+	if len(types) == 0 {
+		p.fail()
+	}
+	return types
+}
+
+// h <number> _ | v <number> _ <number> _
+func (p *cxxParser) callOffset() {
+	switch p.next() {
+	case 'h':
+		p.number()
+		p.expect('_')
+	case 'v':
+		p.number()
+		p.expect('_')
+		p.number()
+		p.expect('_')
+	default:
+		p.fail()
+	}
+}
+
+func (p *cxxParser) specialName() string {
+	switch {
+	case p.hasPrefix("TV"):
+		p.pos += 2
+		return "vtable for " + p.typ().decl("")
+	case p.hasPrefix("TT"):
+		p.pos += 2
+		return "VTT for " + p.typ().decl("")
+	case p.hasPrefix("TI"):
+		p.pos += 2
+		return "typeinfo for " + p.typ().decl("")
+	case p.hasPrefix("TS"):
+		p.pos += 2
+		return "typeinfo name for " + p.typ().decl("")
+	case p.hasPrefix("TA"):
+		p.pos += 2
+		return "template parameter object for " + p.templateArg().decl("")
+	case p.hasPrefix("Th"):
+		p.pos++
+		p.callOffset()
+		return "non-virtual thunk to " + p.encoding()
+	case p.hasPrefix("Tv"):
+		p.pos++
+		p.callOffset()
+		return "virtual thunk to " + p.encoding()
+	case p.hasPrefix("Tc"):
+		p.pos += 2
+		p.callOffset()
+		p.callOffset()
+		return "covariant return thunk to " + p.encoding()
+	case p.hasPrefix("TC"):
+		p.pos += 2
+		derived := p.typ()
+		p.number()
+		p.expect('_')
+		base := p.typ()
+		return "construction vtable for " + base.decl("") + "-in-" + derived.decl("")
+	case p.hasPrefix("TH"):
+		p.pos += 2
+		name, _, _, _ := p.name(false)
+		return "TLS init function for " + name
+	case p.hasPrefix("TW"):
+		p.pos += 2
+		name, _, _, _ := p.name(false)
+		return "TLS wrapper function for " + name
+	case p.hasPrefix("GV"):
+		p.pos += 2
+		name, _, _, _ := p.name(false)
+		return "guard variable for " + name
+	case p.hasPrefix("GR"):
+		p.pos += 2
+		name, _, _, _ := p.name(false)
+		seq := 0
+		if !p.consume('_') {
+			seq = p.seqId() + 1
+			p.expect('_')
+		}
+		return "reference temporary #" + strconv.Itoa(seq) + " for " + name
+	case p.hasPrefix("GTt") || p.hasPrefix("GTn"):
+		p.pos += 3
+		return "transaction clone for " + p.encoding()
+	}
+	p.fail()
+	return ""
+}
+
+// base-36 sequence id
+func (p *cxxParser) seqId() int {
+	n := 0
+	start := p.pos
+	for {
+		c := p.peek()
+		if isDigit(c) {
+			n = n*36 + int(c-'0')
+		} else if 'A' <= c && c <= 'Z' {
+			n = n*36 + int(c-'A') + 10
+		} else {
+			break
+		}
+		p.pos++
+	}
+	if p.pos == start {
+		p.fail()
+	}
+	return n
+}
+
+// returns name, whether it has template args, whether it's ctor/dtor/conversion
+// and cv/ref-qualifiers of the method
+func (p *cxxParser) name(top bool) (string, bool, bool, string) {
+	switch p.peek() {
+	case 'N':
+		return p.nestedName(top)
+	case 'Z':
+		return p.localName(top)
+	}
+
+	var s string
+	cdtor := false
+	isSub := false
+
+	if p.hasPrefix("St") {
+		p.pos += 2
+		s = "std::" + p.unqualified(&cdtor, "")
+	} else if p.peek() == 'S' {
+		t, _ := p.substitution()
+		if p.peek() != 'I' {
+			p.fail()
+		}
+		s = t.decl("")
+		isSub = true
+	} else {
+		p.consume('L') // internal linkage
+		s = p.unqualified(&cdtor, "")
+	}
+
+	if p.peek() == 'I' {
+		if !isSub {
+			p.push(cxxName{s})
+		}
+		s = withArgs(s, p.templateArgs(top))
+		return s, true, cdtor, ""
+	}
+	return s, false, cdtor, ""
+}
+
+func (p *cxxParser) cvQuals() string {
+	var q string
+	restrict := p.consume('r')
+	volatile := p.consume('V')
+	if p.consume('K') {
+		q += " const"
+	}
+	if volatile {
+		q += " volatile"
+	}
+	if restrict {
+		q += " restrict"
+	}
+	return q
+}
+
+// strip namespaces, template args and ABI tags
+func baseName(s string) string {
+	if strings.HasSuffix(s, ">") {
+		depth := 0
+		for i := len(s) - 1; i >= 0; i-- {
+			if s[i] == '>' {
+				depth++
+			} else if s[i] == '<' {
+				depth--
+				if depth == 0 {
+					s = s[:i]
+					break
+				}
+			}
+		}
+	}
+	if i := strings.LastIndex(s, "::"); i >= 0 {
+		s = s[i+2:]
+	}
+	if i := strings.Index(s, "[abi:"); i > 0 {
+		s = s[:i]
+	}
+	return s
+}
+
+func (p *cxxParser) nestedName(top bool) (string, bool, bool, string) {
+	p.expect('N')
+
+	quals := p.cvQuals()
+	if p.consume('R') {
+		quals += " &"
+	} else if p.consume('O') {
+		quals += " &&"
+	}
+
+	var s, last string
+	isTmpl, cdtor, pushed := false, false, false
+
+	for !p.consume('E') {
+		pushed = false
+
+		switch {
+		case p.peek() == 'I':
+			if s == "" {
+				p.fail()
+			}
+			s = withArgs(s, p.templateArgs(top))
+			isTmpl = true
+		case p.hasPrefix("St"):
+			p.pos += 2
+			s = "std"
+			continue
+		case p.peek() == 'S':
+			t, base := p.substitution()
+			s = t.decl("")
+			last = base
+			if last == "" {
+				last = baseName(s)
+			}
+			continue
+		case p.peek() == 'T':
+			s = p.templateParam().decl("")
+			last = baseName(s)
+		case p.hasPrefix("Dt") || p.hasPrefix("DT"):
+			s = p.typ().decl("")
+		case p.peek() == 'L' || p.peek() == 'M':
+			p.pos++
+			continue
+		default:
+			cd := false
+			comp := p.unqualified(&cd, last)
+			cdtor = cd
+			isTmpl = false
+			// unnamed types use the name of the enclosing class
+			if !cd && comp[0] != '{' {
+				last = baseName(comp)
+			}
+			if s == "" {
+				s = comp
+			} else {
+				s += "::" + comp
+			}
+		}
+
+		p.push(cxxName{s})
+		pushed = true
+	}
+
+	// the whole name is not a candidate here
+	if !pushed || s == "" {
+		p.fail()
+	}
+	p.subs = p.subs[:len(p.subs)-1]
+
+	return s, isTmpl, cdtor, quals
+}
+
+func (p *cxxParser) discriminator() {
+	if !p.consume('_') {
+		return
+	}
+	if p.consume('_') {
+		p.number()
+		p.expect('_')
+	} else {
+		p.number()
+	}
+}
+
+func (p *cxxParser) localName(top bool) (string, bool, bool, string) {
+	p.expect('Z')
+	enc := p.encodingRet(false)
+	p.expect('E')
+
+	if p.consume('s') {
+		p.discriminator()
+		return enc + "::string literal", false, false, ""
+	}
+	if p.consume('d') {
+		// default argument
+		enc += "::{default arg#" + strconv.Itoa(p.optNumber()+2) + "}"
+	}
+
+	name, isTmpl, cdtor, quals := p.name(top)
+	p.discriminator()
+	return enc + "::" + name, isTmpl, cdtor, quals
+}
+
+func (p *cxxParser) sourceName() string {
+	n, err := strconv.Atoi(p.number())
+	if err != nil || n <= 0 || p.pos+n > len(p.s) {
+		p.fail()
+	}
+	s := p.s[p.pos : p.pos+n]
+	p.pos += n
+
+	if strings.HasPrefix(s, "_GLOBAL_") && len(s) > 9 && s[9] == 'N' {
+		return "(anonymous namespace)"
+	}
+	return s
+}
+
+func (p *cxxParser) unqualified(cdtor *bool, last string) string {
+	var s string
+	c := p.peek()
+
+	switch {
+	case isDigit(c):
+		s = p.sourceName()
+	case c == 'C' && (isDigit(p.peekAt(1)) || p.peekAt(1) == 'I'):
+		p.pos++
+		if p.consume('I') {
+			// inheriting constructor
+			p.next()
+			p.typ()
+		} else {
+			p.next()
+		}
+		if last == "" {
+			p.fail()
+		}
+		*cdtor = true
+		s = last
+	case c == 'D' && isDigit(p.peekAt(1)):
+		p.pos += 2
+		if last == "" {
+			p.fail()
+		}
+		*cdtor = true
+		s = "~" + last
+	case c == 'D' && p.peekAt(1) == 'C':
+		// structured binding
+		p.pos += 2
+		var names []string
+		for !p.consume('E') {
+			names = append(names, p.sourceName())
+		}
+		s = "[" + strings.Join(names, ", ") + "]"
+	case p.hasPrefix("Ut"):
+		p.pos += 2
+		s = "{unnamed type#" + strconv.Itoa(p.optNumber()+2) + "}"
+	case p.hasPrefix("Ul"):
+		p.pos += 2
+		params := p.types()
+		p.expect('E')
+		s = "{lambda" + cxxParams(params) + "#" + strconv.Itoa(p.optNumber()+2) + "}"
+	case 'a' <= c && c <= 'z':
+		s = p.operatorName(cdtor)
+	default:
+		p.fail()
+	}
+
+	// ABI tags
+	for p.consume('B') {
+		s += "[abi:" + p.sourceName() + "]"
+	}
+	return s
+}
+
+func (p *cxxParser) operatorName(cdtor *bool) string {
+	if p.pos+2 > len(p.s) {
+		p.fail()
+	}
+	op := p.s[p.pos : p.pos+2]
+	p.pos += 2
+
+	switch {
+	case op == "cv":
+		*cdtor = true
+		return "operator " + p.typ().decl("")
+	case op == "li":
+		return "operator\"\" " + p.sourceName()
+	case op[0] == 'v' && isDigit(op[1]):
+		return "operator " + p.sourceName()
+	}
+
+	name, ok := cxxOperators[op]
+	if !ok {
+		p.fail()
+	}
+	if name[0] >= 'a' && name[0] <= 'z' {
+		return "operator " + name
+	}
+	return "operator" + name
+}
+
+// returns the type and its base name for ctors
+func (p *cxxParser) substitution() (cxxType, string) {
+	p.expect('S')
+
+	switch p.next() {
+	case 'a':
+		return cxxName{"std::allocator"}, "allocator"
+	case 'b':
+		return cxxName{"std::basic_string"}, "basic_string"
+	case 's':
+		return cxxName{"std::basic_string<char, std::char_traits<char>, std::allocator<char> >"}, "basic_string"
+	case 'i':
+		return cxxName{"std::basic_istream<char, std::char_traits<char> >"}, "basic_istream"
+	case 'o':
+		return cxxName{"std::basic_ostream<char, std::char_traits<char> >"}, "basic_ostream"
+	case 'd':
+		return cxxName{"std::basic_iostream<char, std::char_traits<char> >"}, "basic_iostream"
+	case '_':
+		if len(p.subs) == 0 {
+			p.fail()
+		}
+		return p.subs[0], ""
+	}
+
+	p.pos--
+	i := p.seqId() + 1
+	p.expect('_')
+	if i >= len(p.subs) {
+		p.fail()
+	}
+	return p.subs[i], ""
+}
+
+func (p *cxxParser) templateParam() cxxType {
+	p.expect('T')
+	i := p.optNumber() + 1
+	if i < len(p.tmpl) {
+		return p.tmpl[i]
+	}
+	// not known yet (e.g. in a conversion operator)
+	return cxxName{"auto"}
+}
+
+func (p *cxxParser) templateArgs(top bool) string {
+	p.expect('I')
+
+	var args []cxxType
+	for !p.consume('E') {
+		args = append(args, p.templateArg())
+	}
+	if top {
+		p.tmpl = args
+	}
+
+	var strs []string
+	for _, a := range args {
+		if d := a.decl(""); d != "" {
+			strs = append(strs, d)
+		}
+	}
+
+	s := "<" + strings.Join(strs, ", ")
+	if strings.HasSuffix(s, ">") {
+		s += " "
+	}
+	return s + ">"
+}
+
+// avoid "<<<" for operator templates
+func withArgs(name, args string) string {
+	if strings.HasSuffix(name, "<") {
+		return name + " " + args
+	}
+	return name + args
+}
+
+func (p *cxxParser) templateArg() cxxType {
+	switch p.peek() {
+	case 'L':
+		return cxxName{p.literal()}
+	case 'X':
+		p.pos++
+		e := p.expr()
+		p.expect('E')
+		return cxxName{e}
+	case 'J':
+		p.pos++
+		var args []cxxType
+		for !p.consume('E') {
+			args = append(args, p.templateArg())
+		}
+		return &cxxArgs{args}
+	}
+	return p.typ()
+}
+
+func (p *cxxParser) literal() string {
+	p.expect('L')
+
+	if p.hasPrefix("_Z") {
+		p.pos += 2
+		s := p.encoding()
+		p.expect('E')
+		return s
+	}
+
+	t := p.typ().decl("")
+
+	start := p.pos
+	for p.peek() != 'E' {
+		p.next()
+	}
+	val := strings.Replace(p.s[start:p.pos], "n", "-", 1)
+	p.pos++
+
+	switch t {
+	case "bool":
+		if val == "0" {
+			return "false"
+		}
+		return "true"
+	case "int":
+		return val
+	case "unsigned int":
+		return val + "u"
+	case "long":
+		return val + "l"
+	case "unsigned long":
+		return val + "ul"
+	case "long long":
+		return val + "ll"
+	case "unsigned long long":
+		return val + "ull"
+	case "decltype(nullptr)":
+		if val == "" || val == "0" {
+			return "nullptr"
+		}
+	}
+	return "(" + t + ")" + val
+}
+
+func (p *cxxParser) expr() string {
+	switch {
+	case p.peek() == 'L':
+		return p.literal()
+	case p.peek() == 'T':
+		return p.templateParam().decl("")
+	case p.hasPrefix("fp") || p.hasPrefix("fL"):
+		p.pos += 2
+		if p.s[p.pos-1] == 'L' {
+			p.number()
+			p.expect('p')
+		}
+		p.cvQuals()
+		return "{parm#" + strconv.Itoa(p.optNumber()+2) + "}"
+	case p.hasPrefix("gs"):
+		p.pos += 2
+		return "::" + p.expr()
+	case p.hasPrefix("sr"):
+		p.pos += 2
+		return p.unresolvedName()
+	case p.hasPrefix("st"):
+		p.pos += 2
+		return "sizeof (" + p.typ().decl("") + ")"
+	case p.hasPrefix("sz"):
+		p.pos += 2
+		return "sizeof (" + p.expr() + ")"
+	case p.hasPrefix("at"):
+		p.pos += 2
+		return "alignof (" + p.typ().decl("") + ")"
+	case p.hasPrefix("az"):
+		p.pos += 2
+		return "alignof (" + p.expr() + ")"
+	case p.hasPrefix("cl"):
+		p.pos += 2
+		fn := p.expr()
+		var args []string
+		for !p.consume('E') {
+			args = append(args, p.expr())
+		}
+		return fn + "(" + strings.Join(args, ", ") + ")"
+	case p.hasPrefix("cv"):
+		p.pos += 2
+		t := p.typ().decl("")
+		if p.consume('_') {
+			var args []string
+			for !p.consume('E') {
+				args = append(args, p.expr())
+			}
+			return "(" + t + ")(" + strings.Join(args, ", ") + ")"
+		}
+		return "(" + t + ")(" + p.expr() + ")"
+	case p.hasPrefix("dt") || p.hasPrefix("pt"):
+		op := "."
+		if p.peek() == 'p' {
+			op = "->"
+		}
+		p.pos += 2
+		e := p.expr()
+		cd := false
+		return e + op + p.unqualified(&cd, "")
+	case p.hasPrefix("qu"):
+		p.pos += 2
+		a := p.expr()
+		b := p.expr()
+		c := p.expr()
+		return "(" + a + ") ? (" + b + ") : (" + c + ")"
+	case p.hasPrefix("pp_") || p.hasPrefix("mm_"):
+		op := cxxOperators[p.s[p.pos:p.pos+2]]
+		p.pos += 3
+		return op + "(" + p.expr() + ")"
+	case isDigit(p.peek()):
+		cd := false
+		return p.unqualified(&cd, "")
+	}
+
+	if p.pos+2 > len(p.s) {
+		p.fail()
+	}
+	op := p.s[p.pos : p.pos+2]
+	name, ok := cxxOperators[op]
+	if !ok || op == "cl" || op == "cv" || op == "qu" {
+		p.fail()
+	}
+	p.pos += 2
+
+	if cxxUnaryOps[op] {
+		return name + "(" + p.expr() + ")"
+	}
+	a := p.expr()
+	b := p.expr()
+	return "(" + a + ")" + name + "(" + b + ")"
+}
+
+// sr N <type> <level>+ E <base> | sr <level>+ E <base> | sr <type> <base>
+func (p *cxxParser) unresolvedName() string {
+	var s string
+
+	switch {
+	case p.consume('N'):
+		s = p.typ().decl("")
+		for !p.consume('E') {
+			s += "::" + p.simpleId()
+		}
+	case isDigit(p.peek()):
+		s = p.simpleId()
+		for !p.consume('E') {
+			s += "::" + p.simpleId()
+		}
+	default:
+		s = p.typ().decl("")
+	}
+	return s + "::" + p.baseUnresolvedName()
+}
+
+// <source-name> [<template-args>]
+func (p *cxxParser) simpleId() string {
+	s := p.sourceName()
+	if p.peek() == 'I' {
+		s = withArgs(s, p.templateArgs(false))
+	}
+	return s
+}
+
+func (p *cxxParser) baseUnresolvedName() string {
+	switch {
+	case isDigit(p.peek()):
+		return p.simpleId()
+	case p.hasPrefix("on"):
+		p.pos += 2
+		cd := false
+		s := p.operatorName(&cd)
+		if p.peek() == 'I' {
+			s = withArgs(s, p.templateArgs(false))
+		}
+		return s
+	case p.hasPrefix("dn"):
+		p.pos += 2
+		if isDigit(p.peek()) {
+			return "~" + p.simpleId()
+		}
+		return "~" + p.typ().decl("")
+	}
+	p.fail()
+	return ""
+}
+
+func (p *cxxParser) functionType(quals string) cxxType {
+	p.expect('F')
+	p.consume('Y') // extern "C"
+
+	f := &cxxFunc{quals: quals}
+	f.ret = p.typ()
+
+	for !p.consume('E') {
+		if (p.peek() == 'R' || p.peek() == 'O') && p.peekAt(1) == 'E' {
+			if p.next() == 'R' {
+				f.quals += " &"
+			} else {
+				f.quals += " &&"
+			}
+			continue
+		}
+		f.params = append(f.params, p.typ())
+	}
+	return f
+}
+
+func (p *cxxParser) typ() cxxType {
+	c := p.peek()
+
+	if b, ok := cxxBuiltins[c]; ok {
+		p.pos++
+		return cxxName{b}
+	}
+
+	var t cxxType
+
+	switch c {
+	case 'D':
+		d := p.peekAt(1)
+		if b, ok := cxxBuiltinsD[d]; ok {
+			p.pos += 2
+			return cxxName{b}
+		}
+
+		switch d {
+		case 'F':
+			p.pos += 2
+			n := p.number()
+			p.expect('_')
+			return cxxName{"_Float" + n}
+		case 'p':
+			p.pos += 2
+			t = &cxxPack{p.typ()}
+		case 't', 'T':
+			p.pos += 2
+			t = cxxName{"decltype (" + p.expr() + ")"}
+			p.expect('E')
+		case 'v':
+			p.pos += 2
+			n := p.number()
+			p.expect('_')
+			t = cxxName{p.typ().decl("") + " __vector(" + n + ")"}
+		case 'o':
+			p.pos += 2
+			t = p.functionType(" noexcept")
+		case 'O':
+			p.pos += 2
+			e := p.expr()
+			p.expect('E')
+			t = p.functionType(" noexcept(" + e + ")")
+		case 'w':
+			p.pos += 2
+			var types []string
+			for !p.consume('E') {
+				types = append(types, p.typ().decl(""))
+			}
+			t = p.functionType(" throw(" + strings.Join(types, ", ") + ")")
+		case 'x':
+			p.pos += 2
+			t = p.functionType(" transaction_safe")
+		default:
+			p.fail()
+		}
+	case 'r', 'V', 'K':
+		q := p.cvQuals()
+		t = &cxxQual{p.typ(), q}
+	case 'P':
+		p.pos++
+		t = &cxxPointer{p.typ(), "*"}
+	case 'R':
+		p.pos++
+		t = &cxxPointer{p.typ(), "&"}
+	case 'O':
+		p.pos++
+		t = &cxxPointer{p.typ(), "&&"}
+	case 'C':
+		p.pos++
+		t = cxxName{p.typ().decl("") + " _Complex"}
+	case 'G':
+		p.pos++
+		t = cxxName{p.typ().decl("") + " _Imaginary"}
+	case 'F':
+		t = p.functionType("")
+	case 'A':
+		p.pos++
+		var dim string
+		if isDigit(p.peek()) {
+			dim = p.number()
+		} else if p.peek() != '_' {
+			dim = p.expr()
+		}
+		p.expect('_')
+		t = &cxxArray{p.typ(), dim}
+	case 'M':
+		p.pos++
+		class := p.typ()
+		t = &cxxMemPtr{class, p.typ()}
+	case 'T':
+		t = p.templateParam()
+		if p.peek() == 'I' {
+			p.push(t)
+			t = cxxName{t.decl("") + p.templateArgs(false)}
+		}
+	case 'S':
+		if p.hasPrefix("St") {
+			name, _, _, _ := p.name(false)
+			t = cxxName{name}
+			break
+		}
+		sub, _ := p.substitution()
+		if p.peek() != 'I' {
+			// no new candidate
+			return sub
+		}
+		t = cxxName{sub.decl("") + p.templateArgs(false)}
+	case 'u':
+		p.pos++
+		t = cxxName{p.sourceName()}
+	case 'U':
+		// vendor extended qualifier
+		p.pos++
+		q := p.sourceName()
+		if p.peek() == 'I' {
+			q += p.templateArgs(false)
+		}
+		t = &cxxQual{p.typ(), " " + q}
+	case 'N', 'Z':
+		name, _, _, _ := p.name(false)
+		t = cxxName{name}
+	default:
+		if !isDigit(c) {
+			p.fail()
+		}
+		name, _, _, _ := p.name(false)
+		t = cxxName{name}
+	}
+
+	p.push(t)
+	return t
+}
+
+//
+// Rust legacy mangling: _ZN...17h<hash>E
+//
+
+var rustEscapes = map[string]string{
+	"SP": "@", "BP": "*", "RF": "&", "LT": "<", "GT": ">",
+	"LP": "(", "RP": ")", "C": ",",
+}
+
+func rustUnescape(s string) (string, bool) {
+	if strings.HasPrefix(s, "_$") {
+		s = s[1:]
+	}
+
+	var out strings.Builder
+	for len(s) > 0 {
+		switch {
+		case s[0] == '$':
+			end := strings.IndexByte(s[1:], '$')
+			if end < 0 {
+				return "", false
+			}
+			esc := s[1 : end+1]
+			s = s[end+2:]
+
+			if r, ok := rustEscapes[esc]; ok {
+				out.WriteString(r)
+			} else if strings.HasPrefix(esc, "u") {
+				v, err := strconv.ParseUint(esc[1:], 16, 32)
+				if err != nil {
+					return "", false
+				}
+				out.WriteRune(rune(v))
+			} else {
+				return "", false
+			}
+		case strings.HasPrefix(s, ".."):
+			out.WriteString("::")
+			s = s[2:]
+		default:
+			out.WriteByte(s[0])
+			s = s[1:]
+		}
+	}
+	return out.String(), true
+}
+
+func isRustHash(s string) bool {
+	if len(s) != 17 || s[0] != 'h' {
+		return false
+	}
+	for _, c := range s[1:] {
+		if !strings.ContainsRune("0123456789abcdef", c) {
+			return false
+		}
+	}
+	return true
+}
+
+func demangleRustLegacy(name string) (string, bool) {
+	var parts []string
+
+	s := name[3:]
+	for len(s) > 0 && s[0] != 'E' {
+		i := 0
+		for i < len(s) && isDigit(s[i]) {
+			i++
+		}
+		n, err := strconv.Atoi(s[:i])
+		if err != nil || i+n > len(s) {
+			return "", false
+		}
+		parts = append(parts, s[i:i+n])
+		s = s[i+n:]
+	}
+
+	// ignore LLVM suffix like .llvm.1234
+	if s != "E" && !strings.HasPrefix(s, "E.") {
+		return "", false
+	}
+	if len(parts) < 2 || !isRustHash(parts[len(parts)-1]) {
+		return "", false
+	}
+	parts = parts[:len(parts)-1]
+
+	for i, p := range parts {
+		u, ok := rustUnescape(p)
+		if !ok {
+			return "", false
+		}
+		parts[i] = u
+	}
+	return strings.Join(parts, "::"), true
+}
+
+//
+// Rust v0 mangling: _R...
+//
+
+var rustBasicTypes = map[byte]string{
+	'a': "i8", 'b': "bool", 'c': "char", 'd': "f64", 'e': "str",
+	'f': "f32", 'h': "u8", 'i': "isize", 'j': "usize", 'l': "i32",
+	'm': "u32", 'n': "i128", 'o': "u128", 's': "i16", 't': "u16",
+	'u': "()", 'v': "...", 'x': "i64", 'y': "u64", 'z': "!", 'p': "_",
+}
+
+type rustParser struct {
+	s     string
+	pos   int
+	depth int
+	bound int // number of bound lifetimes
+}
+
+func demangleRust(name string) (ret string, ok bool) {
+	defer func() {
+		if r := recover(); r != nil {
+			if _, ok := r.(demangleError); !ok {
+				panic(r)
+			}
+			ret, ok = name, false
+		}
+	}()
+
+	// backrefs are relative to the position after _R
+	p := &rustParser{s: name[2:]}
+
+	// encoding version
+	for isDigit(p.peek()) {
+		p.pos++
+	}
+
+	s := p.path(true)
+
+	// skip instantiating crate and vendor-specific suffix
+	if c := p.peek(); c != 0 && c != '.' && c != '$' {
+		p.path(false)
+	}
+	return s, true
+}
+
+func (p *rustParser) fail() {
+	panic(demangleError{})
+}
+
+func (p *rustParser) peek() byte {
+	if p.pos >= len(p.s) {
+		return 0
+	}
+	return p.s[p.pos]
+}
+
+func (p *rustParser) next() byte {
+	if p.pos >= len(p.s) {
+		p.fail()
+	}
+	p.pos++
+	return p.s[p.pos-1]
+}
+
+func (p *rustParser) consume(c byte) bool {
+	if p.peek() == c {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+// {0-9a-zA-Z} _ : "_" is 0
+func (p *rustParser) base62() uint64 {
+	if p.consume('_') {
+		return 0
+	}
+
+	var n uint64
+	for {
+		c := p.next()
+
+		var d uint64
+		switch {
+		case c == '_':
+			if n == math.MaxUint64 {
+				p.fail()
+			}
+			return n + 1
+		case isDigit(c):
+			d = uint64(c - '0')
+		case 'a' <= c && c <= 'z':
+			d = uint64(c-'a') + 10
+		case 'A' <= c && c <= 'Z':
+			d = uint64(c-'A') + 36
+		default:
+			p.fail()
+		}
+
+		if n > (math.MaxUint64-d)/62 {
+			p.fail() // overflow
+		}
+		n = n*62 + d
+	}
+}
+
+func (p *rustParser) decimal() int {
+	start := p.pos
+	if p.consume('0') {
+		return 0
+	}
+	for isDigit(p.peek()) {
+		p.pos++
+	}
+	n, err := strconv.Atoi(p.s[start:p.pos])
+	if err != nil {
+		p.fail()
+	}
+	return n
+}
+
+func (p *rustParser) disambiguator() uint64 {
+	if p.consume('s') {
+		return p.base62()
+	}
+	return 0
+}
+
+func (p *rustParser) ident() string {
+	puny := p.consume('u')
+	n := p.decimal()
+	p.consume('_')
+
+	if n > len(p.s)-p.pos {
+		p.fail()
+	}
+	s := p.s[p.pos : p.pos+n]
+	p.pos += n
+
+	if puny {
+		d, ok := punycodeDecode(s)
+		if !ok {
+			p.fail()
+		}
+		return d
+	}
+	return s
+}
+
+// run fn at the backref position
+func (p *rustParser) backref(fn func() string) string {
+	start := p.pos - 1
+	i := p.base62()
+	if start < 0 || i >= uint64(start) {
+		p.fail()
+	}
+
+	saved := p.pos
+	p.pos = int(i)
+	s := fn()
+	p.pos = saved
+	return s
+}
+
+func (p *rustParser) enter() {
+	p.depth++
+	if p.depth > 200 {
+		p.fail()
+	}
+}
+
+func (p *rustParser) path(value bool) string {
+	p.enter()
+	defer func() { p.depth-- }()
+
+	switch p.next() {
+	case 'C':
+		p.disambiguator()
+		return p.ident()
+	case 'N':
+		ns := p.next()
+		prefix := p.path(value)
+		dis := p.disambiguator()
+		name := p.ident()
+
+		if 'A' <= ns && ns <= 'Z' {
+			kind := string(ns)
+			switch ns {
+			case 'C':
+				kind = "closure"
+			case 'S':
+				kind = "shim"
+			}
+			if name != "" {
+				kind += ":" + name
+			}
+			return prefix + "::{" + kind + "#" + strconv.FormatUint(dis, 10) + "}"
+		}
+		if name == "" {
+			return prefix
+		}
+		return prefix + "::" + name
+	case 'M':
+		p.disambiguator()
+		p.path(false) // impl path is not shown
+		return "<" + p.typ() + ">"
+	case 'X':
+		p.disambiguator()
+		p.path(false)
+		t := p.typ()
+		return "<" + t + " as " + p.path(false) + ">"
+	case 'Y':
+		t := p.typ()
+		return "<" + t + " as " + p.path(false) + ">"
+	case 'I':
+		s := p.path(value)
+		var args []string
+		for !p.consume('E') {
+			args = append(args, p.genericArg())
+		}
+		if value {
+			s += "::"
+		}
+		return s + "<" + strings.Join(args, ", ") + ">"
+	case 'B':
+		return p.backref(func() string { return p.path(value) })
+	}
+	p.fail()
+	return ""
+}
+
+func (p *rustParser) genericArg() string {
+	if p.consume('L') {
+		return p.lifetime(p.base62())
+	}
+	if p.consume('K') {
+		return p.constant()
+	}
+	return p.typ()
+}
+
+func (p *rustParser) lifetime(i uint64) string {
+	if i == 0 {
+		return "'_"
+	}
+	if i > uint64(p.bound) {
+		p.fail()
+	}
+	depth := p.bound - int(i)
+	if depth < 26 {
+		return "'" + string(rune('a'+depth))
+	}
+	return "'_" + strconv.Itoa(depth)
+}
+
+// G <base-62-number> : for<'a, 'b>
+func (p *rustParser) binder() string {
+	if !p.consume('G') {
+		return ""
+	}
+	// each lifetime takes at least a byte to be used
+	n := p.base62()
+	if n >= uint64(len(p.s)) {
+		p.fail()
+	}
+	n++
+
+	var names []string
+	for i := uint64(0); i < n; i++ {
+		p.bound++
+		names = append(names, p.lifetime(1))
+	}
+	return "for<" + strings.Join(names, ", ") + "> "
+}
+
+func (p *rustParser) typ() string {
+	p.enter()
+	defer func() { p.depth-- }()
+
+	c := p.next()
+	if b, ok := rustBasicTypes[c]; ok {
+		return b
+	}
+
+	switch c {
+	case 'A':
+		t := p.typ()
+		return "[" + t + "; " + p.constant() + "]"
+	case 'S':
+		return "[" + p.typ() + "]"
+	case 'T':
+		var types []string
+		for !p.consume('E') {
+			types = append(types, p.typ())
+		}
+		if len(types) == 1 {
+			return "(" + types[0] + ",)"
+		}
+		return "(" + strings.Join(types, ", ") + ")"
+	case 'R', 'Q':
+		s := "&"
+		if p.consume('L') {
+			if lt := p.lifetime(p.base62()); lt != "'_" {
+				s += lt + " "
+			}
+		}
+		if c == 'Q' {
+			s += "mut "
+		}
+		return s + p.typ()
+	case 'P':
+		return "*const " + p.typ()
+	case 'O':
+		return "*mut " + p.typ()
+	case 'F':
+		saved := p.bound
+		s := p.binder()
+		if p.consume('U') {
+			s += "unsafe "
+		}
+		if p.consume('K') {
+			abi := "C"
+			if !p.consume('C') {
+				abi = strings.Replace(p.ident(), "_", "-", -1)
+			}
+			s += "extern \"" + abi + "\" "
+		}
+		var params []string
+		for !p.consume('E') {
+			params = append(params, p.typ())
+		}
+		s += "fn(" + strings.Join(params, ", ") + ")"
+		if ret := p.typ(); ret != "()" {
+			s += " -> " + ret
+		}
+		p.bound = saved
+		return s
+	case 'D':
+		saved := p.bound
+		s := p.binder() + "dyn "
+		var traits []string
+		for !p.consume('E') {
+			traits = append(traits, p.dynTrait())
+		}
+		s += strings.Join(traits, " + ")
+		if !p.consume('L') {
+			p.fail()
+		}
+		if lt := p.lifetime(p.base62()); lt != "'_" {
+			s += " + " + lt
+		}
+		p.bound = saved
+		return s
+	case 'B':
+		return p.backref(p.typ)
+	}
+
+	// named types
+	p.pos--
+	return p.path(false)
+}
+
+func (p *rustParser) dynTrait() string {
+	s := p.path(false)
+
+	var bindings []string
+	for p.consume('p') {
+		name := p.ident()
+		bindings = append(bindings, name+" = "+p.typ())
+	}
+	if len(bindings) == 0 {
+		return s
+	}
+
+	if strings.HasSuffix(s, ">") {
+		return s[:len(s)-1] + ", " + strings.Join(bindings, ", ") + ">"
+	}
+	return s + "<" + strings.Join(bindings, ", ") + ">"
+}
+
+func (p *rustParser) constant() string {
+	p.enter()
+	defer func() { p.depth-- }()
+
+	c := p.next()
+	switch c {
+	case 'p':
+		return "_"
+	case 'B':
+		return p.backref(p.constant)
+	}
+
+	neg := p.consume('n')
+	start := p.pos
+	for p.peek() != '_' {
+		p.next()
+	}
+	hex := p.s[start:p.pos]
+	p.pos++
+
+	var v uint64
+	if hex != "" {
+		var err error
+		v, err = strconv.ParseUint(hex, 16, 64)
+		if err != nil {
+			return "0x" + hex // too big
+		}
+	}
+
+	switch c {
+	case 'b':
+		if v == 0 {
+			return "false"
+		}
+		return "true"
+	case 'c':
+		if !utf8.ValidRune(rune(v)) {
+			p.fail()
+		}
+		return strconv.QuoteRune(rune(v))
+	case 'a', 'h', 'i', 'j', 'l', 'm', 'n', 'o', 's', 't', 'x', 'y':
+		s := strconv.FormatUint(v, 10)
+		if neg {
+			s = "-" + s
+		}
+		return s
+	}
+	p.fail()
+	return ""
+}
+
+// RFC 3492 with '_' as the delimiter
+func punycodeDecode(s string) (string, bool) {
+	const (
+		base  = 36
+		tmin  = 1
+		tmax  = 26
+		skew  = 38
+		damp  = 700
+		ibias = 72
+		in    = 128
+	)
+
+	var out []rune
+	if i := strings.LastIndexByte(s, '_'); i >= 0 {
+		out = []rune(s[:i])
+		s = s[i+1:]
+	}
+
+	adapt := func(delta, numpoints int, first bool) int {
+		if first {
+			delta /= damp
+		} else {
+			delta /= 2
+		}
+		delta += delta / numpoints
+		k := 0
+		for delta > ((base-tmin)*tmax)/2 {
+			delta /= base - tmin
+			k += base
+		}
+		return k + (base-tmin+1)*delta/(delta+skew)
+	}
+
+	n, i, bias := in, 0, ibias
+	for len(s) > 0 {
+		oldi, w := i, 1
+		for k := base; ; k += base {
+			if len(s) == 0 {
+				return "", false
+			}
+			c := s[0]
+			s = s[1:]
+
+			var digit int
+			switch {
+			case 'a' <= c && c <= 'z':
+				digit = int(c - 'a')
+			case '0' <= c && c <= '9':
+				digit = int(c-'0') + 26
+			default:
+				return "", false
+			}
+
+			i += digit * w
+			t := k - bias
+			if t < tmin {
+				t = tmin
+			} else if t > tmax {
+				t = tmax
+			}
+			if digit < t {
+				break
+			}
+			w *= base - t
+		}
+
+		bias = adapt(i-oldi, len(out)+1, oldi == 0)
+		n += i / (len(out) + 1)
+		i %= len(out) + 1
+
+		out = append(out[:i], append([]rune{rune(n)}, out[i:]...)...)
+		i++
+	}
+	return string(out), true
+}
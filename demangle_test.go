@@ -0,0 +1,145 @@
+/*
+ * ELF tree - Tree viewer for ELF library dependency
+ *
+ * Copyright (C) 2017-2018  Namhyung Kim <namhyung@gmail.com>
+ *
+ * Released under MIT license.
+ */
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// expected results are from c++filt (binutils 2.40)
+var cxxTests = []struct {
+	name string
+	want string
+}{
+	{"_Z3foov", "foo()"},
+	{"_Z3fooi", "foo(int)"},
+	{"_ZN3foo3barEv", "foo::bar()"},
+	{"_ZNK3foo3barEv", "foo::bar() const"},
+	{"_ZN3foo3barC1Ev", "foo::bar::bar()"},
+	{"_ZN3foo3barD2Ev", "foo::bar::~bar()"},
+	{"_ZNSt6vectorIiSaIiEE9push_backERKi", "std::vector<int, std::allocator<int> >::push_back(int const&)"},
+	{"_ZNSt7__cxx1112basic_stringIcSt11char_traitsIcESaIcEEC1EPKcRKS3_",
+		"std::__cxx11::basic_string<char, std::char_traits<char>, std::allocator<char> >::basic_string(char const*, std::allocator<char> const&)"},
+	{"_Z1fIiEvT_", "void f<int>(int)"},
+	{"_Z3maxIiET_S0_S0_", "int max<int>(int, int)"},
+	{"_ZplRK1AS1_", "operator+(A const&, A const&)"},
+	{"_ZN1AcvPKcEv", "A::operator char const*()"},
+	{"_Z1fPFviE", "f(void (*)(int))"},
+	{"_Z1fA10_i", "f(int [10])"},
+	{"_Z1fM1AFivE", "f(int (A::*)())"},
+	{"_Z1fRA3_i", "f(int (&) [3])"},
+	{"_Z1fIJidEEvDpT_", "void f<int, double>(int, double)"},
+	{"_ZTV3foo", "vtable for foo"},
+	{"_ZTI3foo", "typeinfo for foo"},
+	{"_ZTS3foo", "typeinfo name for foo"},
+	{"_ZThn8_N3foo3barEv", "non-virtual thunk to foo::bar()"},
+	{"_ZGVZ1fvE1x", "guard variable for f()::x"},
+	{"_ZZ4mainE5count", "main::count"},
+	{"_Z1fSt9nullptr_t", "f(std::nullptr_t)"},
+	{"_ZN12_GLOBAL__N_13fooEv", "(anonymous namespace)::foo()"},
+	{"_Z1fILi3EEvv", "void f<3>()"},
+	{"_Z1fPKcz", "f(char const*, ...)"},
+	{"_Z1fDn", "f(decltype(nullptr))"},
+	{"_ZN9__gnu_cxx13new_allocatorIcED2Ev", "__gnu_cxx::new_allocator<char>::~new_allocator()"},
+	{"_ZNSt8ios_base4InitC1Ev", "std::ios_base::Init::Init()"},
+	{"_ZdlPvm", "operator delete(void*, unsigned long)"},
+	{"_Znwm", "operator new(unsigned long)"},
+	{"_ZNKSt5ctypeIcE8do_widenEc", "std::ctype<char>::do_widen(char) const"},
+
+	// invalid names are kept as is
+	{"notmangled", "notmangled"},
+	{"_Z", "_Z"},
+	{"_ZN3foo", "_ZN3foo"},
+}
+
+// same as c++filt but hashes and crate disambiguators are omitted
+var rustTests = []struct {
+	name string
+	want string
+}{
+	{"_ZN4core3ptr85drop_in_place$LT$std..rt..lang_start$LT$$LP$$RP$$GT$..$u7b$$u7b$closure$u7d$$u7d$$GT$17h0123456789abcdefE",
+		"core::ptr::drop_in_place<std::rt::lang_start<()>::{{closure}}>"},
+	{"_RNvCs1234_7mycrate3foo", "mycrate::foo"},
+	{"_RNvMs_NtCs1234_7mycrate3barNtB4_3Foo3new", "<mycrate::bar::Foo>::new"},
+	{"_RINvCs1234_7mycrate3fooiEB2_", "mycrate::foo::<isize>"},
+	{"_RNvNtCs1234_7mycrate3bar3baz", "mycrate::bar::baz"},
+	{"_RNvXCs1234_7mycrateNtB2_3FooNtNtCsfoo_4core3fmt7Display3fmt", "<mycrate::Foo as core::fmt::Display>::fmt"},
+	{"_RINvCs1234_7mycrate3fooRshEB2_", "mycrate::foo::<&i16, u8>"},
+}
+
+func TestDemangleCxx(t *testing.T) {
+	for _, tt := range cxxTests {
+		if got := demangle(tt.name); got != tt.want {
+			t.Errorf("demangle(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestDemangleRust(t *testing.T) {
+	for _, tt := range rustTests {
+		if got := demangle(tt.name); got != tt.want {
+			t.Errorf("demangle(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestSymName(t *testing.T) {
+	demangleNames = true
+	defer func() { demangleNames = false }()
+
+	if got := symName("_Znwm@GLIBCXX_3.4"); got != "operator new(unsigned long)@GLIBCXX_3.4" {
+		t.Errorf("symName() = %q", got)
+	}
+}
+
+// malformed names should be rejected without a panic
+func TestDemangleRustMutation(t *testing.T) {
+	bad := []string{
+		"_RNvCs" + strings.Repeat("z", 20) + "_7mycrate3foo", // overflow in base62
+		"_RNvCsZZZZZZZZZZZ_7mycrate3foo",
+		"_RNvB" + strings.Repeat("Z", 12) + "_3foo", // negative backref
+		"_RNvBzzzzzzzzzzzzzzzzzz_3foo",
+		"_RNvCs_7mycrate9223372036854775807foo", // ident length overflow
+		"_RNvCs_7mycrate18446744073709551615foo",
+		"_RINvCs_7mycrate3fooFG" + strings.Repeat("z", 10) + "_EuE", // too many lifetimes
+	}
+	for _, name := range bad {
+		if got, ok := demangleRust(name); ok {
+			t.Errorf("demangleRust(%q) = %q", name, got)
+		}
+	}
+
+	// replace, drop or repeat each byte of valid names
+	subst := []string{"", "_", "0", "9", "z", "Z", "B", "G", "u", "zzzzzzzzzzzz", "99999999999999999999"}
+	for _, tt := range rustTests {
+		if !strings.HasPrefix(tt.name, "_R") {
+			continue
+		}
+		for i := 2; i <= len(tt.name); i++ {
+			for _, s := range subst {
+				demangleRust(tt.name[:i] + s)
+				if i < len(tt.name) {
+					demangleRust(tt.name[:i] + s + tt.name[i+1:])
+				}
+			}
+		}
+	}
+}
+
+func FuzzDemangle(f *testing.F) {
+	for _, tt := range cxxTests {
+		f.Add(tt.name)
+	}
+	for _, tt := range rustTests {
+		f.Add(tt.name)
+	}
+	f.Fuzz(func(t *testing.T, name string) {
+		demangle(name)
+	})
+}
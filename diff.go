@@ -0,0 +1,316 @@
+/*
+ * ELF tree - Tree viewer for ELF library dependency
+ *
+ * Copyright (C) 2017-2018  Namhyung Kim <namhyung@gmail.com>
+ *
+ * Released under MIT license.
+ */
+package main
+
+import (
+	"debug/elf"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"strings"
+)
+
+type DiffValue struct {
+	Name string `json:"name"`
+	Old  string `json:"old"`
+	New  string `json:"new"`
+}
+
+type ObjectDiff struct {
+	Name        string      `json:"name"`
+	Root        bool        `json:"root,omitempty"`
+	OldPath     string      `json:"old_path"`
+	NewPath     string      `json:"new_path"`
+	Dynamic     []DiffValue `json:"dynamic,omitempty"`
+	AddedSyms   []string    `json:"added_symbols,omitempty"`
+	RemovedSyms []string    `json:"removed_symbols,omitempty"`
+	AddedVers   []string    `json:"added_versions,omitempty"`
+	RemovedVers []string    `json:"removed_versions,omitempty"`
+}
+
+type DepsDiff struct {
+	Old     string       `json:"old"`
+	New     string       `json:"new"`
+	Added   []DiffValue  `json:"added,omitempty"`
+	Removed []DiffValue  `json:"removed,omitempty"`
+	Changed []ObjectDiff `json:"changed,omitempty"`
+}
+
+// dynamic tags to be compared
+var diffTags = []elf.DynTag{elf.DT_RPATH, elf.DT_RUNPATH, elf.DT_FLAGS, DT_FLAGS_1}
+
+const (
+	COLOR_RED    = "\x1b[31m"
+	COLOR_GREEN  = "\x1b[32m"
+	COLOR_YELLOW = "\x1b[33m"
+	COLOR_RESET  = "\x1b[0m"
+)
+
+func dynValue(info *DepsInfo, tag elf.DynTag) string {
+	var vals []string
+
+	for _, dyn := range info.dyns {
+		if dyn.tag != tag {
+			continue
+		}
+
+		switch tag {
+		case elf.DT_FLAGS:
+			vals = append(vals, strFlags(dyn.val.(uint64)))
+		case DT_FLAGS_1:
+			vals = append(vals, strFlags1(dyn.val.(uint64)))
+		default:
+			vals = append(vals, fmt.Sprint(dyn.val))
+		}
+	}
+	return strings.Join(vals, ":")
+}
+
+// exported (defined global or weak) dynamic symbols with version
+func exportedSymbols(info *DepsInfo) map[string]bool {
+	syms := make(map[string]bool)
+
+	for _, sym := range info.dsym {
+		if sym.Section == elf.SHN_UNDEF {
+			continue
+		}
+		bind := elf.ST_BIND(sym.Info)
+		if bind != elf.STB_GLOBAL && bind != elf.STB_WEAK {
+			continue
+		}
+
+		name := sym.Name
+		if sym.Version != "" {
+			name += "@" + sym.Version
+		}
+		syms[name] = true
+	}
+	return syms
+}
+
+// required symbol versions per library
+func requiredVersions(info *DepsInfo) map[string]bool {
+	vers := make(map[string]bool)
+
+	for _, sym := range info.isym {
+		if sym.Version == "" {
+			continue
+		}
+		vers[sym.Library+":"+sym.Version] = true
+	}
+	return vers
+}
+
+// returns sorted lists of keys only in a and only in b
+func diffSets(a, b map[string]bool) (added, removed []string) {
+	for k := range b {
+		if !a[k] {
+			added = append(added, k)
+		}
+	}
+	for k := range a {
+		if !b[k] {
+			removed = append(removed, k)
+		}
+	}
+	sort.Strings(added)
+	sort.Strings(removed)
+	return
+}
+
+func diffObject(name string, a, b *DepsInfo, root bool) *ObjectDiff {
+	od := &ObjectDiff{Name: name, Root: root, OldPath: a.path, NewPath: b.path}
+	changed := !root && a.path != b.path
+
+	for _, tag := range diffTags {
+		ov := dynValue(a, tag)
+		nv := dynValue(b, tag)
+
+		if ov != nv {
+			od.Dynamic = append(od.Dynamic, DiffValue{tag.String(), ov, nv})
+			changed = true
+		}
+	}
+
+	od.AddedSyms, od.RemovedSyms = diffSets(exportedSymbols(a), exportedSymbols(b))
+	od.AddedVers, od.RemovedVers = diffSets(requiredVersions(a), requiredVersions(b))
+
+	if len(od.AddedSyms)+len(od.RemovedSyms)+len(od.AddedVers)+len(od.RemovedVers) > 0 {
+		changed = true
+	}
+
+	if !changed {
+		return nil
+	}
+	return od
+}
+
+func diffDeps(oldRoot *DepsNode, oldDeps map[string]DepsInfo,
+	newRoot *DepsNode, newDeps map[string]DepsInfo) *DepsDiff {
+	d := &DepsDiff{Old: oldDeps[oldRoot.name].path, New: newDeps[newRoot.name].path}
+
+	// compare root binaries regardless of their names
+	oldInfo := oldDeps[oldRoot.name]
+	newInfo := newDeps[newRoot.name]
+	if od := diffObject(newRoot.name, &oldInfo, &newInfo, true); od != nil {
+		d.Changed = append(d.Changed, *od)
+	}
+
+	var names []string
+	for k := range oldDeps {
+		if k != oldRoot.name {
+			names = append(names, k)
+		}
+	}
+	for k := range newDeps {
+		if _, ok := oldDeps[k]; !ok && k != newRoot.name {
+			names = append(names, k)
+		}
+	}
+	sort.Strings(names)
+
+	for _, k := range names {
+		oldInfo, inOld := oldDeps[k]
+		newInfo, inNew := newDeps[k]
+
+		if k == newRoot.name {
+			inNew = false
+		}
+
+		if inOld && !inNew {
+			d.Removed = append(d.Removed, DiffValue{k, oldInfo.path, ""})
+		} else if !inOld && inNew {
+			d.Added = append(d.Added, DiffValue{k, "", newInfo.path})
+		} else if od := diffObject(k, &oldInfo, &newInfo, false); od != nil {
+			d.Changed = append(d.Changed, *od)
+		}
+	}
+	return d
+}
+
+func isTerminal(f *os.File) bool {
+	fi, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return (fi.Mode() & os.ModeCharDevice) != 0
+}
+
+func printDiffLine(color, format string, args ...interface{}) {
+	line := fmt.Sprintf(format, args...)
+
+	if color != "" && isTerminal(os.Stdout) {
+		line = color + line + COLOR_RESET
+	}
+	fmt.Println(line)
+}
+
+func printDepsDiff(d *DepsDiff) {
+	printDiffLine(COLOR_RED, "--- %s", d.Old)
+	printDiffLine(COLOR_GREEN, "+++ %s", d.New)
+
+	if len(d.Added)+len(d.Removed)+len(d.Changed) == 0 {
+		fmt.Println("no difference")
+		return
+	}
+
+	if len(d.Added) > 0 {
+		fmt.Println()
+		fmt.Println("Added libraries:")
+		for _, v := range d.Added {
+			printDiffLine(COLOR_GREEN, "  + %s  => %s", v.Name, v.New)
+		}
+	}
+
+	if len(d.Removed) > 0 {
+		fmt.Println()
+		fmt.Println("Removed libraries:")
+		for _, v := range d.Removed {
+			printDiffLine(COLOR_RED, "  - %s  => %s", v.Name, v.Old)
+		}
+	}
+
+	if len(d.Changed) > 0 {
+		fmt.Println()
+		fmt.Println("Changed objects:")
+	}
+	for _, od := range d.Changed {
+		printDiffLine(COLOR_YELLOW, "  ~ %s", od.Name)
+
+		if !od.Root && od.OldPath != od.NewPath {
+			fmt.Printf("      path:  %s -> %s\n", od.OldPath, od.NewPath)
+		}
+		for _, v := range od.Dynamic {
+			fmt.Printf("      %s:  '%s' -> '%s'\n", v.Name, v.Old, v.New)
+		}
+		for _, s := range od.AddedSyms {
+			printDiffLine(COLOR_GREEN, "      + symbol  %s", symName(s))
+		}
+		for _, s := range od.RemovedSyms {
+			printDiffLine(COLOR_RED, "      - symbol  %s", symName(s))
+		}
+		for _, s := range od.AddedVers {
+			printDiffLine(COLOR_GREEN, "      + version %s", s)
+		}
+		for _, s := range od.RemovedVers {
+			printDiffLine(COLOR_RED, "      - version %s", s)
+		}
+	}
+}
+
+// short summary of the change for the status line
+func diffNote(od *ObjectDiff) string {
+	var notes []string
+
+	if !od.Root && od.OldPath != od.NewPath {
+		notes = append(notes, "path")
+	}
+	for _, v := range od.Dynamic {
+		notes = append(notes, v.Name)
+	}
+	if len(od.AddedSyms)+len(od.RemovedSyms) > 0 {
+		notes = append(notes, fmt.Sprintf("symbols +%d/-%d",
+			len(od.AddedSyms), len(od.RemovedSyms)))
+	}
+	if len(od.AddedVers)+len(od.RemovedVers) > 0 {
+		notes = append(notes, fmt.Sprintf("versions +%d/-%d",
+			len(od.AddedVers), len(od.RemovedVers)))
+	}
+	return strings.Join(notes, ", ")
+}
+
+func diffMain(oldPath, newPath string) {
+	f := openElf(oldPath)
+	f.Close()
+	f = openElf(newPath)
+	f.Close()
+
+	oldRoot := loadDeps(oldPath, path.Base(oldPath))
+	oldDeps := deps
+
+	deps = make(map[string]DepsInfo)
+	newRoot := loadDeps(newPath, path.Base(newPath))
+	newDeps := deps
+
+	d := diffDeps(oldRoot, oldDeps, newRoot, newDeps)
+
+	if showJson {
+		out, err := json.MarshalIndent(d, "", "  ")
+		if err != nil {
+			fmt.Printf("elftree: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Println(string(out))
+	} else if showTui {
+		ShowDiffWithTUI(oldRoot, newRoot, d)
+	} else {
+		printDepsDiff(d)
+	}
+}
@@ -0,0 +1,274 @@
+/*
+ * ELF tree - Tree viewer for ELF library dependency
+ *
+ * Copyright (C) 2017-2018  Namhyung Kim <namhyung@gmail.com>
+ *
+ * Released under MIT license.
+ */
+package main
+
+import (
+	"debug/elf"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"golang.org/x/arch/arm64/arm64asm"
+	"golang.org/x/arch/x86/x86asm"
+)
+
+const DISASM_BYTES = 8 // max bytes shown in an instruction line
+
+type SymAddr struct {
+	addr uint64
+	size uint64
+	name string
+}
+
+// sorted symbols (and PLT entries) to annotate addresses
+type SymIndex []SymAddr
+
+// symbol index of each object
+var symIndexCache = make(map[string]SymIndex)
+
+// pager disassembles a function
+type Disasm struct {
+	mach elf.Machine
+	bo   binary.ByteOrder
+	code []byte
+	addr uint64
+	off  int
+	syms SymIndex
+}
+
+func canDisasm(mach elf.Machine) bool {
+	return mach == elf.EM_X86_64 || mach == elf.EM_386 || mach == elf.EM_AARCH64
+}
+
+func makeSymIndex(name string) SymIndex {
+	if idx, ok := symIndexCache[name]; ok {
+		return idx
+	}
+
+	info := deps[name]
+	var idx SymIndex
+
+	for _, syms := range [][]elf.Symbol{info.syms, info.dsym} {
+		for _, sym := range syms {
+			t := elf.ST_TYPE(sym.Info)
+			if t != elf.STT_FUNC && t != elf.STT_OBJECT && t != elf.STT_LOOS {
+				continue
+			}
+			if sym.Section == elf.SHN_UNDEF || sym.Value == 0 {
+				continue
+			}
+			idx = append(idx, SymAddr{sym.Value, sym.Size, symName(sym.Name)})
+		}
+	}
+	for _, p := range readPltEntries(name) {
+		idx = append(idx, SymAddr{p.addr, 0, symName(p.name) + "@plt"})
+	}
+
+	// for indirect calls without PLT (-fno-plt)
+	ptrSize := uint64(8)
+	if info.bits == elf.ELFCLASS32 {
+		ptrSize = 4
+	}
+	for addr, sym := range readGotSlots(&info) {
+		idx = append(idx, SymAddr{addr, ptrSize, symName(sym) + "@got"})
+	}
+
+	sort.SliceStable(idx, func(i, j int) bool { return idx[i].addr < idx[j].addr })
+	symIndexCache[name] = idx
+	return idx
+}
+
+// returns symbol name (with offset) of the address
+func (idx SymIndex) lookup(addr uint64) string {
+	i := sort.Search(len(idx), func(i int) bool { return idx[i].addr > addr }) - 1
+	if i < 0 {
+		return ""
+	}
+
+	s := idx[i]
+	if addr == s.addr {
+		return s.name
+	}
+	if addr < s.addr+s.size {
+		return fmt.Sprintf("%s+%#x", s.name, addr-s.addr)
+	}
+	return ""
+}
+
+func (d *Disasm) x86Inst(pc uint64, code []byte) (int, string, uint64) {
+	mode := 64
+	if d.mach == elf.EM_386 {
+		mode = 32
+	}
+
+	// CET instructions are not supported by the decoder
+	if len(code) >= 4 && code[0] == 0xf3 && code[1] == 0x0f && code[2] == 0x1e {
+		switch code[3] {
+		case 0xfa:
+			return 4, "endbr64", 0
+		case 0xfb:
+			return 4, "endbr32", 0
+		}
+	}
+
+	inst, err := x86asm.Decode(code, mode)
+	if err != nil {
+		return 1, "(bad)", 0
+	}
+
+	// target address of the branch or memory access
+	var target uint64
+	for _, arg := range inst.Args {
+		switch a := arg.(type) {
+		case x86asm.Rel:
+			target = pc + uint64(inst.Len) + uint64(int64(a))
+		case x86asm.Mem:
+			if a.Base == x86asm.RIP {
+				target = pc + uint64(inst.Len) + uint64(a.Disp)
+			}
+		}
+	}
+	return inst.Len, x86asm.GNUSyntax(inst, pc, nil), target
+}
+
+func (d *Disasm) arm64Inst(pc uint64, code []byte) (int, string, uint64) {
+	if len(code) < 4 {
+		return len(code), "(bad)", 0
+	}
+
+	inst, err := arm64asm.Decode(code)
+	if err != nil {
+		return 4, fmt.Sprintf(".inst %#08x", d.bo.Uint32(code)), 0
+	}
+
+	var target uint64
+	if inst.Op != arm64asm.ADRP {
+		for _, arg := range inst.Args {
+			if rel, ok := arg.(arm64asm.PCRel); ok {
+				target = pc + uint64(int64(rel))
+			}
+		}
+	}
+
+	asm := arm64asm.GNUSyntax(inst)
+	if target != 0 {
+		// show absolute address instead of ".+offset"
+		asm = strings.Replace(asm, arm64asm.PCRel(target-pc).String(), fmt.Sprintf("%#x", target), 1)
+	}
+	return 4, asm, target
+}
+
+func (d *Disasm) next() []string {
+	var lines []string
+
+	for len(lines) < DUMP_LINES && d.off < len(d.code) {
+		pc := d.addr + uint64(d.off)
+		code := d.code[d.off:]
+
+		var n int
+		var asm string
+		var target uint64
+		if d.mach == elf.EM_AARCH64 {
+			n, asm, target = d.arm64Inst(pc, code)
+		} else {
+			n, asm, target = d.x86Inst(pc, code)
+		}
+
+		var hex []string
+		for i := 0; i < n && i < DISASM_BYTES; i++ {
+			hex = append(hex, fmt.Sprintf("%02x", code[i]))
+		}
+
+		line := fmt.Sprintf("  %8x:  %-*s  %s", pc, DISASM_BYTES*3-1, strings.Join(hex, " "), asm)
+		if s := d.syms.lookup(target); target != 0 && s != "" {
+			line += "  <" + s + ">"
+		}
+		lines = append(lines, line)
+
+		d.off += n
+	}
+	return lines
+}
+
+func (d *Disasm) more() string {
+	return fmt.Sprintf("  ... %d of %d bytes (press ENTER for more)", d.off, len(d.code))
+}
+
+func (d *Disasm) done() bool {
+	return d.off >= len(d.code)
+}
+
+// read function body from its section
+func readFunction(info *DepsInfo, sym *elf.Symbol) ([]byte, error) {
+	idx := int(sym.Section)
+	s := info.sect[idx]
+	if sym.Value < s.Addr || sym.Value+sym.Size > s.Addr+s.Size {
+		return nil, fmt.Errorf("symbol is out of the section %s", s.Name)
+	}
+
+	r, err := sectionData(info.path, info.sect, idx)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := r.Seek(int64(sym.Value-s.Addr), io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	code := make([]byte, sym.Size)
+	if _, err := io.ReadFull(r, code); err != nil {
+		return nil, err
+	}
+	return code, nil
+}
+
+// make the symbol item expandable to see disassembly of the function
+func setDisasm(ti *TreeItem, name string, info *DepsInfo, sym *elf.Symbol) {
+	t := elf.ST_TYPE(sym.Info)
+	if t != elf.STT_FUNC && t != elf.STT_LOOS {
+		return
+	}
+	if sym.Size == 0 || sym.Section == elf.SHN_UNDEF || int(sym.Section) >= len(info.sect) {
+		return
+	}
+	if info.sect[sym.Section].Flags&elf.SHF_EXECINSTR == 0 {
+		return
+	}
+
+	fn := *sym
+	ti.folded = true
+	ti.paged = true
+	ti.load = func(ti *TreeItem) {
+		info := deps[name]
+
+		code, err := readFunction(&info, &fn)
+		if err != nil {
+			appendChild(ti, fmt.Sprintf("  error: %v", err))
+			return
+		}
+
+		d := &Disasm{mach: info.mach, bo: info.endian, code: code,
+			addr: fn.Value, syms: makeSymIndex(name)}
+		loadPage(ti, d)
+	}
+}
+
+// functions in the symbol table (skip the header line)
+func setSymbolDisasm(table *TreeItem, name string, syms []*elf.Symbol) {
+	info := deps[name]
+	if !canDisasm(info.mach) {
+		return
+	}
+
+	i := 0
+	for ti := table.child.next; ti != nil; ti = ti.next {
+		setDisasm(ti, name, &info, syms[i])
+		i++
+	}
+}
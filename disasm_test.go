@@ -0,0 +1,79 @@
+/*
+ * ELF tree - Tree viewer for ELF library dependency
+ *
+ * Copyright (C) 2017-2018  Namhyung Kim <namhyung@gmail.com>
+ *
+ * Released under MIT license.
+ */
+package main
+
+import (
+	"debug/elf"
+	"encoding/binary"
+	"strings"
+	"testing"
+)
+
+var testSymIndex = SymIndex{
+	{0x1000, 0x10, "init"},
+	{0x1020, 0, "puts@plt"},
+	{0x2000, 0x20, "main"},
+}
+
+func TestSymIndexLookup(t *testing.T) {
+	tests := map[uint64]string{
+		0xfff:  "",
+		0x1000: "init",
+		0x1008: "init+0x8",
+		0x1010: "",
+		0x1020: "puts@plt",
+		0x2004: "main+0x4",
+		0x3000: "",
+	}
+	for addr, want := range tests {
+		if got := testSymIndex.lookup(addr); got != want {
+			t.Errorf("%#x: got %q, want %q", addr, got, want)
+		}
+	}
+}
+
+func TestDisasmX86(t *testing.T) {
+	d := &Disasm{mach: elf.EM_X86_64, addr: 0x1130, syms: testSymIndex, code: []byte{
+		0xf3, 0x0f, 0x1e, 0xfa, // endbr64
+		0xe8, 0xe7, 0xfe, 0xff, 0xff, // call puts@plt
+		0xc3, // ret
+	}}
+
+	lines := d.next()
+	if len(lines) != 3 || !d.done() {
+		t.Fatalf("got %q", lines)
+	}
+	if !strings.HasPrefix(lines[0], "      1130:  f3 0f 1e fa") || !strings.HasSuffix(lines[0], "endbr64") {
+		t.Errorf("got %q", lines[0])
+	}
+	if !strings.Contains(lines[1], "call") || !strings.HasSuffix(lines[1], "<puts@plt>") {
+		t.Errorf("got %q", lines[1])
+	}
+	if !strings.HasPrefix(lines[2], "      1139:  c3 ") {
+		t.Errorf("got %q", lines[2])
+	}
+}
+
+func TestDisasmArm64(t *testing.T) {
+	code := make([]byte, 8)
+	binary.LittleEndian.PutUint32(code, 0x94000004)     // bl main
+	binary.LittleEndian.PutUint32(code[4:], 0xd65f03c0) // ret
+
+	d := &Disasm{mach: elf.EM_AARCH64, bo: binary.LittleEndian, addr: 0x1ff0, syms: testSymIndex, code: code}
+
+	lines := d.next()
+	if len(lines) != 2 {
+		t.Fatalf("got %q", lines)
+	}
+	if !strings.Contains(lines[0], "bl 0x2000") || !strings.HasSuffix(lines[0], "<main>") {
+		t.Errorf("got %q", lines[0])
+	}
+	if !strings.HasSuffix(lines[1], "ret") {
+		t.Errorf("got %q", lines[1])
+	}
+}
@@ -0,0 +1,365 @@
+/*
+ * ELF tree - Tree viewer for ELF library dependency
+ *
+ * Copyright (C) 2017-2018  Namhyung Kim <namhyung@gmail.com>
+ *
+ * Released under MIT license.
+ */
+package main
+
+import (
+	"bufio"
+	"debug/elf"
+	"fmt"
+	"io"
+	"strings"
+)
+
+const (
+	DUMP_LINES  = 256 // lines loaded at once
+	DUMP_WIDTH  = 16  // bytes in a hex dump line
+	DUMP_STRLEN = 4   // minimum length of strings
+)
+
+// files are closed after reading ELF info, reopen them to read contents
+var dumpFiles = make(map[string]*elf.File)
+
+// contents loaded page by page
+type Pager interface {
+	next() []string // lines of the next page
+	more() string   // shown at the end of a page
+	done() bool
+}
+
+// pager reads section (or segment) data
+type DumpPager struct {
+	r    *bufio.Reader
+	addr uint64 // address of the next data
+	size uint64
+	off  uint64
+	strs bool   // show strings instead of hex dump
+	str  []byte // partial string at the page boundary
+	eof  bool
+	err  error
+}
+
+func reopenElf(path string) (*elf.File, error) {
+	if f, ok := dumpFiles[path]; ok {
+		return f, nil
+	}
+
+	f, err := sysfs.OpenElf(path)
+	if err != nil {
+		return nil, err
+	}
+	dumpFiles[path] = f
+	return f, nil
+}
+
+// check if the data is accessible (from memory or an open file)
+func readable(r io.ReadSeeker) bool {
+	var b [1]byte
+	if _, err := r.Read(b[:]); err != nil && err != io.EOF {
+		return false
+	}
+	_, err := r.Seek(0, io.SeekStart)
+	return err == nil
+}
+
+// returns (uncompressed) data of the section
+func sectionData(path string, sect []*elf.Section, idx int) (io.ReadSeeker, error) {
+	if r := sect[idx].Open(); readable(r) {
+		return r, nil
+	}
+
+	f, err := reopenElf(path)
+	if err != nil {
+		return nil, err
+	}
+	if idx >= len(f.Sections) {
+		return nil, fmt.Errorf("no section %d in %s", idx, path)
+	}
+	return f.Sections[idx].Open(), nil
+}
+
+func segmentData(path string, prog []*elf.Prog, idx int) (io.Reader, error) {
+	p := prog[idx]
+	if r := p.Open(); readable(r) {
+		return io.LimitReader(r, int64(p.Filesz)), nil
+	}
+
+	f, err := reopenElf(path)
+	if err != nil {
+		return nil, err
+	}
+	if idx >= len(f.Progs) {
+		return nil, fmt.Errorf("no segment %d in %s", idx, path)
+	}
+	return io.LimitReader(f.Progs[idx].Open(), int64(p.Filesz)), nil
+}
+
+func hexDumpLine(addr uint64, data []byte) string {
+	var hex, ascii strings.Builder
+
+	for i := 0; i < DUMP_WIDTH; i++ {
+		if i == DUMP_WIDTH/2 {
+			hex.WriteByte(' ')
+		}
+		if i >= len(data) {
+			hex.WriteString("   ")
+			continue
+		}
+
+		c := data[i]
+		fmt.Fprintf(&hex, "%02x ", c)
+		if c >= 0x20 && c < 0x7f {
+			ascii.WriteByte(c)
+		} else {
+			ascii.WriteByte('.')
+		}
+	}
+	return fmt.Sprintf("  %08x  %s |%s|", addr, hex.String(), ascii.String())
+}
+
+func (p *DumpPager) hexDump() []string {
+	var lines []string
+	var buf [DUMP_WIDTH]byte
+
+	for len(lines) < DUMP_LINES {
+		n, err := io.ReadFull(p.r, buf[:])
+		if n > 0 {
+			lines = append(lines, hexDumpLine(p.addr+p.off, buf[:n]))
+			p.off += uint64(n)
+		}
+		if err != nil {
+			if err != io.EOF && err != io.ErrUnexpectedEOF {
+				p.err = err
+			}
+			p.eof = true
+			break
+		}
+	}
+	return lines
+}
+
+func (p *DumpPager) findStrings() []string {
+	var lines []string
+
+	flush := func() {
+		if len(p.str) >= DUMP_STRLEN {
+			start := p.addr + p.off - uint64(len(p.str))
+			lines = append(lines, fmt.Sprintf("  %08x  %s", start, p.str))
+		}
+		p.str = p.str[:0]
+	}
+
+	// a page ends after the same amount of data as the hex dump,
+	// so a string can continue to the next page
+	for n := 0; n < DUMP_LINES*DUMP_WIDTH && len(lines) < DUMP_LINES; n++ {
+		c, err := p.r.ReadByte()
+		if err != nil {
+			if err != io.EOF {
+				p.err = err
+			}
+			flush()
+			p.eof = true
+			break
+		}
+
+		if (c >= 0x20 && c < 0x7f) || c == '\t' {
+			p.str = append(p.str, c)
+		} else {
+			flush()
+		}
+		p.off++
+	}
+	return lines
+}
+
+// returns lines of the next page
+func (p *DumpPager) next() []string {
+	if p.eof {
+		return nil
+	}
+
+	var lines []string
+	if p.strs {
+		lines = p.findStrings()
+	} else {
+		lines = p.hexDump()
+	}
+
+	if p.err != nil {
+		lines = append(lines, fmt.Sprintf("  error: %v", p.err))
+	}
+	return lines
+}
+
+func (p *DumpPager) more() string {
+	return fmt.Sprintf("  ... %d of %d bytes (press ENTER for more)", p.off, p.size)
+}
+
+func (p *DumpPager) done() bool {
+	return p.eof
+}
+
+// add a child to the (folded) item
+func appendChild(parent *TreeItem, node interface{}) *TreeItem {
+	t := &TreeItem{node: node, parent: parent}
+
+	if parent.child == nil {
+		parent.child = t
+		return t
+	}
+
+	last := parent.child
+	for last.next != nil {
+		last = last.next
+	}
+	last.next = t
+	t.prev = last
+	return t
+}
+
+// insert lines after the (shown) item
+func insertAfter(ti *TreeItem, lines []string) *TreeItem {
+	for _, s := range lines {
+		t := &TreeItem{node: s, parent: ti.parent, prev: ti, next: ti.next}
+		if ti.next != nil {
+			ti.next.prev = t
+		}
+		ti.next = t
+		ti = t
+	}
+
+	// it might be loaded by search under a folded item
+	for p := ti.parent; p != nil; p = p.parent {
+		if p.folded {
+			break
+		}
+		p.total += len(lines)
+	}
+	return ti
+}
+
+// load the first page when the item is expanded
+func loadPage(ti *TreeItem, p Pager) {
+	for _, s := range p.next() {
+		appendChild(ti, s)
+	}
+	if ti.child == nil && p.done() {
+		appendChild(ti, "  (empty)")
+	}
+	if !p.done() {
+		more := appendChild(ti, p.more())
+		more.folded = true
+		more.paged = true
+		more.load = func(ti *TreeItem) { loadMore(ti, p) }
+	}
+}
+
+// replace the "more" item with the next page
+func loadMore(ti *TreeItem, p Pager) {
+	ti.folded = false
+
+	lines := p.next()
+	if len(lines) == 0 && !p.done() {
+		// no strings in the page
+		ti.node = p.more()
+		ti.folded = true
+		ti.load = func(ti *TreeItem) { loadMore(ti, p) }
+		return
+	}
+	if len(lines) == 0 {
+		ti.node = "  (end)"
+		return
+	}
+
+	ti.node = lines[0]
+	last := insertAfter(ti, lines[1:])
+
+	if !p.done() {
+		more := insertAfter(last, []string{p.more()})
+		more.folded = true
+		more.paged = true
+		more.load = func(ti *TreeItem) { loadMore(ti, p) }
+	}
+}
+
+func newDumpPager(r io.Reader, err error, addr, size uint64, strs bool) *DumpPager {
+	p := &DumpPager{addr: addr, size: size, strs: strs}
+	if err != nil {
+		p.err = err
+		p.eof = true
+		return p
+	}
+	p.r = bufio.NewReader(r)
+	return p
+}
+
+// sections worth showing as strings
+func hasStrings(s *elf.Section) bool {
+	if s.Type == elf.SHT_STRTAB || s.Flags&elf.SHF_STRINGS != 0 {
+		return true
+	}
+	return strings.HasPrefix(s.Name, ".rodata") || s.Name == ".comment"
+}
+
+func sectionDumpLabel(s *elf.Section) string {
+	label := fmt.Sprintf("  Hex Dump (%d bytes", s.Size)
+	if s.Flags&elf.SHF_COMPRESSED != 0 || strings.HasPrefix(s.Name, ".zdebug") {
+		label += fmt.Sprintf(", compressed to %d", s.FileSize)
+	}
+	return label + ")"
+}
+
+// make the section item expandable to see its contents
+func setSectionDump(ti *TreeItem, path string, sect []*elf.Section, idx int) {
+	s := sect[idx]
+	if s.Type == elf.SHT_NOBITS || s.Type == elf.SHT_NULL || s.Size == 0 {
+		return
+	}
+
+	addr := uint64(0)
+	if s.Flags&elf.SHF_ALLOC != 0 {
+		addr = s.Addr
+	}
+
+	ti.folded = true
+	ti.load = func(ti *TreeItem) {
+		hex := appendChild(ti, sectionDumpLabel(s))
+		hex.folded = true
+		hex.paged = true
+		hex.load = func(ti *TreeItem) {
+			r, err := sectionData(path, sect, idx)
+			loadPage(ti, newDumpPager(r, err, addr, s.Size, false))
+		}
+
+		if !hasStrings(s) {
+			return
+		}
+
+		strs := appendChild(ti, "  Strings")
+		strs.folded = true
+		strs.paged = true
+		strs.load = func(ti *TreeItem) {
+			r, err := sectionData(path, sect, idx)
+			loadPage(ti, newDumpPager(r, err, addr, s.Size, true))
+		}
+	}
+}
+
+// make the segment item expandable to see its contents
+func setSegmentDump(ti *TreeItem, path string, prog []*elf.Prog, idx int) {
+	p := prog[idx]
+	if p.Filesz == 0 {
+		return
+	}
+
+	ti.folded = true
+	ti.paged = true
+	ti.load = func(ti *TreeItem) {
+		r, err := segmentData(path, prog, idx)
+		loadPage(ti, newDumpPager(r, err, p.Vaddr, p.Filesz, false))
+	}
+}
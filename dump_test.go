@@ -0,0 +1,142 @@
+/*
+ * ELF tree - Tree viewer for ELF library dependency
+ *
+ * Copyright (C) 2017-2018  Namhyung Kim <namhyung@gmail.com>
+ *
+ * Released under MIT license.
+ */
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+const DUMP_PAGE = DUMP_LINES * DUMP_WIDTH
+
+func newTestPager(data []byte, strs bool) *DumpPager {
+	return newDumpPager(bytes.NewReader(data), nil, 0x1000, uint64(len(data)), strs)
+}
+
+func TestHexDumpLine(t *testing.T) {
+	want := "  00001000  41 42 43 00 01 02 03 04  7e 7f                    |ABC.....~.|"
+	if got := hexDumpLine(0x1000, []byte("ABC\x00\x01\x02\x03\x04\x7e\x7f")); got != want {
+		t.Errorf("got  %q\nwant %q", got, want)
+	}
+}
+
+func TestHexDumpPages(t *testing.T) {
+	p := newTestPager(make([]byte, DUMP_PAGE+5), false)
+
+	lines := p.next()
+	if len(lines) != DUMP_LINES || p.done() {
+		t.Fatalf("first page: got %d lines (done: %v)", len(lines), p.done())
+	}
+	if want := fmt.Sprintf("... %d of %d bytes", DUMP_PAGE, DUMP_PAGE+5); !strings.Contains(p.more(), want) {
+		t.Errorf("more: got %q", p.more())
+	}
+
+	lines = p.next()
+	if len(lines) != 1 || !p.done() || !strings.HasPrefix(lines[0], fmt.Sprintf("  %08x ", 0x1000+DUMP_PAGE)) {
+		t.Errorf("second page: got %q (done: %v)", lines, p.done())
+	}
+	if p.next() != nil {
+		t.Error("got lines after the end")
+	}
+}
+
+// data ends at the page boundary
+func TestHexDumpFullPage(t *testing.T) {
+	p := newTestPager(make([]byte, DUMP_PAGE), false)
+
+	ti := &TreeItem{node: "Hex Dump"}
+	loadPage(ti, p)
+	if ti.total != 0 {
+		t.Fatalf("folded item has total %d", ti.total)
+	}
+
+	var more *TreeItem
+	n := 0
+	for c := ti.child; c != nil; c = c.next {
+		more = c
+		n++
+	}
+	if n != DUMP_LINES+1 || more.load == nil {
+		t.Fatalf("got %d items without more item", n)
+	}
+
+	load := more.load
+	more.load = nil
+	load(more)
+	if more.node != "  (end)" || more.next != nil {
+		t.Errorf("got %v after the last page", more.node)
+	}
+}
+
+func TestFindStrings(t *testing.T) {
+	data := []byte("\x00\x01hello\x00abc\x00world\ttab\x7fend of data")
+	p := newTestPager(data, true)
+
+	want := []string{
+		"  00001002  hello",
+		"  0000100c  world\ttab",
+		"  00001016  end of data",
+	}
+	lines := p.next()
+	if strings.Join(lines, "\n") != strings.Join(want, "\n") || !p.done() {
+		t.Errorf("got %q", lines)
+	}
+}
+
+// a string crossing the page boundary is shown in the next page
+func TestFindStringsAcrossPage(t *testing.T) {
+	data := make([]byte, 2*DUMP_PAGE)
+	copy(data[0x10:], "first string")
+	copy(data[DUMP_PAGE-4:], "split string")
+	p := newTestPager(data, true)
+
+	ti := &TreeItem{node: "Strings"}
+	loadPage(ti, p)
+	if ti.child.node != "  00001010  first string" {
+		t.Errorf("first page: got %q", ti.child.node)
+	}
+
+	more := ti.child.next
+	if more == nil || more.load == nil || more.next != nil {
+		t.Fatalf("no more item after the first page")
+	}
+
+	load := more.load
+	more.load = nil
+	load(more)
+
+	want := fmt.Sprintf("  %08x  split string", 0x1000+DUMP_PAGE-4)
+	if more.node != want {
+		t.Errorf("second page: got %q, want %q", more.node, want)
+	}
+}
+
+// no strings in a page shouldn't end the dump
+func TestFindStringsEmptyPage(t *testing.T) {
+	data := make([]byte, 2*DUMP_PAGE+8)
+	copy(data[2*DUMP_PAGE:], "tail")
+	p := newTestPager(data, true)
+
+	ti := &TreeItem{node: "Strings"}
+	loadPage(ti, p)
+
+	more := ti.child
+	for i := 0; more != nil && more.load != nil; i++ {
+		if i > 3 {
+			t.Fatal("too many pages")
+		}
+		load := more.load
+		more.load = nil
+		load(more)
+	}
+	if more == nil || more.node != fmt.Sprintf("  %08x  tail", 0x1000+2*DUMP_PAGE) {
+		t.Errorf("got %v", more)
+	}
+}
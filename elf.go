@@ -11,47 +11,158 @@ import (
 	"debug/elf"
 	"fmt"
 	str "strings"
+	"time"
 )
 
-const (
-	GNU_EH_FRAME = elf.PT_LOOS + 74769744
-	GNU_STACK    = elf.PT_LOOS + 74769745
-	GNU_RELRO    = elf.PT_LOOS + 74769746
-)
+// OS-specific segment types
+var progTypeNames = map[elf.ProgType]string{
+	elf.PT_GNU_EH_FRAME:      "GNU_EH_FRAME",
+	elf.PT_GNU_STACK:         "GNU_STACK",
+	elf.PT_GNU_RELRO:         "GNU_RELRO",
+	elf.PT_GNU_PROPERTY:      "GNU_PROPERTY",
+	0x6474e554:               "GNU_SFRAME",
+	elf.PT_PAX_FLAGS:         "PAX_FLAGS",
+	elf.PT_OPENBSD_RANDOMIZE: "OPENBSD_RANDOMIZE",
+	elf.PT_OPENBSD_WXNEEDED:  "OPENBSD_WXNEEDED",
+	0x65a3dbe8:               "OPENBSD_NOBTCFI", // elf.PT_OPENBSD_NOBTCFI in go1.23
+	0x65a3dbe9:               "OPENBSD_SYSCALLS",
+	elf.PT_OPENBSD_BOOTDATA:  "OPENBSD_BOOTDATA",
+	0x6464e550:               "SUNW_UNWIND",
+	0x6ffffffa:               "SUNWBSS",
+	elf.PT_SUNWSTACK:         "SUNWSTACK",
+	0x6ffffffc:               "SUNWDTRACE",
+	0x6ffffffd:               "SUNWCAP",
+}
 
-func progHdrString(phdr *elf.Prog) string {
-	var typeStr string
-	var flagStr string
-
-	switch phdr.Type {
-	case GNU_EH_FRAME:
-		typeStr = "GNU_EH_FRAME"
-	case GNU_STACK:
-		typeStr = "GNU_STACK"
-	case GNU_RELRO:
-		typeStr = "GNU_RELRO"
-	default:
-		typeStr = phdr.Type.String()[3:]
-	}
-
-	switch phdr.Flags {
-	case elf.PF_X:
-		flagStr = "__X"
-	case elf.PF_W:
-		flagStr = "_W_"
-	case elf.PF_R:
-		flagStr = "R__"
-	case elf.PF_R | elf.PF_W:
-		flagStr = "RW_"
-	case elf.PF_R | elf.PF_X:
-		flagStr = "R_X"
-	case elf.PF_R | elf.PF_W | elf.PF_X:
-		flagStr = "RWX"
-	default:
-		flagStr = "???"
+// processor-specific segment types
+func archProgTypeName(mach elf.Machine, typ elf.ProgType) string {
+	var names []string
+
+	switch mach {
+	case elf.EM_ARM:
+		names = []string{"ARM_ARCHEXT", "ARM_EXIDX"}
+	case elf.EM_AARCH64:
+		names = []string{"AARCH64_ARCHEXT", "AARCH64_UNWIND", "AARCH64_MEMTAG_MTE"}
+	case elf.EM_MIPS, elf.EM_MIPS_RS3_LE:
+		names = []string{"MIPS_REGINFO", "MIPS_RTPROC", "MIPS_OPTIONS", "MIPS_ABIFLAGS"}
+	case elf.EM_RISCV:
+		names = []string{"", "", "", "RISCV_ATTRIBUTES"}
+	case elf.EM_IA_64:
+		names = []string{"IA_64_ARCHEXT", "IA_64_UNWIND"}
+	case elf.EM_PARISC:
+		names = []string{"PARISC_ARCHEXT", "PARISC_UNWIND"}
+	case elf.EM_S390:
+		names = []string{"S390_PGSTE"}
+	}
+
+	i := int(typ - elf.PT_LOPROC)
+	if i < len(names) && names[i] != "" {
+		return names[i]
 	}
+	return fmt.Sprintf("LOPROC+%#x", i)
+}
 
-	return fmt.Sprintf("%-16s  %s  %#18x  %#10x  %#8x", typeStr, flagStr, phdr.Vaddr, phdr.Memsz, phdr.Align)
+func progTypeName(mach elf.Machine, typ elf.ProgType) string {
+	if typ <= elf.PT_TLS {
+		return typ.String()[3:]
+	}
+	if name, ok := progTypeNames[typ]; ok {
+		return name
+	}
+	if typ >= elf.PT_GNU_MBIND_LO && typ <= elf.PT_GNU_MBIND_HI {
+		return fmt.Sprintf("GNU_MBIND+%#x", int(typ-elf.PT_GNU_MBIND_LO))
+	}
+	if typ >= elf.PT_LOPROC && typ <= elf.PT_HIPROC {
+		return archProgTypeName(mach, typ)
+	}
+	if typ >= elf.PT_LOOS && typ <= elf.PT_HIOS {
+		return fmt.Sprintf("LOOS+%#x", int(typ-elf.PT_LOOS))
+	}
+	return fmt.Sprintf("<%#x>", int(typ))
+}
+
+func progFlagString(flags elf.ProgFlag) string {
+	f := []byte("___")
+	if flags&elf.PF_R != 0 {
+		f[0] = 'R'
+	}
+	if flags&elf.PF_W != 0 {
+		f[1] = 'W'
+	}
+	if flags&elf.PF_X != 0 {
+		f[2] = 'X'
+	}
+
+	// OS or processor specific flags
+	if other := flags &^ (elf.PF_R | elf.PF_W | elf.PF_X); other != 0 {
+		return fmt.Sprintf("%s+%#x", f, uint32(other))
+	}
+	return string(f)
+}
+
+func progHdrHeader() string {
+	return fmt.Sprintf("  %-18s %10s %18s %18s %10s %10s  %-5s %8s",
+		"Type", "Offset", "VirtAddr", "PhysAddr", "FileSiz", "MemSiz", "Flags", "Align")
+}
+
+func progHdrString(mach elf.Machine, phdr *elf.Prog) string {
+	return fmt.Sprintf("  %-18s %#10x %#18x %#18x %#10x %#10x  %-5s %#8x",
+		progTypeName(mach, phdr.Type), phdr.Off, phdr.Vaddr, phdr.Paddr,
+		phdr.Filesz, phdr.Memsz, progFlagString(phdr.Flags), phdr.Align)
+}
+
+// check if the section is in the segment like readelf
+func sectionInSegment(s *elf.Section, p *elf.Prog) bool {
+	if s.Type == elf.SHT_NULL {
+		return false
+	}
+
+	// TLS sections are only in PT_TLS, PT_LOAD and PT_GNU_RELRO
+	tls := s.Flags&elf.SHF_TLS != 0
+	if tls && p.Type != elf.PT_TLS && p.Type != elf.PT_LOAD && p.Type != elf.PT_GNU_RELRO {
+		return false
+	}
+	if !tls && p.Type == elf.PT_TLS {
+		return false
+	}
+	// .tbss occupies no space in other segments
+	if tls && s.Type == elf.SHT_NOBITS && p.Type != elf.PT_TLS {
+		return false
+	}
+
+	if s.Flags&elf.SHF_ALLOC != 0 {
+		if s.Addr < p.Vaddr || s.Addr+s.Size > p.Vaddr+p.Memsz {
+			return false
+		}
+		// empty sections at the end are not included
+		if s.Size == 0 && p.Memsz != 0 && s.Addr == p.Vaddr+p.Memsz {
+			return false
+		}
+	}
+
+	if s.Type != elf.SHT_NOBITS {
+		// section data should be in the file image of the segment
+		if s.Offset < p.Off || s.Offset+s.Size > p.Off+p.Filesz {
+			return false
+		}
+		if s.Size == 0 && p.Filesz != 0 && s.Offset == p.Off+p.Filesz {
+			return false
+		}
+	} else if s.Flags&elf.SHF_ALLOC == 0 {
+		return false
+	}
+	return true
+}
+
+// returns names of sections in the segment
+func segmentSections(info *DepsInfo, p *elf.Prog) []string {
+	var names []string
+	for _, s := range info.sect {
+		if sectionInSegment(s, p) {
+			names = append(names, s.Name)
+		}
+	}
+	return names
 }
 
 const (
@@ -65,6 +176,193 @@ const (
 	DT_VERNEEDNUM = elf.DT_VERSYM + 15
 )
 
+// how to show values of dynamic tags
+const (
+	DYN_HEX   = iota
+	DYN_NUM   // count or number
+	DYN_BYTES // size in bytes
+	DYN_ADDR  // address in the object
+	DYN_STR   // offset in the dynamic string table
+	DYN_NONE  // value is not used
+)
+
+type DynTagDesc struct {
+	name string
+	kind int
+}
+
+// standard and OS-specific dynamic tags
+var dynTagNames = map[elf.DynTag]DynTagDesc{
+	0:  {"NULL", DYN_NONE},
+	1:  {"NEEDED", DYN_STR},
+	2:  {"PLTRELSZ", DYN_BYTES},
+	3:  {"PLTGOT", DYN_ADDR},
+	4:  {"HASH", DYN_ADDR},
+	5:  {"STRTAB", DYN_ADDR},
+	6:  {"SYMTAB", DYN_ADDR},
+	7:  {"RELA", DYN_ADDR},
+	8:  {"RELASZ", DYN_BYTES},
+	9:  {"RELAENT", DYN_BYTES},
+	10: {"STRSZ", DYN_BYTES},
+	11: {"SYMENT", DYN_BYTES},
+	12: {"INIT", DYN_ADDR},
+	13: {"FINI", DYN_ADDR},
+	14: {"SONAME", DYN_STR},
+	15: {"RPATH", DYN_STR},
+	16: {"SYMBOLIC", DYN_NONE},
+	17: {"REL", DYN_ADDR},
+	18: {"RELSZ", DYN_BYTES},
+	19: {"RELENT", DYN_BYTES},
+	20: {"PLTREL", DYN_HEX},
+	21: {"DEBUG", DYN_HEX},
+	22: {"TEXTREL", DYN_NONE},
+	23: {"JMPREL", DYN_ADDR},
+	24: {"BIND_NOW", DYN_NONE},
+	25: {"INIT_ARRAY", DYN_ADDR},
+	26: {"FINI_ARRAY", DYN_ADDR},
+	27: {"INIT_ARRAYSZ", DYN_BYTES},
+	28: {"FINI_ARRAYSZ", DYN_BYTES},
+	29: {"RUNPATH", DYN_STR},
+	30: {"FLAGS", DYN_HEX},
+	32: {"PREINIT_ARRAY", DYN_ADDR},
+	33: {"PREINIT_ARRAYSZ", DYN_BYTES},
+	34: {"SYMTAB_SHNDX", DYN_ADDR},
+	35: {"RELRSZ", DYN_BYTES},
+	36: {"RELR", DYN_ADDR},
+	37: {"RELRENT", DYN_BYTES},
+
+	0x6ffffdf5: {"GNU_PRELINKED", DYN_HEX},
+	0x6ffffdf6: {"GNU_CONFLICTSZ", DYN_BYTES},
+	0x6ffffdf7: {"GNU_LIBLISTSZ", DYN_BYTES},
+	0x6ffffdf8: {"CHECKSUM", DYN_HEX},
+	0x6ffffdf9: {"PLTPADSZ", DYN_BYTES},
+	0x6ffffdfa: {"MOVEENT", DYN_BYTES},
+	0x6ffffdfb: {"MOVESZ", DYN_BYTES},
+	0x6ffffdfc: {"FEATURE_1", DYN_HEX},
+	0x6ffffdfd: {"POSFLAG_1", DYN_HEX},
+	0x6ffffdfe: {"SYMINSZ", DYN_BYTES},
+	0x6ffffdff: {"SYMINENT", DYN_BYTES},
+
+	0x6ffffef5: {"GNU_HASH", DYN_ADDR},
+	0x6ffffef6: {"TLSDESC_PLT", DYN_ADDR},
+	0x6ffffef7: {"TLSDESC_GOT", DYN_ADDR},
+	0x6ffffef8: {"GNU_CONFLICT", DYN_ADDR},
+	0x6ffffef9: {"GNU_LIBLIST", DYN_ADDR},
+	0x6ffffefa: {"CONFIG", DYN_STR},
+	0x6ffffefb: {"DEPAUDIT", DYN_STR},
+	0x6ffffefc: {"AUDIT", DYN_STR},
+	0x6ffffefd: {"PLTPAD", DYN_ADDR},
+	0x6ffffefe: {"MOVETAB", DYN_ADDR},
+	0x6ffffeff: {"SYMINFO", DYN_ADDR},
+
+	0x6ffffff0: {"VERSYM", DYN_ADDR},
+	0x6ffffff9: {"RELACOUNT", DYN_NUM},
+	0x6ffffffa: {"RELCOUNT", DYN_NUM},
+	0x6ffffffb: {"FLAGS_1", DYN_HEX},
+	0x6ffffffc: {"VERDEF", DYN_ADDR},
+	0x6ffffffd: {"VERDEFNUM", DYN_NUM},
+	0x6ffffffe: {"VERNEED", DYN_ADDR},
+	0x6fffffff: {"VERNEEDNUM", DYN_NUM},
+
+	0x7ffffffd: {"AUXILIARY", DYN_STR},
+	0x7ffffffe: {"USED", DYN_STR},
+	0x7fffffff: {"FILTER", DYN_STR},
+}
+
+// processor-specific dynamic tags
+var archDynTagNames = map[elf.Machine]map[elf.DynTag]DynTagDesc{
+	elf.EM_X86_64: {
+		0x70000000: {"X86_64_PLT", DYN_ADDR},
+		0x70000001: {"X86_64_PLTSZ", DYN_BYTES},
+		0x70000003: {"X86_64_PLTENT", DYN_BYTES},
+	},
+	elf.EM_AARCH64: {
+		0x70000001: {"AARCH64_BTI_PLT", DYN_NONE},
+		0x70000003: {"AARCH64_PAC_PLT", DYN_NONE},
+		0x70000005: {"AARCH64_VARIANT_PCS", DYN_NONE},
+		0x70000009: {"AARCH64_MEMTAG_MODE", DYN_HEX},
+		0x7000000b: {"AARCH64_MEMTAG_HEAP", DYN_HEX},
+		0x7000000c: {"AARCH64_MEMTAG_STACK", DYN_HEX},
+		0x7000000d: {"AARCH64_MEMTAG_GLOBALS", DYN_ADDR},
+		0x7000000f: {"AARCH64_MEMTAG_GLOBALSSZ", DYN_BYTES},
+	},
+	elf.EM_PPC: {
+		0x70000000: {"PPC_GOT", DYN_ADDR},
+		0x70000001: {"PPC_OPT", DYN_HEX},
+	},
+	elf.EM_PPC64: {
+		0x70000000: {"PPC64_GLINK", DYN_ADDR},
+		0x70000001: {"PPC64_OPD", DYN_ADDR},
+		0x70000002: {"PPC64_OPDSZ", DYN_BYTES},
+		0x70000003: {"PPC64_OPT", DYN_HEX},
+	},
+	elf.EM_RISCV: {
+		0x70000001: {"RISCV_VARIANT_CC", DYN_NONE},
+	},
+	elf.EM_MIPS: {
+		0x70000001: {"MIPS_RLD_VERSION", DYN_NUM},
+		0x70000002: {"MIPS_TIME_STAMP", DYN_HEX},
+		0x70000003: {"MIPS_ICHECKSUM", DYN_HEX},
+		0x70000004: {"MIPS_IVERSION", DYN_STR},
+		0x70000005: {"MIPS_FLAGS", DYN_HEX},
+		0x70000006: {"MIPS_BASE_ADDRESS", DYN_HEX},
+		0x70000007: {"MIPS_MSYM", DYN_ADDR},
+		0x70000008: {"MIPS_CONFLICT", DYN_ADDR},
+		0x70000009: {"MIPS_LIBLIST", DYN_ADDR},
+		0x7000000a: {"MIPS_LOCAL_GOTNO", DYN_NUM},
+		0x7000000b: {"MIPS_CONFLICTNO", DYN_NUM},
+		0x70000010: {"MIPS_LIBLISTNO", DYN_NUM},
+		0x70000011: {"MIPS_SYMTABNO", DYN_NUM},
+		0x70000012: {"MIPS_UNREFEXTNO", DYN_NUM},
+		0x70000013: {"MIPS_GOTSYM", DYN_NUM},
+		0x70000014: {"MIPS_HIPAGENO", DYN_NUM},
+		0x70000016: {"MIPS_RLD_MAP", DYN_ADDR},
+		0x70000029: {"MIPS_OPTIONS", DYN_ADDR},
+		0x70000032: {"MIPS_PLTGOT", DYN_ADDR},
+		0x70000034: {"MIPS_RWPLT", DYN_ADDR},
+		0x70000035: {"MIPS_RLD_MAP_REL", DYN_HEX},
+		0x70000036: {"MIPS_XHASH", DYN_ADDR},
+	},
+	elf.EM_SPARCV9: {
+		0x70000001: {"SPARC_REGISTER", DYN_NUM},
+	},
+	elf.EM_IA_64: {
+		0x70000000: {"IA_64_PLT_RESERVE", DYN_ADDR},
+	},
+	elf.EM_ALPHA: {
+		0x70000000: {"ALPHA_PLTRO", DYN_HEX},
+	},
+}
+
+func dynTagDesc(mach elf.Machine, tag elf.DynTag) DynTagDesc {
+	if mach == elf.EM_MIPS_RS3_LE {
+		mach = elf.EM_MIPS
+	}
+	if tag >= elf.DT_LOPROC && tag < elf.DT_AUXILIARY {
+		if d, ok := archDynTagNames[mach][tag]; ok {
+			return d
+		}
+	}
+	if d, ok := dynTagNames[tag]; ok {
+		return d
+	}
+
+	var name string
+	switch {
+	case tag >= elf.DT_LOPROC && tag <= elf.DT_HIPROC:
+		name = fmt.Sprintf("LOPROC+%#x", uint64(tag-elf.DT_LOPROC))
+	case tag >= elf.DT_LOOS && tag <= elf.DT_HIOS:
+		name = fmt.Sprintf("LOOS+%#x", uint64(tag-elf.DT_LOOS))
+	default:
+		name = fmt.Sprintf("<%#x>", uint64(tag))
+	}
+	return DynTagDesc{name, DYN_HEX}
+}
+
+func dynTagName(mach elf.Machine, tag elf.DynTag) string {
+	return "DT_" + dynTagDesc(mach, tag).name
+}
+
 // convert DT_FLAGS
 func strFlags(val uint64) string {
 	var ret []string
@@ -170,83 +468,99 @@ func strFlags1(val uint64) string {
 	if (val & 0x2000000) != 0 {
 		ret = append(ret, "SINGLETON")
 	}
+	if (val & 0x4000000) != 0 {
+		ret = append(ret, "STUB")
+	}
+	if (val & 0x8000000) != 0 {
+		ret = append(ret, "PIE")
+	}
+	if (val & 0x10000000) != 0 {
+		ret = append(ret, "KMOD")
+	}
+	if (val & 0x20000000) != 0 {
+		ret = append(ret, "WEAKFILTER")
+	}
+	if (val & 0x40000000) != 0 {
+		ret = append(ret, "NOCOMMON")
+	}
 
 	return str.Join(ret, "|")
 }
 
-func makeDynamicStrings(info *DepsInfo) []string {
-	// dynamic attributes
-	var dyns []string
-	for _, v := range info.dyns {
-		switch v.tag {
-		case elf.DT_NEEDED:
-			fallthrough
-		case elf.DT_RPATH:
-			fallthrough
-		case elf.DT_RUNPATH:
-			fallthrough
-		case elf.DT_SONAME:
-			dyns = append(dyns, fmt.Sprintf("  %-16s  %s", v.tag, v.val.(string)))
-		case DT_GNU_HASH:
-			dyns = append(dyns, fmt.Sprintf("  %-16s  %x", "DT_GNU_HASH", v.val))
-		case DT_RELACOUNT:
-			dyns = append(dyns, fmt.Sprintf("  %-16s  %v", "DT_RELACOUNT", v.val))
-		case DT_RELCOUNT:
-			dyns = append(dyns, fmt.Sprintf("  %-16s  %v", "DT_RELCOUNT", v.val))
-		case elf.DT_FLAGS:
-			dyns = append(dyns, fmt.Sprintf("  %-16s  %s", "DT_FLAGS", strFlags(v.val.(uint64))))
-		case DT_FLAGS_1:
-			dyns = append(dyns, fmt.Sprintf("  %-16s  %s", "DT_FLAGS_1", strFlags1(v.val.(uint64))))
-		case DT_VERDEF:
-			dyns = append(dyns, fmt.Sprintf("  %-16s  %x", "DT_VERDEF", v.val))
-		case DT_VERDEFNUM:
-			dyns = append(dyns, fmt.Sprintf("  %-16s  %v", "DT_VERDEFNUM", v.val))
-		case DT_VERNEED:
-			dyns = append(dyns, fmt.Sprintf("  %-16s  %x", "DT_VERNEED", v.val))
-		case DT_VERNEEDNUM:
-			dyns = append(dyns, fmt.Sprintf("  %-16s  %v", "DT_VERNEEDNUM", v.val))
-		default:
-			dyns = append(dyns, fmt.Sprintf("  %-16s  %x", v.tag, v.val))
-		}
+// convert DT_FEATURE_1 and DT_POSFLAG_1
+func strFeature1(val uint64) string {
+	var ret []string
+
+	if (val & 0x1) != 0 {
+		ret = append(ret, "PARINIT")
+	}
+	if (val & 0x2) != 0 {
+		ret = append(ret, "CONFEXP")
 	}
 
-	return dyns
+	return str.Join(ret, "|")
 }
 
-func makeSymbolString(sym elf.Symbol) string {
-	var t string
-	switch elf.ST_TYPE(sym.Info) {
-	case elf.STT_NOTYPE:
-		t = "NON"
-	case elf.STT_OBJECT:
-		t = "OBJ"
-	case elf.STT_FUNC:
-		t = "FUN"
-	case elf.STT_SECTION:
-		t = "SEC"
-	case elf.STT_FILE:
-		t = "FIL"
-	case elf.STT_COMMON:
-		t = "COM"
-	case elf.STT_TLS:
-		t = "TLS"
-	default:
-		t = "XXX"
+func strPosflag1(val uint64) string {
+	var ret []string
+
+	if (val & 0x1) != 0 {
+		ret = append(ret, "LAZYLOAD")
+	}
+	if (val & 0x2) != 0 {
+		ret = append(ret, "GROUPPERM")
 	}
 
-	var b string
-	switch elf.ST_BIND(sym.Info) {
-	case elf.STB_LOCAL:
-		b = "L"
-	case elf.STB_GLOBAL:
-		b = "G"
-	case elf.STB_WEAK:
-		b = "W"
-	default:
-		b = "X"
+	return str.Join(ret, "|")
+}
+
+// returns value of the dynamic tag in a readable form
+func dynValueString(info *DepsInfo, v *DynInfo) string {
+	if s, ok := v.val.(string); ok {
+		return s
+	}
+	val := v.val.(uint64)
+
+	switch v.tag {
+	case elf.DT_PLTREL:
+		return dynTagDesc(info.mach, elf.DynTag(val)).name
+	case elf.DT_FLAGS:
+		return strFlags(val)
+	case DT_FLAGS_1:
+		return strFlags1(val)
+	case elf.DT_FEATURE: // DT_FEATURE_1
+		return strFeature1(val)
+	case elf.DT_POSFLAG_1:
+		return strPosflag1(val)
+	case elf.DT_GNU_PRELINKED:
+		return time.Unix(int64(val), 0).UTC().Format("2006-01-02 15:04:05")
 	}
 
-	return fmt.Sprintf("  %8x %s %s %s", sym.Value, t, b, sym.Name)
+	switch dynTagDesc(info.mach, v.tag).kind {
+	case DYN_NUM:
+		return fmt.Sprintf("%d", val)
+	case DYN_BYTES:
+		return fmt.Sprintf("%d (bytes)", val)
+	case DYN_ADDR:
+		if s := addrSection(info, val); s != nil {
+			return fmt.Sprintf("%#x (%s)", val, s.Name)
+		}
+		return fmt.Sprintf("%#x", val)
+	case DYN_NONE:
+		return ""
+	}
+	return fmt.Sprintf("%#x", val)
+}
+
+func makeDynamicStrings(info *DepsInfo) []string {
+	// dynamic attributes
+	var dyns []string
+	for i := range info.dyns {
+		v := &info.dyns[i]
+		dyns = append(dyns, fmt.Sprintf("  %-20s  %s", dynTagName(info.mach, v.tag), dynValueString(info, v)))
+	}
+
+	return dyns
 }
 
 func makeSectionString(idx int, sec *elf.Section) string {
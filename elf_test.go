@@ -0,0 +1,116 @@
+/*
+ * ELF tree - Tree viewer for ELF library dependency
+ *
+ * Copyright (C) 2017-2018  Namhyung Kim <namhyung@gmail.com>
+ *
+ * Released under MIT license.
+ */
+package main
+
+import (
+	"debug/elf"
+	"strings"
+	"testing"
+)
+
+const TLS_FIXTURE = "testdata/libtls.so.1"
+
+func TestSectionInSegment(t *testing.T) {
+	f, err := elf.Open(TLS_FIXTURE)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	// section to segment mapping from readelf -l
+	want := []string{
+		".gnu.hash .dynsym .dynstr .rela.dyn .rela.plt .plt .text .eh_frame_hdr .eh_frame",
+		".tdata .init_array .fini_array .dynamic .got .got.plt .bss",
+		".dynamic",
+		".tdata .tbss",
+		".eh_frame_hdr",
+		"",
+		".tdata .init_array .fini_array .dynamic .got",
+	}
+	if len(f.Progs) != len(want) {
+		t.Fatalf("got %d segments", len(f.Progs))
+	}
+
+	for i, p := range f.Progs {
+		var names []string
+		for _, s := range f.Sections {
+			if sectionInSegment(s, p) {
+				names = append(names, s.Name)
+			}
+		}
+		if got := strings.Join(names, " "); got != want[i] {
+			t.Errorf("segment %d (%s):\n got: %s\nwant: %s", i, p.Type, got, want[i])
+		}
+	}
+}
+
+func TestDynTagDesc(t *testing.T) {
+	tests := []struct {
+		mach elf.Machine
+		tag  elf.DynTag
+		name string
+		kind int
+	}{
+		{elf.EM_X86_64, elf.DT_NEEDED, "NEEDED", DYN_STR},
+		{elf.EM_X86_64, elf.DT_USED, "USED", DYN_STR},
+		{elf.EM_X86_64, elf.DT_AUDIT, "AUDIT", DYN_STR},
+		{elf.EM_X86_64, elf.DT_STRSZ, "STRSZ", DYN_BYTES},
+		{elf.EM_X86_64, 0x70000004, "LOPROC+0x4", DYN_HEX},
+		{elf.EM_MIPS, 0x70000004, "MIPS_IVERSION", DYN_STR},
+		{elf.EM_MIPS_RS3_LE, 0x70000004, "MIPS_IVERSION", DYN_STR},
+	}
+
+	for _, tt := range tests {
+		d := dynTagDesc(tt.mach, tt.tag)
+		if d.name != tt.name || d.kind != tt.kind {
+			t.Errorf("dynTagDesc(%v, %#x) = %v, want %s (%d)", tt.mach, uint64(tt.tag), d, tt.name, tt.kind)
+		}
+	}
+}
+
+func TestReadDynamic(t *testing.T) {
+	info := loadFixture(t, FIXTURE)
+
+	found := false
+	for _, d := range info.dyns {
+		kind := dynTagDesc(info.mach, d.tag).kind
+		if _, ok := d.val.(string); ok != (kind == DYN_STR) {
+			t.Errorf("%s has a value of %T", dynTagName(info.mach, d.tag), d.val)
+		}
+		if d.tag == elf.DT_SONAME {
+			found = d.val == "libfixture.so.1"
+		}
+	}
+	if !found {
+		t.Error("no SONAME in the fixture")
+	}
+}
+
+func TestProgTypeName(t *testing.T) {
+	tests := []struct {
+		mach elf.Machine
+		typ  elf.ProgType
+		want string
+	}{
+		{elf.EM_X86_64, elf.PT_LOAD, "LOAD"},
+		{elf.EM_X86_64, elf.PT_GNU_RELRO, "GNU_RELRO"},
+		{elf.EM_X86_64, elf.PT_GNU_PROPERTY, "GNU_PROPERTY"},
+		{elf.EM_X86_64, elf.PT_GNU_MBIND_LO + 2, "GNU_MBIND+0x2"},
+		{elf.EM_X86_64, elf.PT_OPENBSD_WXNEEDED, "OPENBSD_WXNEEDED"},
+		{elf.EM_X86_64, elf.PT_SUNWSTACK, "SUNWSTACK"},
+		{elf.EM_ARM, elf.PT_ARM_EXIDX, "ARM_EXIDX"},
+		{elf.EM_RISCV, 0x70000003, "RISCV_ATTRIBUTES"},
+		{elf.EM_X86_64, 0x70000003, "LOPROC+0x3"},
+	}
+
+	for _, tt := range tests {
+		if got := progTypeName(tt.mach, tt.typ); got != tt.want {
+			t.Errorf("progTypeName(%v, %#x) = %q, want %q", tt.mach, uint32(tt.typ), got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,464 @@
+/*
+ * ELF tree - Tree viewer for ELF library dependency
+ *
+ * Copyright (C) 2017-2018  Namhyung Kim <namhyung@gmail.com>
+ *
+ * Released under MIT license.
+ */
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"debug/elf"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// FileSystem abstracts file access to look up binaries and libraries
+type FileSystem interface {
+	Open(name string) (io.ReadCloser, error)
+	OpenElf(name string) (*elf.File, error)
+	Stat(name string) (os.FileInfo, error)
+	EvalSymlinks(name string) (string, error)
+	Glob(pattern string) ([]string, error)
+	Walk(root string, fn filepath.WalkFunc) error
+}
+
+// all file lookups go through this
+var sysfs FileSystem = hostFS{}
+
+// hostFS accesses files in the host directly
+type hostFS struct{}
+
+func (hostFS) Open(name string) (io.ReadCloser, error) {
+	return os.Open(name)
+}
+
+func (hostFS) OpenElf(name string) (*elf.File, error) {
+	return elf.Open(name)
+}
+
+func (hostFS) Stat(name string) (os.FileInfo, error) {
+	return os.Stat(name)
+}
+
+func (hostFS) EvalSymlinks(name string) (string, error) {
+	return filepath.EvalSymlinks(name)
+}
+
+func (hostFS) Glob(pattern string) ([]string, error) {
+	return filepath.Glob(pattern)
+}
+
+func (hostFS) Walk(root string, fn filepath.WalkFunc) error {
+	return filepath.Walk(root, fn)
+}
+
+// rootFS accesses files under a directory as if it's the root
+type rootFS struct {
+	dir string
+}
+
+func (fs rootFS) resolve(name string) (string, error) {
+	return resolveLinks(name, func(p string) (bool, string) {
+		fi, err := os.Lstat(filepath.Join(fs.dir, p))
+		if err != nil {
+			return false, ""
+		}
+		if fi.Mode()&os.ModeSymlink == 0 {
+			return true, ""
+		}
+		link, err := os.Readlink(filepath.Join(fs.dir, p))
+		return err == nil, link
+	})
+}
+
+func (fs rootFS) Open(name string) (io.ReadCloser, error) {
+	p, err := fs.resolve(name)
+	if err != nil {
+		return nil, err
+	}
+	return os.Open(filepath.Join(fs.dir, p))
+}
+
+func (fs rootFS) OpenElf(name string) (*elf.File, error) {
+	p, err := fs.resolve(name)
+	if err != nil {
+		return nil, err
+	}
+	return elf.Open(filepath.Join(fs.dir, p))
+}
+
+func (fs rootFS) Stat(name string) (os.FileInfo, error) {
+	p, err := fs.resolve(name)
+	if err != nil {
+		return nil, err
+	}
+	return os.Stat(filepath.Join(fs.dir, p))
+}
+
+func (fs rootFS) EvalSymlinks(name string) (string, error) {
+	return fs.resolve(name)
+}
+
+func (fs rootFS) Glob(pattern string) ([]string, error) {
+	matches, err := filepath.Glob(filepath.Join(fs.dir, pattern))
+	for i, m := range matches {
+		matches[i] = "/" + strings.TrimPrefix(m, fs.dir+"/")
+	}
+	return matches, err
+}
+
+func (fs rootFS) Walk(root string, fn filepath.WalkFunc) error {
+	return filepath.Walk(filepath.Join(fs.dir, root), func(p string, fi os.FileInfo, err error) error {
+		return fn("/"+strings.TrimPrefix(p, fs.dir+"/"), fi, err)
+	})
+}
+
+// overlayFS looks up the upper file system first
+type overlayFS struct {
+	upper FileSystem
+	lower FileSystem
+}
+
+func (fs overlayFS) Open(name string) (io.ReadCloser, error) {
+	if r, err := fs.upper.Open(name); err == nil {
+		return r, nil
+	}
+	return fs.lower.Open(name)
+}
+
+func (fs overlayFS) OpenElf(name string) (*elf.File, error) {
+	if f, err := fs.upper.OpenElf(name); err == nil {
+		return f, nil
+	}
+	return fs.lower.OpenElf(name)
+}
+
+func (fs overlayFS) Stat(name string) (os.FileInfo, error) {
+	if fi, err := fs.upper.Stat(name); err == nil {
+		return fi, nil
+	}
+	return fs.lower.Stat(name)
+}
+
+func (fs overlayFS) EvalSymlinks(name string) (string, error) {
+	if p, err := fs.upper.EvalSymlinks(name); err == nil {
+		return p, nil
+	}
+	return fs.lower.EvalSymlinks(name)
+}
+
+func (fs overlayFS) Glob(pattern string) ([]string, error) {
+	upper, _ := fs.upper.Glob(pattern)
+	lower, err := fs.lower.Glob(pattern)
+
+	seen := make(map[string]bool)
+	var matches []string
+	for _, m := range append(upper, lower...) {
+		if !seen[m] {
+			seen[m] = true
+			matches = append(matches, m)
+		}
+	}
+	return matches, err
+}
+
+func (fs overlayFS) Walk(root string, fn filepath.WalkFunc) error {
+	if err := fs.upper.Walk(root, fn); err != nil {
+		return err
+	}
+	return fs.lower.Walk(root, fn)
+}
+
+type memEntry struct {
+	name  string
+	mode  os.FileMode
+	link  string // target of symlink
+	data  []byte
+	size  int64 // file size when data is not kept
+	mtime time.Time
+}
+
+// implements os.FileInfo
+func (e *memEntry) Name() string       { return path.Base(e.name) }
+func (e *memEntry) Size() int64        { return int64(len(e.data)) + e.size }
+func (e *memEntry) Mode() os.FileMode  { return e.mode }
+func (e *memEntry) ModTime() time.Time { return e.mtime }
+func (e *memEntry) IsDir() bool        { return e.mode.IsDir() }
+func (e *memEntry) Sys() interface{}   { return nil }
+
+// memFS keeps a whole (virtual) file system tree in memory
+type memFS struct {
+	files map[string]*memEntry
+}
+
+func newMemFS() *memFS {
+	fs := &memFS{files: make(map[string]*memEntry)}
+	fs.files["/"] = &memEntry{name: "/", mode: os.ModeDir | 0755}
+	return fs
+}
+
+func (fs *memFS) add(e *memEntry) {
+	// create parent directories if missing
+	for dir := path.Dir(e.name); dir != "/"; dir = path.Dir(dir) {
+		if _, ok := fs.files[dir]; ok {
+			break
+		}
+		fs.files[dir] = &memEntry{name: dir, mode: os.ModeDir | 0755}
+	}
+	fs.files[e.name] = e
+}
+
+// remove the entry and everything under it
+func (fs *memFS) remove(name string) {
+	delete(fs.files, name)
+
+	prefix := name + "/"
+	for k := range fs.files {
+		if strings.HasPrefix(k, prefix) {
+			delete(fs.files, k)
+		}
+	}
+}
+
+// follow symlinks in every component of the name.
+// lookup returns whether the path exists and its symlink target if any.
+func resolveLinks(name string, lookup func(string) (bool, string)) (string, error) {
+	name = path.Clean("/" + name)
+
+	for n := 0; n < 40; n++ {
+		parts := strings.Split(name, "/")[1:]
+		curr := "/"
+		restart := false
+
+		for i, comp := range parts {
+			if comp == "" {
+				continue
+			}
+
+			p := path.Join(curr, comp)
+			ok, link := lookup(p)
+			if !ok {
+				return "", os.ErrNotExist
+			}
+
+			if link != "" {
+				if !path.IsAbs(link) {
+					link = path.Join(curr, link)
+				}
+				name = path.Join(append([]string{link}, parts[i+1:]...)...)
+				restart = true
+				break
+			}
+			curr = p
+		}
+
+		if !restart {
+			return curr, nil
+		}
+	}
+	return "", errors.New("too many levels of symbolic links")
+}
+
+func (fs *memFS) resolve(name string) (string, *memEntry, error) {
+	p, err := resolveLinks(name, func(p string) (bool, string) {
+		e, ok := fs.files[p]
+		if !ok {
+			return false, ""
+		}
+		return true, e.link
+	})
+	if err != nil {
+		return "", nil, err
+	}
+	return p, fs.files[p], nil
+}
+
+func (fs *memFS) Open(name string) (io.ReadCloser, error) {
+	_, e, err := fs.resolve(name)
+	if err != nil {
+		return nil, err
+	}
+	if !e.mode.IsRegular() {
+		return nil, errors.New("not a regular file: " + name)
+	}
+	if e.size > 0 {
+		return nil, errors.New("contents not loaded: " + name)
+	}
+	return ioutil.NopCloser(bytes.NewReader(e.data)), nil
+}
+
+func (fs *memFS) OpenElf(name string) (*elf.File, error) {
+	_, e, err := fs.resolve(name)
+	if err != nil {
+		return nil, err
+	}
+	if !e.mode.IsRegular() {
+		return nil, errors.New("not a regular file: " + name)
+	}
+	if e.size > 0 {
+		// only non-ELF files are skipped
+		return nil, errors.New("bad magic number in " + name)
+	}
+	return elf.NewFile(bytes.NewReader(e.data))
+}
+
+func (fs *memFS) Stat(name string) (os.FileInfo, error) {
+	_, e, err := fs.resolve(name)
+	if err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+func (fs *memFS) EvalSymlinks(name string) (string, error) {
+	p, _, err := fs.resolve(name)
+	return p, err
+}
+
+func (fs *memFS) names() []string {
+	var names []string
+	for k := range fs.files {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (fs *memFS) Glob(pattern string) ([]string, error) {
+	var matches []string
+
+	for _, name := range fs.names() {
+		ok, err := path.Match(pattern, name)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			matches = append(matches, name)
+		}
+	}
+	return matches, nil
+}
+
+func (fs *memFS) Walk(root string, fn filepath.WalkFunc) error {
+	root, _, err := fs.resolve(root)
+	if err != nil {
+		return fn(root, nil, err)
+	}
+
+	for _, name := range fs.names() {
+		if name != root && !strings.HasPrefix(name, strings.TrimSuffix(root, "/")+"/") {
+			continue
+		}
+		if err := fn(name, fs.files[name], nil); err != nil && err != filepath.SkipDir {
+			return err
+		}
+	}
+	return nil
+}
+
+// contents of files read by elftree: ELF files, archives, (compressed)
+// kernel modules, ld.so.conf and depmod results.  others are not kept
+// in memory.
+func keepData(name string, head []byte) bool {
+	switch {
+	case bytes.HasPrefix(head, []byte(elf.ELFMAG)):
+		return true
+	case bytes.HasPrefix(head, []byte(AR_MAGIC)), bytes.HasPrefix(head, []byte(AR_THIN_MAGIC)):
+		return true
+	case isKernelModule(name), strings.HasPrefix(path.Base(name), "modules."):
+		return true
+	}
+	return strings.HasPrefix(name, "/etc/")
+}
+
+// apply a layer tarball on top of the file system
+func (fs *memFS) applyLayer(r io.Reader) error {
+	added := make(map[string]bool)
+
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		name := path.Clean("/" + hdr.Name)
+		dir := path.Dir(name)
+		base := path.Base(name)
+
+		// opaque directory hides everything from lower layers
+		if base == ".wh..wh..opq" {
+			prefix := strings.TrimSuffix(dir, "/") + "/"
+			for k := range fs.files {
+				if k != "/" && strings.HasPrefix(k, prefix) && !added[k] {
+					delete(fs.files, k)
+				}
+			}
+			continue
+		}
+		// whiteout removes the file from lower layers
+		if strings.HasPrefix(base, ".wh.") {
+			fs.remove(path.Join(dir, base[4:]))
+			continue
+		}
+
+		e := &memEntry{name: name, mode: hdr.FileInfo().Mode(), mtime: hdr.ModTime}
+
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if old, ok := fs.files[name]; ok && old.mode.IsDir() {
+				continue
+			}
+		case tar.TypeSymlink:
+			e.link = hdr.Linkname
+		case tar.TypeLink:
+			target, ok := fs.files[path.Clean("/"+hdr.Linkname)]
+			if !ok {
+				continue
+			}
+			e.mode = target.mode
+			e.data = target.data
+			e.size = target.size
+		case tar.TypeReg, tar.TypeRegA:
+			head := make([]byte, 8)
+			n, err := io.ReadFull(tr, head)
+			if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+				return err
+			}
+
+			if !keepData(name, head[:n]) {
+				e.size = hdr.Size
+				break
+			}
+
+			rest, err := ioutil.ReadAll(tr)
+			if err != nil {
+				return err
+			}
+			e.data = append(head[:n], rest...)
+		default:
+			// ignore device and fifo files
+			continue
+		}
+
+		if old, ok := fs.files[name]; ok && old.mode.IsDir() && !e.mode.IsDir() {
+			fs.remove(name)
+		}
+		fs.add(e)
+		added[name] = true
+	}
+	return nil
+}
@@ -0,0 +1,21 @@
+module github.com/namhyung/elftree
+
+go 1.22
+
+require (
+	github.com/airking05/termui v0.0.0-00010101000000-000000000000
+	github.com/klauspost/compress v1.18.0
+	github.com/ulikunitz/xz v0.5.15
+	golang.org/x/arch v0.11.0
+)
+
+require (
+	github.com/maruel/panicparse v0.0.0-20170227222818-25bcac0d793c // indirect
+	github.com/mattn/go-runewidth v0.0.0-20161012013512-737072b4e32b // indirect
+	github.com/mitchellh/go-wordwrap v0.0.0-20150314170334-ad45545899c7 // indirect
+	github.com/nsf/termbox-go v0.0.0-20170211012700-3540b76b9c77 // indirect
+)
+
+// github.com/airking05/termui (for termui bug #177) is not in the module
+// proxy anymore, build it from upstream termui which has the same API.
+replace github.com/airking05/termui => github.com/gizak/termui v0.0.0-20170117222342-991cd3d38091
@@ -0,0 +1,252 @@
+/*
+ * ELF tree - Tree viewer for ELF library dependency
+ *
+ * Copyright (C) 2017-2018  Namhyung Kim <namhyung@gmail.com>
+ *
+ * Released under MIT license.
+ */
+package main
+
+import (
+	"bytes"
+	"debug/buildinfo"
+	"debug/elf"
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"runtime/debug"
+	"sort"
+	"strings"
+)
+
+type CgoLink struct {
+	pkg    string
+	module string
+	lib    string // library defining the functions
+	funcs  []string
+}
+
+// Go wrapper of C function: <package>._Cfunc_<name> or
+// <package>._cgo_<hash>_Cfunc_<name>
+var cgoFunc = regexp.MustCompile(`^(.+?)\.(?:_cgo_[0-9a-f]+)?_Cfunc_([A-Za-z0-9]\w*?)(?:\.abi0)?$`)
+
+func isGoBinary(f *elf.File) bool {
+	if f.Section(".go.buildinfo") != nil || f.Section(".note.go.buildid") != nil {
+		return true
+	}
+	for _, n := range readNotes(f) {
+		if n.name == "Go" && n.kind == NT_GO_BUILD_ID {
+			return true
+		}
+	}
+	return false
+}
+
+// read build info embedded by the Go linker
+func readGoInfo(pathname string) *buildinfo.BuildInfo {
+	r, err := sysfs.Open(pathname)
+	if err != nil {
+		return nil
+	}
+	defer r.Close()
+
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil
+	}
+
+	bi, err := buildinfo.Read(bytes.NewReader(data))
+	if err != nil {
+		return nil
+	}
+	return bi
+}
+
+func goSetting(bi *buildinfo.BuildInfo, key string) string {
+	for _, s := range bi.Settings {
+		if s.Key == key {
+			return s.Value
+		}
+	}
+	return ""
+}
+
+// returns module providing the package
+func goModule(bi *buildinfo.BuildInfo, pkg string) string {
+	best := ""
+	mods := append([]*debug.Module{&bi.Main}, bi.Deps...)
+	for _, m := range mods {
+		if m.Path == "" || len(m.Path) <= len(best) {
+			continue
+		}
+		if pkg == m.Path || strings.HasPrefix(pkg, m.Path+"/") {
+			best = m.Path
+		}
+	}
+
+	// standard packages have no dot in the first path element
+	if best == "" && !strings.Contains(strings.Split(pkg, "/")[0], ".") {
+		best = "std"
+	}
+	return best
+}
+
+// returns object in the load order defining the C function
+func cgoProvider(name string, fn string) string {
+	for _, lib := range loadOrder(name) {
+		info := deps[lib]
+
+		if lib == name {
+			// functions linked (or defined in the preamble) into the binary itself
+			for _, s := range info.syms {
+				if s.Name == fn && s.Section != elf.SHN_UNDEF && elf.ST_TYPE(s.Info) == elf.STT_FUNC {
+					return lib
+				}
+			}
+			continue
+		}
+
+		for _, s := range info.dsym {
+			if s.Name == fn && s.Section != elf.SHN_UNDEF && elf.ST_BIND(s.Info) != elf.STB_LOCAL {
+				return lib
+			}
+		}
+	}
+	return ""
+}
+
+// find C functions called by Go packages and libraries providing them
+func cgoLinks(name string) []CgoLink {
+	info := deps[name]
+	if info.goinfo == nil {
+		return nil
+	}
+
+	links := make(map[string]*CgoLink)
+	seen := make(map[string]bool)
+	for _, s := range info.syms {
+		m := cgoFunc.FindStringSubmatch(s.Name)
+		if m == nil {
+			continue
+		}
+		pkg, fn := m[1], m[2]
+
+		lib := cgoProvider(name, fn)
+		if lib == name {
+			lib = "(linked statically)"
+		} else if lib == "" {
+			lib = "(not in libraries)"
+		}
+
+		key := pkg + "\x00" + lib
+		cl, ok := links[key]
+		if !ok {
+			cl = &CgoLink{pkg: pkg, module: goModule(info.goinfo, pkg), lib: lib}
+			links[key] = cl
+		}
+		if !seen[pkg+"."+fn] {
+			seen[pkg+"."+fn] = true
+			cl.funcs = append(cl.funcs, fn)
+		}
+	}
+
+	var ret []CgoLink
+	for _, cl := range links {
+		sort.Strings(cl.funcs)
+		ret = append(ret, *cl)
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		if ret[i].pkg != ret[j].pkg {
+			return ret[i].pkg < ret[j].pkg
+		}
+		return ret[i].lib < ret[j].lib
+	})
+	return ret
+}
+
+func moduleString(m *debug.Module) string {
+	s := fmt.Sprintf("  %s  %s", m.Path, m.Version)
+	if m.Sum != "" {
+		s += "  " + m.Sum
+	}
+	if m.Replace != nil {
+		s += fmt.Sprintf("  => %s %s", m.Replace.Path, m.Replace.Version)
+	}
+	return s
+}
+
+func makeGoStrings(name string) (summary, mods, settings, cgo []string) {
+	bi := deps[name].goinfo
+
+	summary = append(summary, "  Go version: "+bi.GoVersion)
+	if bi.Path != "" {
+		summary = append(summary, "  Package: "+bi.Path)
+	}
+	if bi.Main.Path != "" {
+		summary = append(summary, "  Main module: "+bi.Main.Path+" "+bi.Main.Version)
+	}
+
+	for _, m := range bi.Deps {
+		mods = append(mods, moduleString(m))
+	}
+	for _, s := range bi.Settings {
+		settings = append(settings, fmt.Sprintf("  %s=%s", s.Key, s.Value))
+	}
+
+	for _, cl := range cgoLinks(name) {
+		cgo = append(cgo, fmt.Sprintf("  %s (%s)  => %s: %s",
+			cl.pkg, cl.module, cl.lib, strings.Join(cl.funcs, " ")))
+	}
+	return
+}
+
+func printGoInfo(name string) {
+	summary, mods, settings, cgo := makeGoStrings(name)
+
+	fmt.Println()
+	fmt.Println("Go build info:")
+	for _, s := range summary {
+		fmt.Println(s)
+	}
+
+	fmt.Printf("Modules (%d):\n", len(mods))
+	for _, s := range mods {
+		fmt.Println(s)
+	}
+
+	fmt.Println("Build settings:")
+	for _, s := range settings {
+		fmt.Println(s)
+	}
+
+	if goSetting(deps[name].goinfo, "CGO_ENABLED") == "1" {
+		fmt.Println("Cgo libraries:")
+		for _, s := range cgo {
+			fmt.Println(s)
+		}
+	}
+}
+
+// note libraries used by cgo packages in the tree
+func markCgoLibs(roots []*DepsNode) {
+	for _, root := range roots {
+		for _, cl := range cgoLinks(root.name) {
+			if _, ok := deps[cl.lib]; !ok || cl.lib == root.name {
+				continue
+			}
+
+			if depsNotes == nil {
+				depsNotes = make(map[string]string)
+			}
+			old, ok := depsNotes[cl.lib]
+			switch {
+			case !ok:
+				depsNotes[cl.lib] = "cgo: " + cl.module
+			case strings.HasPrefix(old, "cgo: "):
+				if !strings.Contains(old[5:]+", ", cl.module+", ") {
+					depsNotes[cl.lib] = old + ", " + cl.module
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,112 @@
+/*
+ * ELF tree - Tree viewer for ELF library dependency
+ *
+ * Copyright (C) 2017-2018  Namhyung Kim <namhyung@gmail.com>
+ *
+ * Released under MIT license.
+ */
+package main
+
+import (
+	"debug/buildinfo"
+	"debug/elf"
+	"os"
+	"reflect"
+	"runtime"
+	"runtime/debug"
+	"testing"
+)
+
+func TestReadGoInfo(t *testing.T) {
+	exe, err := os.Executable()
+	if err != nil {
+		t.Skip(err)
+	}
+
+	bi := readGoInfo(exe)
+	if bi == nil {
+		t.Fatalf("no build info in %s", exe)
+	}
+	if bi.GoVersion != runtime.Version() {
+		t.Errorf("got %s, want %s", bi.GoVersion, runtime.Version())
+	}
+	if goSetting(bi, "GOOS") != runtime.GOOS {
+		t.Errorf("GOOS: got %q", goSetting(bi, "GOOS"))
+	}
+
+	if readGoInfo("/nonexistent") != nil {
+		t.Error("got build info of missing file")
+	}
+}
+
+func testBuildInfo() *buildinfo.BuildInfo {
+	return &buildinfo.BuildInfo{
+		Main: debug.Module{Path: "example.com/app"},
+		Deps: []*debug.Module{
+			{Path: "github.com/mattn/go-sqlite3", Version: "v1.14.22"},
+			{Path: "example.com/app/plugin", Version: "v0.1.0"},
+		},
+	}
+}
+
+func TestGoModule(t *testing.T) {
+	bi := testBuildInfo()
+
+	tests := map[string]string{
+		"github.com/mattn/go-sqlite3":     "github.com/mattn/go-sqlite3",
+		"example.com/app/internal/db":     "example.com/app",
+		"example.com/app/plugin/sqlite":   "example.com/app/plugin", // longest match
+		"example.com/application":         "",
+		"net":                             "std",
+		"runtime/cgo":                     "std",
+		"github.com/mattn/go-sqlite3-ext": "",
+	}
+	for pkg, want := range tests {
+		if got := goModule(bi, pkg); got != want {
+			t.Errorf("%s: got %q, want %q", pkg, got, want)
+		}
+	}
+}
+
+func TestCgoLinks(t *testing.T) {
+	fn := func(name string, sect elf.SectionIndex) elf.Symbol {
+		return elf.Symbol{Name: name, Section: sect,
+			Info: elf.ST_INFO(elf.STB_GLOBAL, elf.STT_FUNC)}
+	}
+
+	deps = map[string]DepsInfo{
+		"app": {
+			libs: []string{"libsqlite3.so.0", "libc.so.6"},
+			syms: []elf.Symbol{
+				fn("github.com/mattn/go-sqlite3._Cfunc_sqlite3_open_v2", 1),
+				fn("github.com/mattn/go-sqlite3._Cfunc_sqlite3_close", 1),
+				fn("github.com/mattn/go-sqlite3._Cfunc_sqlite3_close.abi0", 1),
+				fn("github.com/mattn/go-sqlite3._cgo_3a42ad434848_Cfunc_getenv", 1),
+				fn("net._Cfunc_getaddrinfo", 1),
+				fn("example.com/app._Cfunc_helper", 1),
+				fn("helper", 1),
+				fn("main.main", 1),
+			},
+			goinfo: testBuildInfo(),
+		},
+		"libsqlite3.so.0": {
+			libs: []string{"libc.so.6"},
+			dsym: []elf.Symbol{fn("sqlite3_open_v2", 10), fn("sqlite3_close", 10),
+				fn("getenv", elf.SHN_UNDEF)},
+		},
+		"libc.so.6": {
+			dsym: []elf.Symbol{fn("getenv", 10), fn("getaddrinfo", 10)},
+		},
+	}
+
+	want := []CgoLink{
+		{pkg: "example.com/app", module: "example.com/app", lib: "(linked statically)", funcs: []string{"helper"}},
+		{pkg: "github.com/mattn/go-sqlite3", module: "github.com/mattn/go-sqlite3", lib: "libc.so.6", funcs: []string{"getenv"}},
+		{pkg: "github.com/mattn/go-sqlite3", module: "github.com/mattn/go-sqlite3", lib: "libsqlite3.so.0",
+			funcs: []string{"sqlite3_close", "sqlite3_open_v2"}},
+		{pkg: "net", module: "std", lib: "libc.so.6", funcs: []string{"getaddrinfo"}},
+	}
+	if got := cgoLinks("app"); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v\nwant %+v", got, want)
+	}
+}
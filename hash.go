@@ -0,0 +1,401 @@
+/*
+ * ELF tree - Tree viewer for ELF library dependency
+ *
+ * Copyright (C) 2017-2018  Namhyung Kim <namhyung@gmail.com>
+ *
+ * Released under MIT license.
+ */
+package main
+
+import (
+	"debug/elf"
+	"fmt"
+	"math/bits"
+)
+
+const HASH_MAX_CHAIN = 16 // longer chains are shown together in the histogram
+
+type GnuHash struct {
+	symoff uint32 // index of the first hashed symbol
+	shift  uint32 // bloom filter shift
+	bloom  []uint64
+	bits   uint32 // bits in a bloom word
+	bucket []uint32
+	chain  []uint32 // hash values of symbols from symoff
+}
+
+type SysvHash struct {
+	bucket []uint32
+	chain  []uint32
+}
+
+func gnuHashOf(name string) uint32 {
+	h := uint32(5381)
+	for i := 0; i < len(name); i++ {
+		h = h*33 + uint32(name[i])
+	}
+	return h
+}
+
+func sysvHashOf(name string) uint32 {
+	var h uint32
+	for i := 0; i < len(name); i++ {
+		h = (h << 4) + uint32(name[i])
+		g := h & 0xf0000000
+		if g != 0 {
+			h ^= g >> 24
+		}
+		h &^= g
+	}
+	return h
+}
+
+// returns index of the hash section from the dynamic tag (or the name)
+func hashSection(info *DepsInfo, tag elf.DynTag, name string) int {
+	for _, d := range info.dyns {
+		if d.tag != tag {
+			continue
+		}
+		if s := addrSection(info, d.val.(uint64)); s != nil {
+			return findSection(info.sect, s.Name)
+		}
+	}
+	if len(info.dyns) == 0 {
+		return findSection(info.sect, name)
+	}
+	return -1
+}
+
+func readGnuHash(info *DepsInfo) (*GnuHash, error) {
+	idx := hashSection(info, DT_GNU_HASH, ".gnu.hash")
+	if idx < 0 {
+		return nil, nil
+	}
+
+	data, err := readSectionData(info.path, info.sect, idx)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < 16 {
+		return nil, fmt.Errorf("invalid .gnu.hash size: %d", len(data))
+	}
+
+	bo := info.endian
+	nbucket := bo.Uint32(data[0:])
+	h := &GnuHash{symoff: bo.Uint32(data[4:]), shift: bo.Uint32(data[12:]), bits: 64}
+	nbloom := bo.Uint32(data[8:])
+
+	word := 8
+	if info.bits == elf.ELFCLASS32 {
+		word = 4
+		h.bits = 32
+	}
+
+	off := 16
+	if uint64(off)+uint64(nbloom)*uint64(word)+uint64(nbucket)*4 > uint64(len(data)) {
+		return nil, fmt.Errorf("invalid .gnu.hash: %d buckets, %d bloom words", nbucket, nbloom)
+	}
+
+	for i := uint32(0); i < nbloom; i++ {
+		if word == 4 {
+			h.bloom = append(h.bloom, uint64(bo.Uint32(data[off:])))
+		} else {
+			h.bloom = append(h.bloom, bo.Uint64(data[off:]))
+		}
+		off += word
+	}
+	for i := uint32(0); i < nbucket; i++ {
+		h.bucket = append(h.bucket, bo.Uint32(data[off:]))
+		off += 4
+	}
+	for ; off+4 <= len(data); off += 4 {
+		h.chain = append(h.chain, bo.Uint32(data[off:]))
+	}
+	return h, nil
+}
+
+func readSysvHash(info *DepsInfo) (*SysvHash, error) {
+	idx := hashSection(info, elf.DT_HASH, ".hash")
+	if idx < 0 {
+		return nil, nil
+	}
+
+	data, err := readSectionData(info.path, info.sect, idx)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < 8 {
+		return nil, fmt.Errorf("invalid .hash size: %d", len(data))
+	}
+
+	bo := info.endian
+	nbucket := uint64(bo.Uint32(data[0:]))
+	nchain := uint64(bo.Uint32(data[4:]))
+	if 8+(nbucket+nchain)*4 > uint64(len(data)) {
+		return nil, fmt.Errorf("invalid .hash: %d buckets, %d chains", nbucket, nchain)
+	}
+
+	h := &SysvHash{}
+	off := 8
+	for i := uint64(0); i < nbucket; i++ {
+		h.bucket = append(h.bucket, bo.Uint32(data[off:]))
+		off += 4
+	}
+	for i := uint64(0); i < nchain; i++ {
+		h.chain = append(h.chain, bo.Uint32(data[off:]))
+		off += 4
+	}
+	return h, nil
+}
+
+// length of the chain in each bucket
+func (h *GnuHash) chainLens() []int {
+	var lens []int
+	for _, start := range h.bucket {
+		n := 0
+		if start >= h.symoff {
+			for i := start - h.symoff; int(i) < len(h.chain); i++ {
+				n++
+				if h.chain[i]&1 != 0 {
+					break // end of the chain
+				}
+			}
+		}
+		lens = append(lens, n)
+	}
+	return lens
+}
+
+func (h *SysvHash) chainLens() []int {
+	var lens []int
+	for _, start := range h.bucket {
+		n := 0
+		for i := start; i != 0 && int(i) < len(h.chain) && n < len(h.chain); i = h.chain[i] {
+			n++
+		}
+		lens = append(lens, n)
+	}
+	return lens
+}
+
+// histogram of chain lengths and the average cost of lookups
+func makeChainStrings(lens []int) []string {
+	var hist [HASH_MAX_CHAIN + 1]int
+	var nsyms, used, longest, cost int
+
+	for _, n := range lens {
+		if n < HASH_MAX_CHAIN {
+			hist[n]++
+		} else {
+			hist[HASH_MAX_CHAIN]++
+		}
+		nsyms += n
+		// a symbol in the chain needs comparisons up to its position
+		cost += n * (n + 1) / 2
+		if n > 0 {
+			used++
+		}
+		if n > longest {
+			longest = n
+		}
+	}
+
+	lines := []string{fmt.Sprintf("  Buckets: %d (%d used), Symbols: %d, Longest chain: %d",
+		len(lens), used, nsyms, longest)}
+	if nsyms == 0 {
+		return lines
+	}
+
+	lines = append(lines, fmt.Sprintf("  Average lookup: %.2f comparisons if found, %.2f if not (chain length)",
+		float64(cost)/float64(nsyms), float64(nsyms)/float64(used)))
+
+	lines = append(lines, fmt.Sprintf("  %8s  %8s  %6s  %8s", "Length", "Buckets", "%", "Coverage"))
+	covered := 0
+	for n, cnt := range hist {
+		if cnt == 0 {
+			continue
+		}
+		covered += n * cnt
+		length := fmt.Sprintf("%d", n)
+		if n == HASH_MAX_CHAIN {
+			length = fmt.Sprintf(">=%d", n)
+			covered = nsyms
+		}
+		lines = append(lines, fmt.Sprintf("  %8s  %8d  %5.1f%%  %7.1f%%", length, cnt,
+			float64(cnt)*100/float64(len(lens)), float64(covered)*100/float64(nsyms)))
+	}
+	return lines
+}
+
+func makeGnuHashStrings(h *GnuHash) []string {
+	var set int
+	for _, w := range h.bloom {
+		set += bits.OnesCount64(w)
+	}
+	total := len(h.bloom) * int(h.bits)
+
+	ratio := 0.0
+	if total > 0 {
+		ratio = float64(set) / float64(total)
+	}
+
+	lines := []string{fmt.Sprintf("  Bloom filter: %d words (%d bits), shift %d, %.1f%% bits set",
+		len(h.bloom), total, h.shift, ratio*100)}
+
+	// a missing symbol passes the filter if both bits are set
+	lines = append(lines, fmt.Sprintf("  Bloom false positive: %.1f%% (of lookups for missing symbols)",
+		ratio*ratio*100))
+	lines = append(lines, fmt.Sprintf("  First hashed symbol: %d", h.symoff))
+
+	return append(lines, makeChainStrings(h.chainLens())...)
+}
+
+func missingGnuHash(info *DepsInfo) bool {
+	if info.kind != elf.ET_DYN && info.kind != elf.ET_EXEC {
+		return false
+	}
+	if len(info.dsym) == 0 {
+		return false
+	}
+	return hashSection(info, DT_GNU_HASH, ".gnu.hash") < 0
+}
+
+// returns hash table statistics of the object
+func makeHashStrings(name string, info *DepsInfo) []string {
+	var lines []string
+
+	gnu, err := readGnuHash(info)
+	if err != nil {
+		lines = append(lines, "  error: "+err.Error())
+	} else if gnu != nil {
+		lines = append(lines, "  GNU hash (.gnu.hash):")
+		lines = append(lines, makeGnuHashStrings(gnu)...)
+	}
+
+	sysv, err := readSysvHash(info)
+	if err != nil {
+		lines = append(lines, "  error: "+err.Error())
+	} else if sysv != nil {
+		lines = append(lines, "  SysV hash (.hash):")
+		lines = append(lines, makeChainStrings(sysv.chainLens())...)
+	}
+
+	if missingGnuHash(info) {
+		lines = append(lines, "  warning: no DT_GNU_HASH, symbol binding is slower with SysV hash")
+	}
+	if len(lines) == 0 {
+		lines = append(lines, "  (none)")
+	}
+	return lines
+}
+
+// symbol found by the lookup (index in the dynamic symbol table)
+func lookupDefined(info *DepsInfo, idx uint32, sym string) bool {
+	// DynamicSymbols() skips the first (null) symbol
+	if idx == 0 || int(idx) > len(info.dsym) {
+		return false
+	}
+	s := &info.dsym[idx-1]
+	if s.Name != sym {
+		return false
+	}
+	// ld.so skips undefined and local symbols
+	return s.Section != elf.SHN_UNDEF && elf.ST_BIND(s.Info) != elf.STB_LOCAL
+}
+
+// look up the symbol in the object like ld.so does, returns steps and the symbol index
+func lookupHash(info *DepsInfo, sym string) ([]string, uint32) {
+	gnu, err := readGnuHash(info)
+	if err != nil {
+		return []string{"error: " + err.Error()}, 0
+	}
+
+	if gnu != nil && len(gnu.bucket) > 0 && len(gnu.bloom) > 0 {
+		h := gnuHashOf(sym)
+		var steps []string
+
+		word := gnu.bloom[(h/gnu.bits)%uint32(len(gnu.bloom))]
+		mask := uint64(1)<<(h%gnu.bits) | uint64(1)<<((h>>gnu.shift)%gnu.bits)
+		if word&mask != mask {
+			return append(steps, fmt.Sprintf("gnu hash %#08x: rejected by bloom filter", h)), 0
+		}
+
+		b := h % uint32(len(gnu.bucket))
+		start := gnu.bucket[b]
+		if start < gnu.symoff {
+			return append(steps, fmt.Sprintf("gnu hash %#08x: bucket %d is empty (bloom false positive)", h, b)), 0
+		}
+
+		cmp := 0
+		for i := start; int(i-gnu.symoff) < len(gnu.chain); i++ {
+			h2 := gnu.chain[i-gnu.symoff]
+			cmp++
+			if h|1 == h2|1 && lookupDefined(info, i, sym) {
+				return append(steps, fmt.Sprintf("gnu hash %#08x: bucket %d, found symbol %d after %d comparisons",
+					h, b, i, cmp)), i
+			}
+			if h2&1 != 0 {
+				break
+			}
+		}
+		return append(steps, fmt.Sprintf("gnu hash %#08x: bucket %d, not found after %d comparisons",
+			h, b, cmp)), 0
+	}
+
+	sysv, err := readSysvHash(info)
+	if err != nil {
+		return []string{"error: " + err.Error()}, 0
+	}
+	if sysv == nil || len(sysv.bucket) == 0 {
+		return []string{"no hash table"}, 0
+	}
+
+	h := sysvHashOf(sym)
+	b := h % uint32(len(sysv.bucket))
+
+	cmp := 0
+	for i := sysv.bucket[b]; i != 0 && int(i) < len(sysv.chain) && cmp < len(sysv.chain); i = sysv.chain[i] {
+		cmp++
+		if lookupDefined(info, i, sym) {
+			return []string{fmt.Sprintf("sysv hash %#08x: bucket %d, found symbol %d after %d string comparisons",
+				h, b, i, cmp)}, i
+		}
+	}
+	return []string{fmt.Sprintf("sysv hash %#08x: bucket %d, not found after %d string comparisons",
+		h, b, cmp)}, 0
+}
+
+// look up the symbol in the global search scope like ld.so does
+func printLookup(root *DepsNode, sym string) {
+	fmt.Printf("Lookup of %s:\n", sym)
+
+	for i, name := range loadOrder(root.name) {
+		info := deps[name]
+		steps, idx := lookupHash(&info, sym)
+
+		for _, s := range steps {
+			fmt.Printf("  %3d  %-24s %s\n", i+1, name, s)
+		}
+		if idx != 0 {
+			s := &info.dsym[idx-1]
+			fmt.Printf("\n%s is bound to %s%s at %#x in %s\n", sym, symName(s.Name), symVersion(s), s.Value, name)
+			return
+		}
+	}
+	fmt.Printf("\n%s is not found\n", sym)
+}
+
+func printHashStats(root *DepsNode) {
+	for _, name := range loadOrder(root.name) {
+		info := deps[name]
+		if info.endian == nil {
+			continue
+		}
+
+		fmt.Printf("%s:\n", name)
+		for _, s := range makeHashStrings(name, &info) {
+			fmt.Println(s)
+		}
+		fmt.Println()
+	}
+}
@@ -0,0 +1,105 @@
+/*
+ * ELF tree - Tree viewer for ELF library dependency
+ *
+ * Copyright (C) 2017-2018  Namhyung Kim <namhyung@gmail.com>
+ *
+ * Released under MIT license.
+ */
+package main
+
+import (
+	"sort"
+	"testing"
+)
+
+const FIXTURE_SYSV = "testdata/libfixture-sysv.so.1"
+
+func TestHashOf(t *testing.T) {
+	tests := []struct {
+		name string
+		gnu  uint32
+		sysv uint32
+	}{
+		{"", 0x00001505, 0x00000000},
+		{"foo", 0x0b887389, 0x00006d5f},
+		{"printf", 0x156b2bb8, 0x077905a6},
+		{"GLIBC_2.2.5", 0x427315ba, 0x09691a75},
+		{"_ZNSt8ios_base4InitC1Ev", 0x4cd4b8c7, 0x0c0d71d6},
+	}
+
+	for _, tt := range tests {
+		if h := gnuHashOf(tt.name); h != tt.gnu {
+			t.Errorf("gnuHashOf(%q) = %#08x, want %#08x", tt.name, h, tt.gnu)
+		}
+		if h := sysvHashOf(tt.name); h != tt.sysv {
+			t.Errorf("sysvHashOf(%q) = %#08x, want %#08x", tt.name, h, tt.sysv)
+		}
+	}
+}
+
+func TestReadHash(t *testing.T) {
+	info := loadFixture(t, FIXTURE)
+
+	gnu, err := readGnuHash(info)
+	if err != nil || gnu == nil {
+		t.Fatalf("readGnuHash() = %v, %v", gnu, err)
+	}
+	sysv, err := readSysvHash(info)
+	if err != nil || sysv == nil {
+		t.Fatalf("readSysvHash() = %v, %v", sysv, err)
+	}
+
+	// from readelf -I
+	tests := []struct {
+		name string
+		lens []int
+		want []int
+	}{
+		{"gnu", gnu.chainLens(), []int{1, 2, 3}},
+		{"sysv", sysv.chainLens(), []int{2, 2, 2}},
+	}
+	for _, tt := range tests {
+		sort.Ints(tt.lens)
+		if len(tt.lens) != len(tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, tt.lens, tt.want)
+			continue
+		}
+		for i := range tt.lens {
+			if tt.lens[i] != tt.want[i] {
+				t.Errorf("%s: got %v, want %v", tt.name, tt.lens, tt.want)
+				break
+			}
+		}
+	}
+}
+
+func TestLookupHash(t *testing.T) {
+	// symbol index from readelf --dyn-syms
+	tests := []struct {
+		path string
+		sym  string
+		idx  uint32
+	}{
+		{FIXTURE, "foo", 2},
+		{FIXTURE, "baz", 6},
+		{FIXTURE, "ptrs", 1},
+		{FIXTURE, "nosuch", 0},
+		{FIXTURE, "a", 0}, // static
+		{FIXTURE_SYSV, "foo", 5},
+		{FIXTURE_SYSV, "baz", 1},
+		{FIXTURE_SYSV, "printf_wrapper", 4},
+		{FIXTURE_SYSV, "nosuch", 0},
+	}
+
+	for _, tt := range tests {
+		info := loadFixture(t, tt.path)
+
+		steps, idx := lookupHash(info, tt.sym)
+		if idx != tt.idx {
+			t.Errorf("%s: lookupHash(%q) = %d, want %d (%v)", tt.path, tt.sym, idx, tt.idx, steps)
+		}
+		if len(steps) == 0 {
+			t.Errorf("%s: lookupHash(%q) has no steps", tt.path, tt.sym)
+		}
+	}
+}
@@ -0,0 +1,242 @@
+/*
+ * ELF tree - Tree viewer for ELF library dependency
+ *
+ * Copyright (C) 2017-2018  Namhyung Kim <namhyung@gmail.com>
+ *
+ * Released under MIT license.
+ */
+package main
+
+import (
+	"archive/tar"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+)
+
+// docker save: manifest.json
+type dockerManifest struct {
+	Config string
+	Layers []string
+}
+
+type ociDescriptor struct {
+	MediaType string `json:"mediaType"`
+	Digest    string `json:"digest"`
+}
+
+// OCI image layout: index.json and image manifest
+type ociManifest struct {
+	MediaType string          `json:"mediaType"`
+	Manifests []ociDescriptor `json:"manifests"`
+	Config    ociDescriptor   `json:"config"`
+	Layers    []ociDescriptor `json:"layers"`
+}
+
+type imageConfig struct {
+	Config struct {
+		Env []string
+	} `json:"config"`
+}
+
+// opens a file in the image archive (tarball or directory)
+type imageReader func(name string) (io.ReadCloser, error)
+
+// position of a file in the tarball
+type tarSection struct {
+	off  int64
+	size int64
+}
+
+// files in the tarball are read when needed, not to keep all layers in memory
+func tarballReader(f *os.File) (imageReader, error) {
+	files := make(map[string]tarSection)
+	links := make(map[string]string)
+
+	tr := tar.NewReader(f)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		name := path.Clean(hdr.Name)
+		switch hdr.Typeflag {
+		case tar.TypeReg, tar.TypeRegA:
+			// the header is read, it's the start of the data
+			off, err := f.Seek(0, io.SeekCurrent)
+			if err != nil {
+				return nil, err
+			}
+			files[name] = tarSection{off, hdr.Size}
+		case tar.TypeSymlink:
+			// docker save links the same layers (<id>/layer.tar)
+			links[name] = path.Join(path.Dir(name), hdr.Linkname)
+		case tar.TypeLink:
+			links[name] = path.Clean(hdr.Linkname)
+		}
+	}
+
+	return func(name string) (io.ReadCloser, error) {
+		name = path.Clean(name)
+		for n := 0; n < 40; n++ {
+			target, ok := links[name]
+			if !ok {
+				break
+			}
+			name = target
+		}
+
+		s, ok := files[name]
+		if !ok {
+			return nil, fmt.Errorf("%s: not found in %s", name, f.Name())
+		}
+		return ioutil.NopCloser(io.NewSectionReader(f, s.off, s.size)), nil
+	}, nil
+}
+
+func directoryReader(dir string) imageReader {
+	return func(name string) (io.ReadCloser, error) {
+		return os.Open(path.Join(dir, name))
+	}
+}
+
+func readImageFile(read imageReader, name string) ([]byte, error) {
+	r, err := read(name)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	return ioutil.ReadAll(r)
+}
+
+func blobPath(digest string) string {
+	return path.Join("blobs", strings.Replace(digest, ":", "/", 1))
+}
+
+// returns config and layer file names in the image
+func readImageManifest(read imageReader) (string, []string, error) {
+	if data, err := readImageFile(read, "manifest.json"); err == nil {
+		var manifests []dockerManifest
+		if err := json.Unmarshal(data, &manifests); err != nil {
+			return "", nil, err
+		}
+		if len(manifests) == 0 {
+			return "", nil, errors.New("no image in manifest.json")
+		}
+		return manifests[0].Config, manifests[0].Layers, nil
+	}
+
+	data, err := readImageFile(read, "index.json")
+	if err != nil {
+		return "", nil, errors.New("neither manifest.json nor index.json found")
+	}
+
+	// follow (nested) index to the first image manifest
+	for {
+		var m ociManifest
+		if err := json.Unmarshal(data, &m); err != nil {
+			return "", nil, err
+		}
+
+		if len(m.Manifests) == 0 {
+			var layers []string
+			for _, l := range m.Layers {
+				layers = append(layers, blobPath(l.Digest))
+			}
+			return blobPath(m.Config.Digest), layers, nil
+		}
+
+		data, err = readImageFile(read, blobPath(m.Manifests[0].Digest))
+		if err != nil {
+			return "", nil, err
+		}
+	}
+}
+
+// build a virtual file system from an OCI layout or docker save tarball
+func loadImage(pathname string) (*memFS, []string, error) {
+	var read imageReader
+
+	fi, err := os.Stat(pathname)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if fi.IsDir() {
+		read = directoryReader(pathname)
+	} else {
+		f, err := os.Open(pathname)
+		if err != nil {
+			return nil, nil, err
+		}
+		defer f.Close()
+
+		read, err = tarballReader(f)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	config, layers, err := readImageManifest(read)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	fs := newMemFS()
+	for _, layer := range layers {
+		if err := applyImageLayer(fs, read, layer); err != nil {
+			return nil, nil, fmt.Errorf("%s: %v", layer, err)
+		}
+	}
+
+	var env []string
+	if data, err := readImageFile(read, config); err == nil {
+		var cfg imageConfig
+		if json.Unmarshal(data, &cfg) == nil {
+			env = cfg.Config.Env
+		}
+	}
+	return fs, env, nil
+}
+
+func applyImageLayer(fs *memFS, read imageReader, layer string) error {
+	f, err := read(layer)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	r, err := decompressReader(f)
+	if err != nil {
+		return err
+	}
+	return fs.applyLayer(r)
+}
+
+// look up libraries inside the image instead of the host
+func useImage(pathname string) {
+	fs, env, err := loadImage(pathname)
+	if err != nil {
+		fmt.Printf("elftree: %v\n", err)
+		os.Exit(1)
+	}
+	sysfs = fs
+
+	envlib = ""
+	for _, e := range env {
+		if strings.HasPrefix(e, "LD_LIBRARY_PATH=") {
+			envlib = e[16:]
+		}
+	}
+	conflib = readLdSoConf("/etc/ld.so.conf", nil)
+}
@@ -0,0 +1,231 @@
+/*
+ * ELF tree - Tree viewer for ELF library dependency
+ *
+ * Copyright (C) 2017-2018  Namhyung Kim <namhyung@gmail.com>
+ *
+ * Released under MIT license.
+ */
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+type tarEntry struct {
+	name string
+	typ  byte
+	data string // or link target
+}
+
+func makeTar(entries []tarEntry) []byte {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+
+	for _, e := range entries {
+		hdr := &tar.Header{Name: e.name, Typeflag: e.typ, Mode: 0644}
+		switch e.typ {
+		case tar.TypeDir:
+			hdr.Mode = 0755
+		case tar.TypeSymlink, tar.TypeLink:
+			hdr.Linkname = e.data
+		case tar.TypeReg:
+			hdr.Size = int64(len(e.data))
+		}
+		tw.WriteHeader(hdr)
+		if e.typ == tar.TypeReg {
+			tw.Write([]byte(e.data))
+		}
+	}
+	tw.Close()
+	return buf.Bytes()
+}
+
+func gzipData(data []byte) []byte {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	zw.Write(data)
+	zw.Close()
+	return buf.Bytes()
+}
+
+// contents of non-ELF files are not kept (except in /etc)
+const ELF_HEAD = "\x7fELF"
+
+var baseLayer = []tarEntry{
+	{"usr/", tar.TypeDir, ""},
+	{"usr/lib/", tar.TypeDir, ""},
+	{"usr/lib/libfoo.so.1", tar.TypeReg, ELF_HEAD + "foo"},
+	{"usr/lib/libbar.so.1", tar.TypeReg, ELF_HEAD + "bar"},
+	{"usr/lib/libfoo.so", tar.TypeLink, "usr/lib/libfoo.so.1"},
+	{"lib", tar.TypeSymlink, "usr/lib"},
+	{"etc/conf/a", tar.TypeReg, "a"},
+	{"etc/conf/sub/b", tar.TypeReg, "b"},
+}
+
+var upperLayer = []tarEntry{
+	{"usr/lib/.wh.libbar.so.1", tar.TypeReg, ""},
+	{"etc/conf/c", tar.TypeReg, "c"},
+	{"etc/conf/.wh..wh..opq", tar.TypeReg, ""},
+	{"usr/lib/libfoo.so.1", tar.TypeReg, ELF_HEAD + "foo2"},
+	{"usr/share/doc/README", tar.TypeReg, "not an ELF file"},
+}
+
+// returns contents of the file or "" if not found
+func memFileData(fs *memFS, name string) string {
+	r, err := fs.Open(name)
+	if err != nil {
+		return ""
+	}
+	defer r.Close()
+
+	data, _ := ioutil.ReadAll(r)
+	return string(data)
+}
+
+func TestApplyLayer(t *testing.T) {
+	fs := newMemFS()
+	for _, layer := range [][]tarEntry{baseLayer, upperLayer} {
+		if err := fs.applyLayer(bytes.NewReader(makeTar(layer))); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := map[string]string{
+		"/lib/libfoo.so.1":     ELF_HEAD + "foo2", // through the symlink
+		"/usr/lib/libfoo.so":   ELF_HEAD + "foo",  // hard link to the lower file
+		"/usr/lib/libbar.so.1": "",                // whiteout
+		"/etc/conf/a":          "",                // opaque directory
+		"/etc/conf/sub/b":      "",
+		"/etc/conf/c":          "c",
+	}
+	for name, want := range tests {
+		if got := memFileData(fs, name); got != want {
+			t.Errorf("%s: got %q, want %q", name, got, want)
+		}
+	}
+
+	if p, err := fs.EvalSymlinks("/lib/libfoo.so.1"); err != nil || p != "/usr/lib/libfoo.so.1" {
+		t.Errorf("EvalSymlinks: got %s (%v)", p, err)
+	}
+	if m, _ := fs.Glob("/usr/lib/lib*.so*"); len(m) != 2 {
+		t.Errorf("Glob: got %v", m)
+	}
+
+	// skipped contents
+	if fi, err := fs.Stat("/usr/share/doc/README"); err != nil || fi.Size() != 15 {
+		t.Errorf("Stat: got %v (%v)", fi, err)
+	}
+	if _, err := fs.Open("/usr/share/doc/README"); err == nil {
+		t.Errorf("Open: got contents not kept")
+	}
+}
+
+// returns docker save tarball with two layers
+func makeDockerSave() []byte {
+	config := `{"config": {"Env": ["PATH=/bin", "LD_LIBRARY_PATH=/opt/lib"]}}`
+	manifest := `[{"Config": "config.json", "Layers": ["base/layer.tar", "upper/layer.tar"]}]`
+
+	return makeTar([]tarEntry{
+		{"manifest.json", tar.TypeReg, manifest},
+		{"config.json", tar.TypeReg, config},
+		{"base/layer.tar", tar.TypeReg, string(makeTar(baseLayer))},
+		{"blobs/sha256/1234", tar.TypeReg, string(gzipData(makeTar(upperLayer)))},
+		// same layer in other images is a symlink
+		{"upper/layer.tar", tar.TypeSymlink, "../blobs/sha256/1234"},
+	})
+}
+
+func TestLoadDockerSave(t *testing.T) {
+	pathname := filepath.Join(t.TempDir(), "image.tar")
+	if err := os.WriteFile(pathname, makeDockerSave(), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	fs, env, err := loadImage(pathname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(env) != 2 || env[1] != "LD_LIBRARY_PATH=/opt/lib" {
+		t.Errorf("env: got %v", env)
+	}
+	if got := memFileData(fs, "/lib/libfoo.so.1"); got != ELF_HEAD+"foo2" {
+		t.Errorf("got %q from the upper layer", got)
+	}
+}
+
+// write the blob and returns its digest
+func writeBlob(t *testing.T, dir string, data []byte) string {
+	sum := fmt.Sprintf("%x", sha256.Sum256(data))
+
+	blobs := filepath.Join(dir, "blobs", "sha256")
+	os.MkdirAll(blobs, 0755)
+	if err := os.WriteFile(filepath.Join(blobs, sum), data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return "sha256:" + sum
+}
+
+func TestLoadOciLayout(t *testing.T) {
+	dir := t.TempDir()
+
+	config := writeBlob(t, dir, []byte(`{"config": {"Env": ["LD_LIBRARY_PATH=/opt/lib"]}}`))
+	base := writeBlob(t, dir, gzipData(makeTar(baseLayer)))
+	upper := writeBlob(t, dir, makeTar(upperLayer))
+
+	manifest := writeBlob(t, dir, []byte(fmt.Sprintf(`{
+		"mediaType": "application/vnd.oci.image.manifest.v1+json",
+		"config": {"digest": "%s"},
+		"layers": [{"digest": "%s"}, {"digest": "%s"}]}`, config, base, upper)))
+
+	// nested index (e.g. multi-platform image)
+	nested := writeBlob(t, dir, []byte(fmt.Sprintf(`{
+		"mediaType": "application/vnd.oci.image.index.v1+json",
+		"manifests": [{"digest": "%s"}]}`, manifest)))
+	index := fmt.Sprintf(`{"manifests": [{"digest": "%s"}]}`, nested)
+	os.WriteFile(filepath.Join(dir, "index.json"), []byte(index), 0644)
+
+	fs, env, err := loadImage(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(env) != 1 || env[0] != "LD_LIBRARY_PATH=/opt/lib" {
+		t.Errorf("env: got %v", env)
+	}
+	if got := memFileData(fs, "/usr/lib/libfoo.so"); got != ELF_HEAD+"foo" {
+		t.Errorf("got %q from the base layer", got)
+	}
+	if got := memFileData(fs, "/usr/lib/libbar.so.1"); got != "" {
+		t.Errorf("got %q from removed file", got)
+	}
+}
+
+func TestOpaqueRoot(t *testing.T) {
+	fs := newMemFS()
+	fs.applyLayer(bytes.NewReader(makeTar(baseLayer)))
+
+	// opaque whiteout at the root hides all lower files
+	err := fs.applyLayer(bytes.NewReader(makeTar([]tarEntry{
+		{".wh..wh..opq", tar.TypeReg, ""},
+		{"usr/lib/libnew.so.1", tar.TypeReg, ELF_HEAD + "new"},
+	})))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"/", "/usr", "/usr/lib", "/usr/lib/libnew.so.1"}
+	if got := fs.names(); strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if _, err := fs.Stat("/"); err != nil {
+		t.Errorf("root is removed: %v", err)
+	}
+}
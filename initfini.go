@@ -0,0 +1,356 @@
+/*
+ * ELF tree - Tree viewer for ELF library dependency
+ *
+ * Copyright (C) 2017-2018  Namhyung Kim <namhyung@gmail.com>
+ *
+ * Released under MIT license.
+ */
+package main
+
+import (
+	"debug/elf"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+type InitFunc struct {
+	kind string // dynamic tag (or section) of the entry
+	idx  int    // index in the array, -1 for DT_INIT and DT_FINI
+	addr uint64
+	name string
+}
+
+// init/fini arrays with their size tags and section names
+var initArrays = []struct {
+	tag, size elf.DynTag
+	sect      string
+}{
+	{elf.DT_PREINIT_ARRAY, elf.DT_PREINIT_ARRAYSZ, ".preinit_array"},
+	{elf.DT_INIT, 0, ".init"},
+	{elf.DT_INIT_ARRAY, elf.DT_INIT_ARRAYSZ, ".init_array"},
+	{elf.DT_FINI_ARRAY, elf.DT_FINI_ARRAYSZ, ".fini_array"},
+	{elf.DT_FINI, 0, ".fini"},
+}
+
+func (f *InitFunc) label() string {
+	if f.idx < 0 {
+		return f.kind
+	}
+	return fmt.Sprintf("%s[%d]", f.kind, f.idx)
+}
+
+func (f *InitFunc) isInit() bool {
+	return !strings.HasPrefix(f.kind, "DT_FINI") && !strings.HasPrefix(f.kind, ".fini")
+}
+
+func (f *InitFunc) isPreinit() bool {
+	return strings.HasPrefix(f.kind, "DT_PREINIT") || strings.HasPrefix(f.kind, ".preinit")
+}
+
+// returns the function symbol at the offset of the section (for relocatable objects)
+func sectionFunc(info *DepsInfo, shndx elf.SectionIndex, off uint64) string {
+	for _, sym := range info.syms {
+		t := elf.ST_TYPE(sym.Info)
+		if sym.Section == shndx && sym.Value == off && (t == elf.STT_FUNC || t == elf.STT_LOOS) {
+			return symName(sym.Name)
+		}
+	}
+	if int(shndx) < len(info.sect) {
+		return fmt.Sprintf("%s%+#x", info.sect[shndx].Name, off)
+	}
+	return ""
+}
+
+// relocation applied to an entry of init/fini arrays
+type initReloc struct {
+	sym    *elf.Symbol // nil for relative relocations
+	addend int64
+	rela   bool
+}
+
+// returns relocations in the address range
+func initRelocs(info *DepsInfo, sidx int, start, end uint64) map[uint64]initReloc {
+	relocs := make(map[uint64]initReloc)
+
+	for i, s := range info.sect {
+		if !isRelocSection(s) {
+			continue
+		}
+		if info.kind == elf.ET_REL && int(s.Info) != sidx {
+			continue
+		}
+
+		entries, err := readRelocs(info, i)
+		if err != nil {
+			continue
+		}
+
+		syms := info.syms
+		if int(s.Link) < len(info.sect) && info.sect[s.Link].Type == elf.SHT_DYNSYM {
+			syms = info.dsym
+		}
+
+		for _, r := range entries {
+			if r.off < start || r.off >= end {
+				continue
+			}
+
+			ir := initReloc{addend: r.addend, rela: r.rela}
+			// the first (null) symbol is not included
+			if r.sym != 0 && int(r.sym) <= len(syms) {
+				ir.sym = &syms[r.sym-1]
+			}
+			relocs[r.off] = ir
+		}
+	}
+	return relocs
+}
+
+// returns the function name of the relocation target
+func (r *initReloc) name(info *DepsInfo, syms SymIndex, val uint64) string {
+	// REL and RELR keep the addend in place
+	addend := r.addend
+	if !r.rela {
+		addend = int64(val)
+	}
+
+	switch {
+	case r.sym == nil:
+		return syms.lookup(uint64(addend))
+	case elf.ST_TYPE(r.sym.Info) == elf.STT_SECTION:
+		return sectionFunc(info, r.sym.Section, uint64(addend))
+	case addend != 0:
+		return fmt.Sprintf("%s%+#x", symName(r.sym.Name), addend)
+	}
+	return symName(r.sym.Name)
+}
+
+// read function pointers in the init/fini array
+func readInitArray(name string, info *DepsInfo, kind string, sidx int, off, size uint64) []InitFunc {
+	s := info.sect[sidx]
+
+	data, err := readSectionData(info.path, info.sect, sidx)
+	if err != nil || size > uint64(len(data)) || off > uint64(len(data))-size {
+		return nil
+	}
+	data = data[off : off+size]
+
+	word := uint64(8)
+	if info.bits == elf.ELFCLASS32 {
+		word = 4
+	}
+
+	// relocatable objects have section offsets
+	base := s.Addr + off
+	if info.kind == elf.ET_REL {
+		base = off
+	}
+	relocs := initRelocs(info, sidx, base, base+size)
+	syms := makeSymIndex(name)
+
+	var funcs []InitFunc
+	for i := uint64(0); i+word <= size; i += word {
+		var val uint64
+		if word == 4 {
+			val = uint64(info.endian.Uint32(data[i:]))
+		} else {
+			val = info.endian.Uint64(data[i:])
+		}
+
+		var fn string
+		if r, ok := relocs[base+i]; ok {
+			fn = r.name(info, syms, val)
+		} else if info.kind != elf.ET_REL {
+			fn = syms.lookup(val)
+		}
+		funcs = append(funcs, InitFunc{kind: kind, idx: int(i / word), addr: val, name: fn})
+	}
+	return funcs
+}
+
+// returns initializers and finalizers of the object in the order of ld.so
+func readInitFuncs(name string) []InitFunc {
+	info := deps[name]
+	if info.endian == nil {
+		return nil
+	}
+
+	dyns := make(map[elf.DynTag]uint64)
+	for _, d := range info.dyns {
+		if v, ok := d.val.(uint64); ok {
+			dyns[d.tag] = v
+		}
+	}
+	syms := makeSymIndex(name)
+
+	var funcs []InitFunc
+	for _, a := range initArrays {
+		kind := dynTagName(info.mach, a.tag)
+
+		addr, ok := dyns[a.tag]
+		if ok && a.size == 0 {
+			// DT_INIT and DT_FINI are function addresses
+			funcs = append(funcs, InitFunc{kind: kind, idx: -1, addr: addr, name: syms.lookup(addr)})
+			continue
+		}
+		if ok {
+			s := addrSection(&info, addr)
+			if s == nil {
+				continue
+			}
+			sidx := findSection(info.sect, s.Name)
+			funcs = append(funcs, readInitArray(name, &info, kind, sidx, addr-s.Addr, dyns[a.size])...)
+			continue
+		}
+
+		// no dynamic section: static executables and relocatable objects
+		if len(info.dyns) > 0 {
+			continue
+		}
+		if a.size == 0 {
+			sidx := findSection(info.sect, a.sect)
+			if sidx < 0 || info.sect[sidx].Type == elf.SHT_NOBITS {
+				continue
+			}
+			s := info.sect[sidx]
+
+			fn := ""
+			if info.kind != elf.ET_REL {
+				fn = syms.lookup(s.Addr)
+			}
+			funcs = append(funcs, InitFunc{kind: a.sect, idx: -1, addr: s.Addr, name: fn})
+			continue
+		}
+		for _, sidx := range initSections(&info, a.sect) {
+			s := info.sect[sidx]
+			funcs = append(funcs, readInitArray(name, &info, s.Name, sidx, 0, s.Size)...)
+		}
+	}
+	return funcs
+}
+
+// returns init/fini array sections sorted by priority like the linker does.
+// relocatable objects have ".init_array.NNNNN" for constructor priorities.
+func initSections(info *DepsInfo, name string) []int {
+	var idx []int
+	for i, s := range info.sect {
+		if s.Type == elf.SHT_NOBITS {
+			continue
+		}
+		if s.Name == name || strings.HasPrefix(s.Name, name+".") {
+			idx = append(idx, i)
+		}
+	}
+
+	// sections without priority come last
+	prio := func(i int) string {
+		n := info.sect[i].Name
+		if n == name {
+			return "~"
+		}
+		return fmt.Sprintf("%10s", n[len(name)+1:])
+	}
+	sort.SliceStable(idx, func(i, j int) bool { return prio(idx[i]) < prio(idx[j]) })
+	return idx
+}
+
+func makeInitFuncString(f *InitFunc) string {
+	name := f.name
+	if name != "" {
+		name = "<" + name + ">"
+	}
+	return fmt.Sprintf("  %-20s %16x  %s", f.label(), f.addr, name)
+}
+
+func makeInitFuncStrings(name string) []string {
+	var lines []string
+	for _, f := range readInitFuncs(name) {
+		lines = append(lines, makeInitFuncString(&f))
+	}
+	if len(lines) == 0 {
+		lines = append(lines, "  (none)")
+	}
+	return lines
+}
+
+// constructors of all objects in execution order
+func constructorOrder(root string) ([]string, []InitFunc) {
+	var objs []string
+	var funcs []InitFunc
+
+	// pre-initializers only run for the executable, before all others
+	for _, f := range readInitFuncs(root) {
+		if f.isPreinit() {
+			objs = append(objs, root)
+			funcs = append(funcs, f)
+		}
+	}
+
+	for _, name := range initOrder(root) {
+		for _, f := range readInitFuncs(name) {
+			if !f.isInit() || f.isPreinit() {
+				continue
+			}
+			objs = append(objs, name)
+			funcs = append(funcs, f)
+		}
+	}
+	return objs, funcs
+}
+
+// destructors run in reverse: fini arrays backward, then DT_FINI
+func destructorOrder(root string) ([]string, []InitFunc) {
+	var objs []string
+	var funcs []InitFunc
+
+	order := initOrder(root)
+	for i := len(order) - 1; i >= 0; i-- {
+		var arrays, fini []InitFunc
+
+		all := readInitFuncs(order[i])
+		for j := len(all) - 1; j >= 0; j-- {
+			switch {
+			case all[j].isInit():
+				continue
+			case all[j].idx < 0:
+				fini = append(fini, all[j])
+			default:
+				arrays = append(arrays, all[j])
+			}
+		}
+
+		for _, f := range append(arrays, fini...) {
+			objs = append(objs, order[i])
+			funcs = append(funcs, f)
+		}
+	}
+	return objs, funcs
+}
+
+func makeOrderStrings(objs []string, funcs []InitFunc) []string {
+	var lines []string
+	for i := range funcs {
+		name := funcs[i].name
+		if name == "" {
+			name = fmt.Sprintf("%#x", funcs[i].addr)
+		}
+		lines = append(lines, fmt.Sprintf("  %3d  %-24s %-20s %s", i+1, objs[i], funcs[i].label(), name))
+	}
+	if len(lines) == 0 {
+		lines = append(lines, "  (none)")
+	}
+	return lines
+}
+
+// global constructor and destructor order for the order view
+func makeInitOrderInfo(root string) *FileInfo {
+	top := &TreeItem{node: "Initializer order"}
+
+	AddSubTree("", nil, top)
+	AddSubTree("Constructor Order", makeOrderStrings(constructorOrder(root)), top)
+	AddSubTree("", nil, top)
+	AddSubTree("Destructor Order", makeOrderStrings(destructorOrder(root)), top)
+
+	return &FileInfo{Root: top, Top: top, Curr: top}
+}
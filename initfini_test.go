@@ -0,0 +1,65 @@
+/*
+ * ELF tree - Tree viewer for ELF library dependency
+ *
+ * Copyright (C) 2017-2018  Namhyung Kim <namhyung@gmail.com>
+ *
+ * Released under MIT license.
+ */
+package main
+
+import (
+	"debug/elf"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestInitSections(t *testing.T) {
+	var info DepsInfo
+	for _, name := range []string{"", ".text", ".init_array", ".init_array.00200",
+		".fini_array", ".init_array.00101", ".init_array.65535", ".init_array.bss"} {
+		s := &elf.Section{SectionHeader: elf.SectionHeader{Name: name, Type: elf.SHT_INIT_ARRAY}}
+		if name == ".init_array.bss" {
+			s.Type = elf.SHT_NOBITS
+		}
+		info.sect = append(info.sect, s)
+	}
+
+	var names []string
+	for _, i := range initSections(&info, ".init_array") {
+		names = append(names, info.sect[i].Name)
+	}
+	want := ".init_array.00101 .init_array.00200 .init_array.65535 .init_array"
+	if got := strings.Join(names, " "); got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
+
+func TestReadInitFuncs(t *testing.T) {
+	deps = make(map[string]DepsInfo)
+	root := loadDeps(TLS_FIXTURE, "libtls.so.1")
+
+	var labels []string
+	for _, f := range readInitFuncs(root.name) {
+		labels = append(labels, fmt.Sprintf("%s@%#x", f.label(), f.addr))
+	}
+	// constructors sorted by priority (101, 200, default) in the fixture
+	want := "DT_INIT_ARRAY[0]@0x460 DT_INIT_ARRAY[1]@0x470 DT_INIT_ARRAY[2]@0x480 DT_FINI_ARRAY[0]@0x450"
+	if got := strings.Join(labels, " "); got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
+
+// DT_INIT_ARRAYSZ from a broken file should not wrap around
+func TestReadInitArrayBounds(t *testing.T) {
+	deps = make(map[string]DepsInfo)
+	root := loadDeps(TLS_FIXTURE, "libtls.so.1")
+	info := deps[root.name]
+
+	sidx := initSections(&info, ".init_array")[0]
+	for _, size := range []uint64{^uint64(0) - 7, ^uint64(0), info.sect[sidx].Size + 8} {
+		if funcs := readInitArray(root.name, &info, "DT_INIT_ARRAY", sidx, 8, size); funcs != nil {
+			t.Errorf("size %#x: got %d functions", size, len(funcs))
+		}
+	}
+}
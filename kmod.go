@@ -0,0 +1,417 @@
+/*
+ * ELF tree - Tree viewer for ELF library dependency
+ *
+ * Copyright (C) 2017-2018  Namhyung Kim <namhyung@gmail.com>
+ *
+ * Released under MIT license.
+ */
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/x509/pkix"
+	"debug/elf"
+	"encoding/asn1"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+)
+
+const (
+	MODULE_SIG_MAGIC = "~Module signature appended~\n"
+	MODULE_SIG_SIZE  = 12 // sizeof(struct module_signature)
+	PKEY_ID_PKCS7    = 2
+)
+
+var kmodName = regexp.MustCompile(`\.ko(\.gz|\.xz|\.zst)?$`)
+
+// module name to its path in the module directory
+var kmodPaths map[string]string
+
+// module name in modinfo uses underscore instead of dash
+func moduleName(pathname string) string {
+	name := kmodName.ReplaceAllString(path.Base(pathname), "")
+	return strings.Replace(name, "-", "_", -1)
+}
+
+func isKernelModule(pathname string) bool {
+	return kmodName.MatchString(pathname)
+}
+
+// returns default module directory of the running kernel
+func defaultModDir() string {
+	release, err := ioutil.ReadFile("/proc/sys/kernel/osrelease")
+	if err != nil {
+		return ""
+	}
+	return path.Join("/lib/modules", strings.TrimSpace(string(release)))
+}
+
+// read (and decompress) the kernel module
+func readModule(pathname string) ([]byte, error) {
+	f, err := sysfs.Open(pathname)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	data, err := ioutil.ReadAll(f)
+	if err != nil || !isCompressed(data) {
+		return data, err
+	}
+
+	r, err := decompress(data)
+	if err != nil {
+		return nil, err
+	}
+	return ioutil.ReadAll(r)
+}
+
+// returns "key=value" strings in the .modinfo section
+func readModinfo(f *elf.File) []string {
+	s := f.Section(".modinfo")
+	if s == nil {
+		return nil
+	}
+	data, err := s.Data()
+	if err != nil {
+		return nil
+	}
+	return parseModinfo(data)
+}
+
+// NUL-separated strings with padding between them
+func parseModinfo(data []byte) []string {
+	var info []string
+	for _, v := range bytes.Split(data, []byte{0}) {
+		if bytes.IndexByte(v, '=') > 0 {
+			info = append(info, string(v))
+		}
+	}
+	return info
+}
+
+func modinfoValues(modinfo []string, key string) []string {
+	var vals []string
+	for _, v := range modinfo {
+		if strings.HasPrefix(v, key+"=") {
+			vals = append(vals, v[len(key)+1:])
+		}
+	}
+	return vals
+}
+
+type pkcs7 struct {
+	ContentType asn1.ObjectIdentifier
+	Content     asn1.RawValue `asn1:"explicit,tag:0"`
+}
+
+type pkcs7SignedData struct {
+	Version          int
+	DigestAlgorithms asn1.RawValue
+	ContentInfo      asn1.RawValue
+	Certificates     asn1.RawValue     `asn1:"optional,tag:0"`
+	CRLs             asn1.RawValue     `asn1:"optional,tag:1"`
+	SignerInfos      []pkcs7SignerInfo `asn1:"set"`
+}
+
+type pkcs7SignerInfo struct {
+	Version         int
+	IssuerAndSerial pkcs7IssuerAndSerial
+	DigestAlgorithm pkix.AlgorithmIdentifier
+}
+
+type pkcs7IssuerAndSerial struct {
+	Issuer asn1.RawValue
+	Serial *big.Int
+}
+
+var hashAlgos = map[string]string{
+	"1.3.14.3.2.26":          "sha1",
+	"2.16.840.1.101.3.4.2.4": "sha224",
+	"2.16.840.1.101.3.4.2.1": "sha256",
+	"2.16.840.1.101.3.4.2.2": "sha384",
+	"2.16.840.1.101.3.4.2.3": "sha512",
+}
+
+// returns signer, key id and hash algorithm of the appended signature
+func readModuleSignature(data []byte) ([]string, error) {
+	if !bytes.HasSuffix(data, []byte(MODULE_SIG_MAGIC)) {
+		return nil, nil
+	}
+	data = data[:len(data)-len(MODULE_SIG_MAGIC)]
+	if len(data) < MODULE_SIG_SIZE {
+		return nil, errors.New("bad module signature")
+	}
+
+	hdr := data[len(data)-MODULE_SIG_SIZE:]
+	siglen := int(binary.BigEndian.Uint32(hdr[8:12]))
+	if hdr[2] != PKEY_ID_PKCS7 || siglen > len(data)-MODULE_SIG_SIZE {
+		return nil, errors.New("unsupported module signature")
+	}
+	sig := data[len(data)-MODULE_SIG_SIZE-siglen : len(data)-MODULE_SIG_SIZE]
+
+	var p pkcs7
+	if _, err := asn1.Unmarshal(sig, &p); err != nil {
+		return nil, err
+	}
+	var sd pkcs7SignedData
+	if _, err := asn1.Unmarshal(p.Content.Bytes, &sd); err != nil {
+		return nil, err
+	}
+	if len(sd.SignerInfos) == 0 {
+		return nil, errors.New("no signer in module signature")
+	}
+	si := sd.SignerInfos[0]
+
+	var rdn pkix.RDNSequence
+	if _, err := asn1.Unmarshal(si.IssuerAndSerial.Issuer.FullBytes, &rdn); err != nil {
+		return nil, err
+	}
+	var issuer pkix.Name
+	issuer.FillFromRDNSequence(&rdn)
+
+	algo := si.DigestAlgorithm.Algorithm.String()
+	if name, ok := hashAlgos[algo]; ok {
+		algo = name
+	}
+
+	return []string{
+		"signer=" + issuer.CommonName,
+		"sig_key=" + strings.ToUpper(hex.EncodeToString(si.IssuerAndSerial.Serial.Bytes())),
+		"sig_hashalgo=" + algo,
+	}, nil
+}
+
+// returns symbols exported by the module
+func exportedKsyms(syms []elf.Symbol) []string {
+	var exports []string
+	for _, s := range syms {
+		if strings.HasPrefix(s.Name, "__ksymtab_") && s.Section != elf.SHN_UNDEF {
+			exports = append(exports, s.Name[len("__ksymtab_"):])
+		}
+	}
+	return exports
+}
+
+// read module locations and exported symbols in the module directory
+func readModuleIndex(moddir string) {
+	kmodPaths = make(map[string]string)
+	symIndex = make(map[string]string)
+
+	if f, err := sysfs.Open(path.Join(moddir, "modules.dep")); err == nil {
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			line := scanner.Text()
+			i := strings.Index(line, ":")
+			if i < 0 {
+				continue
+			}
+
+			p := line[:i]
+			if !path.IsAbs(p) {
+				p = path.Join(moddir, p)
+			}
+			kmodPaths[moduleName(p)] = p
+		}
+		f.Close()
+	} else {
+		// no depmod result: find modules directly
+		sysfs.Walk(moddir, func(p string, fi os.FileInfo, err error) error {
+			if err == nil && fi.Mode().IsRegular() && isKernelModule(p) {
+				kmodPaths[moduleName(p)] = p
+			}
+			return nil
+		})
+	}
+
+	if f, err := sysfs.Open(path.Join(moddir, "modules.symbols")); err == nil {
+		// alias symbol:<name> <module>
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			fields := strings.Fields(scanner.Text())
+			if len(fields) != 3 || !strings.HasPrefix(fields[1], "symbol:") {
+				continue
+			}
+			symIndex[fields[1][7:]] = fields[2]
+		}
+		f.Close()
+		return
+	}
+
+	// resolve symbols directly with exports of every module
+	for name, p := range kmodPaths {
+		data, err := readModule(p)
+		if err != nil {
+			continue
+		}
+		f, err := elf.NewFile(bytes.NewReader(data))
+		if err != nil {
+			continue
+		}
+		if syms, err := f.Symbols(); err == nil {
+			for _, sym := range exportedKsyms(syms) {
+				symIndex[sym] = name
+			}
+		}
+		f.Close()
+	}
+}
+
+func processModule(dep *DepsNode, pathname string) {
+	// skip duplicate modules
+	if _, ok := deps[dep.name]; ok {
+		return
+	}
+
+	info := DepsInfo{path: pathname, link: "kernel module"}
+
+	data, err := readModule(pathname)
+	if err != nil && dep.parent == nil {
+		fmt.Printf("elftree: %v: %s\n", err, pathname)
+		os.Exit(1)
+	}
+	if err != nil {
+		// show it as a leaf
+		info.path = ""
+		deps[dep.name] = info
+		return
+	}
+
+	f, err := elf.NewFile(bytes.NewReader(data))
+	if err != nil {
+		fmt.Printf("elftree: %v: %s\n", err, pathname)
+		os.Exit(1)
+	}
+	defer f.Close()
+
+	info.path = realPath(pathname)
+
+	info.mach = f.Machine
+	info.bits = f.Class
+	info.kind = f.Type
+	info.abi = f.OSABI
+	info.ver = f.ABIVersion
+	info.endian = f.ByteOrder
+	info.sect = f.Sections
+	info.notes = readNotes(f)
+
+	syms, err := f.Symbols()
+	if err == nil {
+		info.syms = syms
+	}
+	info.undef = undefinedSymbols(syms)
+
+	info.modinfo = readModinfo(f)
+	if sig, err := readModuleSignature(data); err == nil {
+		info.modinfo = append(info.modinfo, sig...)
+	}
+
+	// modpost records dependencies, otherwise resolve symbols
+	if depends := modinfoValues(info.modinfo, "depends"); len(depends) > 0 {
+		for _, d := range strings.Split(depends[0], ",") {
+			if d != "" {
+				info.libs = append(info.libs, strings.Replace(d, "-", "_", -1))
+			}
+		}
+	} else {
+		seen := make(map[string]bool)
+		for _, sym := range info.undef {
+			mod, ok := symIndex[sym]
+			if !ok || mod == dep.name || seen[mod] {
+				continue
+			}
+			seen[mod] = true
+			info.libs = append(info.libs, mod)
+		}
+	}
+
+	var L []*DepsNode
+	for _, name := range info.libs {
+		N := new(DepsNode)
+		N.name = name
+		N.parent = dep
+		N.depth = dep.depth + 1
+
+		L = append(L, N)
+		dep.child = append(dep.child, N)
+	}
+
+	deps_list = append(L, deps_list...)
+	deps[dep.name] = info
+}
+
+func loadModuleDeps(pathname string) *DepsNode {
+	root := &DepsNode{name: moduleName(pathname)}
+	kmodPaths[root.name] = pathname
+
+	deps_list = append(deps_list, root)
+	for len(deps_list) > 0 {
+		dep := deps_list[0]
+		deps_list = deps_list[1:]
+
+		processModule(dep, kmodPaths[dep.name])
+	}
+	return root
+}
+
+func printModinfo(root *DepsNode) {
+	info := deps[root.name]
+
+	fmt.Println()
+	fmt.Printf("%s: %s\n", root.name, info.path)
+	for _, v := range info.modinfo {
+		i := strings.Index(v, "=")
+		fmt.Printf("  %-24s  %s\n", v[:i]+":", v[i+1:])
+	}
+}
+
+func kmodMain(pathname string) {
+	if modDir == "" {
+		modDir = defaultModDir()
+	}
+	readModuleIndex(modDir)
+
+	root := loadModuleDeps(pathname)
+
+	depsMarks = make(map[string]rune)
+	depsNotes = make(map[string]string)
+	for name, info := range deps {
+		if info.path == "" {
+			depsMarks[name] = '?'
+			depsNotes[name] = "not found in " + modDir
+		}
+	}
+
+	if whyLib != "" {
+		printWhy(root, whyLib)
+		return
+	}
+
+	if showOrder {
+		// dependencies are loaded first like modprobe
+		fmt.Println("Load order:")
+		for i, name := range initOrder(root.name) {
+			fmt.Printf("  %3d  %s\n", i+1, name)
+		}
+		return
+	}
+
+	if showTui {
+		ShowWithTUI(root)
+		return
+	}
+
+	printDepTree(root, nil)
+	if verbose {
+		printModinfo(root)
+	}
+}
@@ -0,0 +1,158 @@
+/*
+ * ELF tree - Tree viewer for ELF library dependency
+ *
+ * Copyright (C) 2017-2018  Namhyung Kim <namhyung@gmail.com>
+ *
+ * Released under MIT license.
+ */
+package main
+
+import (
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/binary"
+	"math/big"
+	"reflect"
+	"testing"
+)
+
+func TestModuleName(t *testing.T) {
+	tests := map[string]string{
+		"kernel/fs/ext4/ext4.ko":               "ext4",
+		"kernel/drivers/usb/usb-storage.ko.xz": "usb_storage",
+		"/lib/modules/extra/snd-hda.ko.zst":    "snd_hda",
+		"nvidia_drm.ko.gz":                     "nvidia_drm",
+	}
+	for p, want := range tests {
+		if got := moduleName(p); got != want || !isKernelModule(p) {
+			t.Errorf("%s: got %s, want %s", p, got, want)
+		}
+	}
+	if isKernelModule("libfoo.so") {
+		t.Error("libfoo.so is not a kernel module")
+	}
+}
+
+func TestParseModinfo(t *testing.T) {
+	data := []byte("license=GPL\x00\x00\x00description=Foo driver\x00" +
+		"depends=bar,baz-core\x00alias=pci:v00008086d*\x00alias=of:N*\x00vermagic=6.1.0 SMP\x00\x00")
+
+	info := parseModinfo(data)
+	if len(info) != 6 {
+		t.Fatalf("got %q", info)
+	}
+	if got := modinfoValues(info, "alias"); !reflect.DeepEqual(got, []string{"pci:v00008086d*", "of:N*"}) {
+		t.Errorf("alias: got %q", got)
+	}
+	if got := modinfoValues(info, "depends"); !reflect.DeepEqual(got, []string{"bar,baz-core"}) {
+		t.Errorf("depends: got %q", got)
+	}
+	if got := modinfoValues(info, "firmware"); got != nil {
+		t.Errorf("firmware: got %q", got)
+	}
+}
+
+func TestReadModuleIndex(t *testing.T) {
+	fs := newMemFS()
+	add := func(name, data string) {
+		fs.add(&memEntry{name: name, mode: 0644, data: []byte(data)})
+	}
+	add("/lib/modules/6.1.0/modules.dep", "kernel/drivers/foo.ko.xz: kernel/lib/bar.ko\n"+
+		"kernel/lib/bar.ko:\n/opt/extra/my-mod.ko.zst:\n")
+	add("/lib/modules/6.1.0/modules.symbols", "# Aliases for symbols, used by symbol_request().\n"+
+		"alias symbol:bar_init bar\nalias symbol:foo_register foo\n")
+
+	saved := sysfs
+	sysfs = fs
+	defer func() { sysfs = saved }()
+
+	readModuleIndex("/lib/modules/6.1.0")
+
+	wantPaths := map[string]string{
+		"foo":    "/lib/modules/6.1.0/kernel/drivers/foo.ko.xz",
+		"bar":    "/lib/modules/6.1.0/kernel/lib/bar.ko",
+		"my_mod": "/opt/extra/my-mod.ko.zst",
+	}
+	if !reflect.DeepEqual(kmodPaths, wantPaths) {
+		t.Errorf("paths: got %v", kmodPaths)
+	}
+	wantSyms := map[string]string{"bar_init": "bar", "foo_register": "foo"}
+	if !reflect.DeepEqual(symIndex, wantSyms) {
+		t.Errorf("symbols: got %v", symIndex)
+	}
+
+	// without depmod result
+	fs.remove("/lib/modules/6.1.0/modules.dep")
+	fs.remove("/lib/modules/6.1.0/modules.symbols")
+	add("/lib/modules/6.1.0/kernel/lib/bar.ko", "")
+
+	readModuleIndex("/lib/modules/6.1.0")
+	if !reflect.DeepEqual(kmodPaths, map[string]string{"bar": "/lib/modules/6.1.0/kernel/lib/bar.ko"}) {
+		t.Errorf("paths: got %v", kmodPaths)
+	}
+}
+
+// module data with a PKCS#7 signature appended
+func makeSignedModule(t *testing.T) []byte {
+	var rdn pkix.RDNSequence = pkix.Name{CommonName: "Build time autogenerated kernel key"}.ToRDNSequence()
+	issuer, err := asn1.Marshal(rdn)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	empty := asn1.RawValue{FullBytes: []byte{0x31, 0x00}} // empty set
+	sd := pkcs7SignedData{
+		Version:          1,
+		DigestAlgorithms: empty,
+		ContentInfo:      asn1.RawValue{FullBytes: []byte{0x30, 0x00}},
+		SignerInfos: []pkcs7SignerInfo{{
+			Version: 1,
+			IssuerAndSerial: pkcs7IssuerAndSerial{
+				Issuer: asn1.RawValue{FullBytes: issuer},
+				Serial: big.NewInt(0x1a2b3c),
+			},
+			DigestAlgorithm: pkix.AlgorithmIdentifier{Algorithm: asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 3}},
+		}},
+	}
+	content, err := asn1.Marshal(sd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sig, err := asn1.Marshal(pkcs7{
+		ContentType: asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 2},
+		Content:     asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: content},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// struct module_signature
+	hdr := make([]byte, MODULE_SIG_SIZE)
+	hdr[2] = PKEY_ID_PKCS7
+	binary.BigEndian.PutUint32(hdr[8:], uint32(len(sig)))
+
+	data := []byte("\x7fELF module body")
+	data = append(data, sig...)
+	data = append(data, hdr...)
+	return append(data, MODULE_SIG_MAGIC...)
+}
+
+func TestReadModuleSignature(t *testing.T) {
+	sig, err := readModuleSignature(makeSignedModule(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{
+		"signer=Build time autogenerated kernel key",
+		"sig_key=1A2B3C",
+		"sig_hashalgo=sha512",
+	}
+	if !reflect.DeepEqual(sig, want) {
+		t.Errorf("got %q, want %q", sig, want)
+	}
+
+	if sig, err := readModuleSignature([]byte("\x7fELF unsigned")); sig != nil || err != nil {
+		t.Errorf("got %q (%v) for unsigned module", sig, err)
+	}
+}
@@ -9,6 +9,7 @@ package main
 
 import (
 	"bufio"
+	"debug/buildinfo"
 	"debug/elf"
 	"encoding/binary"
 	"flag"
@@ -24,6 +25,7 @@ type DepsNode struct {
 	parent *DepsNode
 	child  []*DepsNode
 	depth  int
+	seen   bool // expanded in other place (of the reverse tree)
 }
 
 type DynInfo struct {
@@ -47,12 +49,23 @@ type DepsInfo struct {
 	prog []*elf.Prog
 	sect []*elf.Section
 	dyns []DynInfo
+
+	notes  []ElfNote
+	link   string // dynamic, static or static-pie
+	interp string
+	libc   string   // embedded libc of static binaries
+	undef  []string // undefined symbols of relocatable objects
+
+	modinfo []string // key=value in kernel module
+	goinfo  *buildinfo.BuildInfo
 }
 
 var (
 	deps      map[string]DepsInfo
 	deps_list []*DepsNode
 	deps_root *DepsNode
+	deps_path string
+	deps_keys map[string]string // library name to key in deps for the current root
 	deflib    []string
 	envlib    string
 	conflib   []string
@@ -64,10 +77,25 @@ var (
 	showPath  bool
 	showTui   bool
 	showStdio bool
+	showJson  bool
+	whyLib    string
+	showOrder bool
+	showTls   bool
+	showHash  bool
+	lookupSym string
+	showCycle bool
+	procPid   int
+	imagePath string
+	rootDir   string
+	pullSym   string
+	modDir    string
 )
 
+// do not exit on missing libraries
+var allowMissing bool
+
 func readLdSoConf(name string, libpath []string) []string {
-	f, err := os.Open(name)
+	f, err := sysfs.Open(name)
 	if err != nil {
 		return libpath
 	}
@@ -85,7 +113,7 @@ func readLdSoConf(name string, libpath []string) []string {
 		}
 
 		if strings.HasPrefix(t, "include") {
-			libs, err := filepath.Glob(t[8:])
+			libs, err := sysfs.Glob(strings.TrimSpace(t[8:]))
 			if err != nil {
 				continue
 			}
@@ -101,6 +129,7 @@ func readLdSoConf(name string, libpath []string) []string {
 
 func init() {
 	deps = make(map[string]DepsInfo)
+	deps_keys = make(map[string]string)
 	deflib = []string{"/lib/", "/usr/lib/", "/lib64", "/usr/lib64"}
 	envlib = os.Getenv("LD_LIBRARY_PATH")
 	conflib = readLdSoConf("/etc/ld.so.conf", conflib)
@@ -109,6 +138,19 @@ func init() {
 	flag.BoolVar(&showPath, "p", false, "Show library path")
 	flag.BoolVar(&showTui, "tui", true, "Show it with TUI")
 	flag.BoolVar(&showStdio, "stdio", false, "Show it on standard IO")
+	flag.BoolVar(&showJson, "json", false, "Show diff result in JSON")
+	flag.StringVar(&whyLib, "why", "", "Show dependency chains to the `library`")
+	flag.BoolVar(&showOrder, "order", false, "Show load order and initializer order")
+	flag.BoolVar(&showCycle, "cycles", false, "Show dependency cycles")
+	flag.BoolVar(&showTls, "tls", false, "Show TLS usage and static TLS size")
+	flag.BoolVar(&showHash, "hash", false, "Show symbol hash table statistics")
+	flag.StringVar(&lookupSym, "lookup", "", "Look up the `symbol` through hash tables like ld.so")
+	flag.BoolVar(&demangleNames, "demangle", false, "Demangle C++ and Rust symbol names")
+	flag.IntVar(&procPid, "pid", 0, "Compare with libraries loaded in the `process`")
+	flag.StringVar(&rootDir, "root", "", "Look up libraries under the `directory` instead of /")
+	flag.StringVar(&imagePath, "image", "", "Look up files in the container `image` (OCI layout or docker save tarball)")
+	flag.StringVar(&pullSym, "pull", "", "Show archive members pulled in by the `symbol`")
+	flag.StringVar(&modDir, "moddir", "", "Look up kernel modules in the `directory` (default: /lib/modules/$(uname -r))")
 }
 
 // search shared libraries as described in `man ld.so(8)`
@@ -126,7 +168,7 @@ func findLib(name string, parent *DepsNode) string {
 			}
 
 			fullpath := path.Join(dyn.val.(string), name)
-			if _, err := os.Stat(fullpath); err == nil {
+			if _, err := sysfs.Stat(fullpath); err == nil {
 				return fullpath
 			}
 		}
@@ -135,7 +177,7 @@ func findLib(name string, parent *DepsNode) string {
 	// check LD_LIBRARY_PATH environ
 	for _, libpath := range strings.Split(envlib, ":") {
 		fullpath := path.Join(libpath, name)
-		if _, err := os.Stat(fullpath); err == nil {
+		if _, err := sysfs.Stat(fullpath); err == nil {
 			return fullpath
 		}
 	}
@@ -149,7 +191,7 @@ func findLib(name string, parent *DepsNode) string {
 			}
 
 			fullpath := path.Join(dyn.val.(string), name)
-			if _, err := os.Stat(fullpath); err == nil {
+			if _, err := sysfs.Stat(fullpath); err == nil {
 				return fullpath
 			}
 		}
@@ -158,7 +200,7 @@ func findLib(name string, parent *DepsNode) string {
 	// check libraries in /etc/ld.so.conf
 	for _, libpath := range conflib {
 		fullpath := path.Join(libpath, name)
-		if _, err := os.Stat(fullpath); err == nil {
+		if _, err := sysfs.Stat(fullpath); err == nil {
 			return fullpath
 		}
 	}
@@ -166,7 +208,7 @@ func findLib(name string, parent *DepsNode) string {
 	// check default library directories
 	for _, libpath := range deflib {
 		fullpath := path.Join(libpath, name)
-		if _, err := os.Stat(fullpath); err == nil {
+		if _, err := sysfs.Stat(fullpath); err == nil {
 			return fullpath
 		}
 	}
@@ -178,20 +220,29 @@ func realPath(pathname string) string {
 		return ""
 	}
 
-	relpath, _ := filepath.EvalSymlinks(pathname)
+	// magic links in /proc (like /proc/<pid>/root) cannot be followed
+	if strings.HasPrefix(pathname, "/proc/") {
+		return pathname
+	}
+
+	relpath, _ := sysfs.EvalSymlinks(pathname)
 	abspath, _ := filepath.Abs(relpath)
 
 	return abspath
 }
 
 func readElfString(strtab []byte, i uint64) string {
-	var len uint64
+	var n uint64
 
-	for len = 0; strtab[i+len] != '\x00'; len++ {
+	if i >= uint64(len(strtab)) {
+		return ""
+	}
+
+	for n = 0; i+n < uint64(len(strtab)) && strtab[i+n] != '\x00'; n++ {
 		continue
 	}
 
-	return string(strtab[i : i+len])
+	return string(strtab[i : i+n])
 }
 
 func readDynamic(f *elf.File, info *DepsInfo) int {
@@ -206,10 +257,13 @@ func readDynamic(f *elf.File, info *DepsInfo) int {
 	if err != nil {
 		return -1
 	}
-	str := f.Section(".dynstr")
-	stab, err := str.Data()
-	if err != nil {
-		return -1
+	// static-pie might not have dynamic string table
+	var stab []byte
+	if str := f.Section(".dynstr"); str != nil {
+		stab, err = str.Data()
+		if err != nil {
+			return -1
+		}
 	}
 
 	count = uint(dyn.Size / dyn.Entsize)
@@ -225,40 +279,70 @@ func readDynamic(f *elf.File, info *DepsInfo) int {
 		}
 
 		dtag := elf.DynTag(tag)
-		switch dtag {
-		case elf.DT_NULL:
+		if dtag == elf.DT_NULL {
+			// end of the dynamic array, the rest is padding
 			break
-		case elf.DT_NEEDED:
-			fallthrough
-		case elf.DT_RPATH:
-			fallthrough
-		case elf.DT_RUNPATH:
-			fallthrough
-		case elf.DT_SONAME:
+		}
+
+		// tags with string values are marked in the table
+		if dynTagDesc(f.Machine, dtag).kind == DYN_STR {
 			sval := readElfString(stab, val)
 			info.dyns = append(info.dyns, DynInfo{dtag, sval})
-			break
-		default:
+		} else {
 			info.dyns = append(info.dyns, DynInfo{dtag, val})
-			break
 		}
 	}
 	return 0
 }
 
+// use the key in deps for the node (and in the libs of the parent)
+func renameDep(dep *DepsNode, key string) {
+	if dep.name == key {
+		return
+	}
+	if dep.parent != nil {
+		libs := deps[dep.parent.name].libs
+		for i := range libs {
+			if libs[i] == dep.name {
+				libs[i] = key
+			}
+		}
+	}
+	dep.name = key
+}
+
 func processDep(dep *DepsNode) {
-	// skip duplicate libraries
-	if _, ok := deps[dep.name]; ok {
+	// skip duplicate libraries in the root like ld.so does
+	if key, ok := deps_keys[dep.name]; ok {
+		renameDep(dep, key)
 		return
 	}
 
 	info := DepsInfo{path: realPath(findLib(dep.name, dep.parent))}
 
 	if dep.parent == nil {
-		info.path = realPath(flag.Args()[0])
+		info.path = realPath(deps_path)
 	}
 
-	f, err := elf.Open(info.path)
+	// other roots might use a library with the same name in a different path
+	key := dep.name
+	if old, ok := deps[key]; ok && old.path != info.path {
+		key = fmt.Sprintf("%s (%s)", dep.name, info.path)
+	}
+	deps_keys[dep.name] = key
+	renameDep(dep, key)
+
+	if _, ok := deps[dep.name]; ok {
+		return
+	}
+
+	if info.path == "" && allowMissing {
+		// show it as a leaf
+		deps[dep.name] = info
+		return
+	}
+
+	f, err := sysfs.OpenElf(info.path)
 	if err != nil {
 		fmt.Printf("%v: %s (%s)\n", err, info.path, dep.name)
 		os.Exit(1)
@@ -275,14 +359,44 @@ func processDep(dep *DepsNode) {
 	info.prog = f.Progs
 	info.sect = f.Sections
 
-	if f.Type != elf.ET_EXEC && f.Type != elf.ET_DYN {
+	if f.Type != elf.ET_EXEC && f.Type != elf.ET_DYN && f.Type != elf.ET_REL {
 		fmt.Printf("elftree: `%s` seems not to be a valid ELF executable\n", dep.name)
 		os.Exit(1)
 	}
 
+	info.notes = readNotes(f)
+	info.interp = readInterp(f)
+
+	syms, err := f.Symbols()
+	if err == nil {
+		info.syms = syms
+	}
+
+	if isGoBinary(f) {
+		info.goinfo = readGoInfo(info.path)
+	}
+
+	if f.Type == elf.ET_REL {
+		// dependencies are resolved at link time
+		info.link = "relocatable"
+		info.undef = undefinedSymbols(syms)
+
+		deps[dep.name] = info
+		return
+	}
+
 	if readDynamic(f, &info) < 0 {
-		fmt.Printf("elftree: `%s` seems to be statically linked\n", dep.name)
-		os.Exit(1)
+		// statically linked binary has no dependency
+		info.link = "static"
+		info.libc = detectLibc(f, syms)
+
+		deps[dep.name] = info
+		return
+	}
+
+	info.link = linkType(f, &info)
+	if info.link == "static-pie" {
+		info.libc = detectLibc(f, syms)
 	}
 
 	libs, err := f.ImportedLibraries()
@@ -291,23 +405,19 @@ func processDep(dep *DepsNode) {
 		os.Exit(1)
 	}
 
+	// static-pie might not have dynamic symbols
 	isym, err := f.ImportedSymbols()
-	if err != nil {
+	if err != nil && err != elf.ErrNoSymbols {
 		fmt.Println(err)
 		os.Exit(1)
 	}
 
 	dsym, err := f.DynamicSymbols()
-	if err != nil {
+	if err != nil && err != elf.ErrNoSymbols {
 		fmt.Println(err)
 		os.Exit(1)
 	}
 
-	syms, err := f.Symbols()
-	if err == nil {
-		info.syms = syms
-	}
-
 	info.libs = libs
 	info.dsym = dsym
 	info.isym = isym
@@ -332,59 +442,56 @@ func printDepTree(n *DepsNode, f *elf.File) {
 		fmt.Printf("   ")
 	}
 
+	name := n.name
+	if isBackEdge(n) {
+		name += " " + CYCLE_MARK
+	}
+
 	if showPath {
-		fmt.Printf("%s  => %s\n", n.name, deps[n.name].path)
+		fmt.Printf("%s  => %s\n", name, deps[n.name].path)
 	} else {
-		fmt.Println(n.name)
+		fmt.Println(name)
 	}
 
 	for _, v := range n.child {
 		printDepTree(v, f)
 	}
 
-	if verbose && n.parent == nil {
-		showDetails(f, deps[n.name].path)
-	}
-}
+	if verbose && n.parent == nil && f != nil {
+		showDetails(f, n)
 
-func showDetails(f *elf.File, pathname string) {
-	s := f.Section(".interp")
-	if s == nil {
-		fmt.Printf("static linked executable: %s\n", pathname)
-		os.Exit(1)
-	}
-	interp, err := s.Data()
-	if err != nil {
-		fmt.Printf("%v: %s\n", err, pathname)
-		os.Exit(1)
+		if deps[n.name].goinfo != nil {
+			printGoInfo(n.name)
+		}
 	}
+}
 
-	di_deps, err := f.ImportedLibraries()
-	if err != nil {
-		fmt.Printf("imported libraries: %v\n", err)
-		os.Exit(1)
-	}
+func showDetails(f *elf.File, n *DepsNode) {
+	info := deps[n.name]
+	pathname := info.path
 
 	fmt.Println()
 	fmt.Printf("%s: %s\n", path.Base(pathname), realPath(pathname))
 	fmt.Printf("  type:                     %s  (%s / %s / %s)\n",
 		f.Type, f.Machine, f.Class, f.ByteOrder)
-	fmt.Printf("  interpreter:              %s\n", string(interp))
-	fmt.Printf("  total dependency:         %d\n", len(deps)-1) // exclude itself
-	fmt.Printf("  direct dependency:        %d\n", len(di_deps))
-}
-
-func main() {
-	flag.Parse()
+	fmt.Printf("  linkage:                  %s\n", info.link)
 
-	args := flag.Args()
-	if len(args) < 1 {
-		fmt.Println("Usage: elftree [<options>] <executable>")
-		os.Exit(1)
+	if info.link == "relocatable" {
+		fmt.Printf("  undefined symbols:        %d\n", len(info.undef))
+		return
+	}
+	if info.link != "dynamic" {
+		fmt.Printf("  libc:                     %s\n", info.libc)
+		return
 	}
 
-	pathname := args[0]
-	f, err := elf.Open(pathname)
+	fmt.Printf("  interpreter:              %s\n", info.interp)
+	fmt.Printf("  total dependency:         %d\n", len(loadOrder(n.name))-1) // exclude itself
+	fmt.Printf("  direct dependency:        %d\n", len(info.libs))
+}
+
+func openElf(pathname string) *elf.File {
+	f, err := sysfs.OpenElf(pathname)
 	if err != nil {
 		if strings.HasPrefix(err.Error(), "bad magic number") {
 			fmt.Printf("elftree: `%s` is not an ELF file\n", pathname)
@@ -393,12 +500,24 @@ func main() {
 		}
 		os.Exit(1)
 	}
-	defer f.Close()
+	return f
+}
+
+// build dependency tree of the given binary into the global deps map
+func loadDeps(pathname, name string) *DepsNode {
+	root := new(DepsNode)
+	root.name = name
+
+	// libraries are resolved for each root
+	deps_keys = make(map[string]string)
+	deps_path = pathname
+	deps_list = append(deps_list, root)
+	processDepsList()
 
-	deps_root = new(DepsNode)
-	deps_root.name = path.Base(pathname)
+	return root
+}
 
-	deps_list = append(deps_list, deps_root)
+func processDepsList() {
 	for len(deps_list) > 0 {
 		// pop first element
 		dep := deps_list[0]
@@ -406,14 +525,175 @@ func main() {
 
 		processDep(dep)
 	}
+}
+
+func main() {
+	flag.Parse()
+
+	args := flag.Args()
+	isDiff := len(args) > 0 && args[0] == "diff"
+	if isDiff {
+		// allow options after the subcommand
+		flag.CommandLine.Parse(args[1:])
+		args = flag.Args()
+	}
+
+	if imagePath != "" {
+		useImage(imagePath)
+	} else if rootDir != "" {
+		sysfs = rootFS{dir: rootDir}
+		conflib = readLdSoConf("/etc/ld.so.conf", nil)
+	}
+
+	if isDiff {
+		if len(args) != 2 {
+			fmt.Println("Usage: elftree diff [<options>] <old> <new>")
+			os.Exit(1)
+		}
+		if showStdio || showJson {
+			showTui = false
+		}
+		diffMain(args[0], args[1])
+		return
+	}
+
+	if procPid > 0 {
+		if showStdio {
+			showTui = false
+		}
+		procMain(procPid)
+		return
+	}
+
+	if len(args) < 1 {
+		fmt.Println("Usage: elftree [<options>] <executable>|<directory>...")
+		fmt.Println("       elftree diff [<options>] <old> <new>")
+		os.Exit(1)
+	}
+
+	var files []string
+	seen := make(map[string]bool)
+	for _, arg := range args {
+		for _, file := range scanElfFiles(arg) {
+			if seen[realPath(file)] {
+				continue
+			}
+			seen[realPath(file)] = true
+			files = append(files, file)
+		}
+	}
+	if len(files) == 0 {
+		fmt.Println("elftree: no ELF binary found")
+		os.Exit(1)
+	}
+
+	if len(files) == 1 && isPackage(files[0]) {
+		if showStdio {
+			showTui = false
+		}
+		pkgMain(files[0])
+		return
+	}
+
+	if len(files) == 1 && isArchiveFile(files[0]) {
+		if showStdio {
+			showTui = false
+		}
+		archiveMain(files[0])
+		return
+	}
+
+	if len(files) == 1 && isKernelModule(files[0]) {
+		if showStdio {
+			showTui = false
+		}
+		kmodMain(files[0])
+		return
+	}
+
+	if len(files) == 1 && isCoreFile(files[0]) {
+		if showStdio {
+			showTui = false
+		}
+		coreMain(files[0])
+		return
+	}
+
+	// libraries are shared across roots through the deps map
+	var roots []*DepsNode
+	for _, pathname := range files {
+		f := openElf(pathname)
+		f.Close()
+
+		name := path.Base(pathname)
+		if len(files) > 1 {
+			name = pathname
+		}
+		roots = append(roots, loadDeps(pathname, name))
+	}
+
+	markCgoLibs(roots)
+	tlsStartup = startupObjects(roots)
+
+	if whyLib != "" {
+		for _, root := range roots {
+			printWhy(root, whyLib)
+		}
+		return
+	}
+
+	if showOrder {
+		for _, root := range roots {
+			printLoadOrder(root)
+		}
+		return
+	}
+
+	if showCycle {
+		printCycles()
+		return
+	}
+
+	if showTls {
+		for _, root := range roots {
+			printTlsUsage(root)
+		}
+		return
+	}
+
+	if showHash || lookupSym != "" {
+		for _, root := range roots {
+			if showHash {
+				printHashStats(root)
+			}
+			if lookupSym != "" {
+				printLookup(root, lookupSym)
+			}
+		}
+		return
+	}
 
 	if showStdio {
 		showTui = false
 	}
 
 	if showTui {
+		if len(roots) > 1 {
+			deps_root = makeMultiRoot(roots)
+		} else {
+			deps_root = roots[0]
+		}
 		ShowWithTUI(deps_root)
-	} else {
-		printDepTree(deps_root, f)
+		return
+	}
+
+	for _, root := range roots {
+		f := openElf(deps[root.name].path)
+		printDepTree(root, f)
+		f.Close()
+	}
+
+	if len(roots) > 1 {
+		printSummary(roots)
 	}
 }
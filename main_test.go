@@ -0,0 +1,38 @@
+/*
+ * ELF tree - Tree viewer for ELF library dependency
+ *
+ * Copyright (C) 2017-2018  Namhyung Kim <namhyung@gmail.com>
+ *
+ * Released under MIT license.
+ */
+package main
+
+import (
+	"testing"
+)
+
+func TestReadElfString(t *testing.T) {
+	buf := []byte("\x00libc.so.6\x00libm.so.6\x00trunc")
+
+	tests := []struct {
+		strtab []byte
+		idx    uint64
+		want   string
+	}{
+		{buf, 0, ""},
+		{buf, 1, "libc.so.6"},
+		{buf, 11, "libm.so.6"},
+		{buf, 16, "so.6"},
+		{buf, 21, "trunc"}, // not terminated
+		{buf, 100, ""},
+		// capacity beyond the length is not used
+		{buf[:15], 11, "libm"},
+		{buf[:15], 15, ""},
+	}
+
+	for _, tt := range tests {
+		if got := readElfString(tt.strtab, tt.idx); got != tt.want {
+			t.Errorf("readElfString(%q, %d) = %q, want %q", tt.strtab, tt.idx, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,170 @@
+/*
+ * ELF tree - Tree viewer for ELF library dependency
+ *
+ * Copyright (C) 2017-2018  Namhyung Kim <namhyung@gmail.com>
+ *
+ * Released under MIT license.
+ */
+package main
+
+import (
+	"debug/elf"
+	"fmt"
+	"os"
+	"sort"
+)
+
+type LibUsage struct {
+	name  string
+	path  string
+	users []string
+}
+
+// check whether the file is a dynamically linked ELF executable or library
+func isDynamicElf(pathname string) bool {
+	f, err := sysfs.OpenElf(pathname)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+
+	if f.Type != elf.ET_EXEC && f.Type != elf.ET_DYN {
+		return false
+	}
+	return f.Section(".dynamic") != nil
+}
+
+// returns the file itself or ELF files under the directory
+func scanElfFiles(pathname string) []string {
+	fi, err := sysfs.Stat(pathname)
+	if err != nil || !fi.IsDir() {
+		return []string{pathname}
+	}
+
+	var files []string
+	sysfs.Walk(pathname, func(p string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return nil
+		}
+		// skip symlinks to libraries to avoid duplicates
+		if !fi.Mode().IsRegular() {
+			return nil
+		}
+		if isElfBinary(p) {
+			files = append(files, p)
+		}
+		return nil
+	})
+	return files
+}
+
+// check whether the root object is a shared library (not a PIE)
+func isLibrary(info *DepsInfo) bool {
+	if info.kind != elf.ET_DYN || info.link == "static-pie" {
+		return false
+	}
+	for _, p := range info.prog {
+		if p.Type == elf.PT_INTERP {
+			return false
+		}
+	}
+	return true
+}
+
+// returns libraries in the closure of roots with their users
+func libUsage(roots []*DepsNode) []LibUsage {
+	usage := make(map[string]*LibUsage)
+
+	for _, root := range roots {
+		for _, name := range loadOrder(root.name)[1:] {
+			lu, ok := usage[name]
+			if !ok {
+				lu = &LibUsage{name: name, path: deps[name].path}
+				usage[name] = lu
+			}
+			lu.users = append(lu.users, root.name)
+		}
+	}
+
+	var libs []LibUsage
+	for _, lu := range usage {
+		libs = append(libs, *lu)
+	}
+	sort.Slice(libs, func(i, j int) bool {
+		if len(libs[i].users) != len(libs[j].users) {
+			return len(libs[i].users) > len(libs[j].users)
+		}
+		return libs[i].name < libs[j].name
+	})
+	return libs
+}
+
+// returns libraries given as roots which are not used by others
+func unusedLibs(roots []*DepsNode) []string {
+	used := make(map[string]bool)
+
+	for _, root := range roots {
+		for _, name := range loadOrder(root.name)[1:] {
+			used[deps[name].path] = true
+		}
+	}
+
+	var unused []string
+	for _, root := range roots {
+		info := deps[root.name]
+		if isLibrary(&info) && !used[info.path] {
+			unused = append(unused, root.name)
+		}
+	}
+	return unused
+}
+
+func makeSummaryStrings(roots []*DepsNode) (used, unused []string) {
+	for _, lu := range libUsage(roots) {
+		used = append(used, fmt.Sprintf("  %4d  %s", len(lu.users), lu.name))
+	}
+	for _, name := range unusedLibs(roots) {
+		unused = append(unused, "  "+name)
+	}
+	return
+}
+
+func printSummary(roots []*DepsNode) {
+	used, unused := makeSummaryStrings(roots)
+
+	fmt.Println()
+	fmt.Printf("Summary of %d binaries:\n", len(roots))
+	fmt.Printf("  %4s  %s\n", "Used", "Library")
+	for _, s := range used {
+		fmt.Println(s)
+	}
+
+	if len(unused) > 0 {
+		fmt.Println()
+		fmt.Println("Unused libraries:")
+		for _, s := range unused {
+			fmt.Println(s)
+		}
+	}
+}
+
+// put multiple roots under a single node for TUI
+func makeMultiRoot(roots []*DepsNode) *DepsNode {
+	top := &DepsNode{name: fmt.Sprintf("%d binaries", len(roots))}
+
+	var shift func(dn *DepsNode)
+	shift = func(dn *DepsNode) {
+		dn.depth++
+		for _, c := range dn.child {
+			shift(c)
+		}
+	}
+
+	for _, root := range roots {
+		root.parent = top
+		shift(root)
+
+		top.child = append(top.child, root)
+	}
+	return top
+}
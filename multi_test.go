@@ -0,0 +1,76 @@
+/*
+ * ELF tree - Tree viewer for ELF library dependency
+ *
+ * Copyright (C) 2017-2018  Namhyung Kim <namhyung@gmail.com>
+ *
+ * Released under MIT license.
+ */
+package main
+
+import (
+	"debug/elf"
+	"reflect"
+	"testing"
+)
+
+func setMultiDeps() []*DepsNode {
+	deps = map[string]DepsInfo{
+		"prog-a":               {path: "/usr/bin/prog-a", kind: elf.ET_EXEC, libs: []string{"libfoo.so.1", "libc.so.6"}},
+		"prog-b":               {path: "/usr/bin/prog-b", kind: elf.ET_EXEC, libs: []string{"libc.so.6"}},
+		"libfoo.so.1":          {path: "/usr/lib/libfoo.so.1", kind: elf.ET_DYN, libs: []string{"libc.so.6"}},
+		"libc.so.6":            {path: "/usr/lib/libc.so.6", kind: elf.ET_DYN},
+		"/usr/lib/libfoo.so.1": {path: "/usr/lib/libfoo.so.1", kind: elf.ET_DYN, libs: []string{"libc.so.6"}},
+		"/usr/lib/libbar.so.1": {path: "/usr/lib/libbar.so.1", kind: elf.ET_DYN, libs: []string{"libc.so.6"}},
+	}
+
+	var roots []*DepsNode
+	for _, name := range []string{"prog-a", "prog-b", "/usr/lib/libfoo.so.1", "/usr/lib/libbar.so.1"} {
+		root := &DepsNode{name: name}
+		for _, lib := range deps[name].libs {
+			root.child = append(root.child, &DepsNode{name: lib, parent: root, depth: 1})
+		}
+		roots = append(roots, root)
+	}
+	return roots
+}
+
+func TestLibUsage(t *testing.T) {
+	roots := setMultiDeps()
+
+	want := []LibUsage{
+		{"libc.so.6", "/usr/lib/libc.so.6", []string{"prog-a", "prog-b", "/usr/lib/libfoo.so.1", "/usr/lib/libbar.so.1"}},
+		{"libfoo.so.1", "/usr/lib/libfoo.so.1", []string{"prog-a"}},
+	}
+	if got := libUsage(roots); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestUnusedLibs(t *testing.T) {
+	roots := setMultiDeps()
+
+	// libfoo is used by prog-a, and executables are never unused
+	want := []string{"/usr/lib/libbar.so.1"}
+	if got := unusedLibs(roots); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestMakeMultiRoot(t *testing.T) {
+	roots := setMultiDeps()
+
+	top := makeMultiRoot(roots)
+	if top.name != "4 binaries" || len(top.child) != len(roots) {
+		t.Fatalf("bad top node: %s with %d children", top.name, len(top.child))
+	}
+	for _, root := range top.child {
+		if root.parent != top || root.depth != 1 {
+			t.Errorf("%s: parent %v, depth %d", root.name, root.parent, root.depth)
+		}
+		for _, c := range root.child {
+			if c.depth != 2 {
+				t.Errorf("%s: depth %d", c.name, c.depth)
+			}
+		}
+	}
+}
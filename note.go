@@ -0,0 +1,242 @@
+/*
+ * ELF tree - Tree viewer for ELF library dependency
+ *
+ * Copyright (C) 2017-2018  Namhyung Kim <namhyung@gmail.com>
+ *
+ * Released under MIT license.
+ */
+package main
+
+import (
+	"debug/elf"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"strings"
+)
+
+const (
+	NT_GNU_ABI_TAG      = 1
+	NT_GNU_BUILD_ID     = 3
+	NT_GNU_GOLD_VERSION = 4
+	NT_GNU_PROPERTY     = 5
+
+	NT_PRSTATUS = 1
+	NT_PRPSINFO = 3
+	NT_AUXV     = 6
+	NT_FILE     = 0x46494c45
+)
+
+type ElfNote struct {
+	name string
+	kind uint32
+	desc []byte
+}
+
+// in 64-bit not to wrap around with crafted sizes
+func noteAlign(n uint32) uint64 {
+	return (uint64(n) + 3) &^ 3
+}
+
+// parse notes in a PT_NOTE segment or SHT_NOTE section
+func parseNotes(data []byte, order binary.ByteOrder) []ElfNote {
+	var notes []ElfNote
+
+	for len(data) >= 12 {
+		namesz := order.Uint32(data[0:4])
+		descsz := order.Uint32(data[4:8])
+		kind := order.Uint32(data[8:12])
+		data = data[12:]
+
+		if noteAlign(namesz)+noteAlign(descsz) > uint64(len(data)) {
+			break
+		}
+
+		name := data[:namesz]
+		if namesz > 0 && name[namesz-1] == 0 {
+			name = name[:namesz-1]
+		}
+		data = data[noteAlign(namesz):]
+
+		desc := data[:descsz]
+		data = data[noteAlign(descsz):]
+
+		notes = append(notes, ElfNote{string(name), kind, desc})
+	}
+	return notes
+}
+
+// returns all notes in the file
+func readNotes(f *elf.File) []ElfNote {
+	var notes []ElfNote
+
+	for _, p := range f.Progs {
+		if p.Type != elf.PT_NOTE {
+			continue
+		}
+
+		data, err := ioutil.ReadAll(p.Open())
+		if err != nil {
+			continue
+		}
+		notes = append(notes, parseNotes(data, f.ByteOrder)...)
+	}
+
+	if len(notes) > 0 {
+		return notes
+	}
+
+	// relocatable files have no program header
+	for _, s := range f.Sections {
+		if s.Type != elf.SHT_NOTE {
+			continue
+		}
+
+		data, err := s.Data()
+		if err != nil {
+			continue
+		}
+		notes = append(notes, parseNotes(data, f.ByteOrder)...)
+	}
+	return notes
+}
+
+func findBuildId(notes []ElfNote) string {
+	for _, n := range notes {
+		if n.name == "GNU" && n.kind == NT_GNU_BUILD_ID {
+			return hex.EncodeToString(n.desc)
+		}
+	}
+	return ""
+}
+
+func readBuildId(pathname string) string {
+	f, err := elf.Open(pathname)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+
+	return findBuildId(readNotes(f))
+}
+
+const (
+	NT_GO_BUILD_ID = 4
+	NT_STAPSDT     = 3
+
+	GNU_PROPERTY_AARCH64_FEATURE_1_AND = 0xc0000000
+	GNU_PROPERTY_X86_FEATURE_1_AND     = 0xc0000002
+	GNU_PROPERTY_X86_ISA_1_NEEDED      = 0xc0008002
+	GNU_PROPERTY_X86_ISA_1_USED        = 0xc0010002
+)
+
+var abiTagOS = []string{"Linux", "Hurd", "Solaris", "FreeBSD", "NetBSD", "Syllable"}
+
+// decode bits of GNU property with given names
+func propertyBits(val uint32, names []string) string {
+	var ret []string
+	for i, n := range names {
+		if val&(1<<uint(i)) != 0 {
+			ret = append(ret, n)
+		}
+	}
+	if len(ret) == 0 {
+		return "none"
+	}
+	return strings.Join(ret, ", ")
+}
+
+// decode NT_GNU_PROPERTY_TYPE_0 note
+func gnuProperties(desc []byte, order binary.ByteOrder, class elf.Class) []string {
+	var props []string
+
+	align := 8
+	if class == elf.ELFCLASS32 {
+		align = 4
+	}
+
+	for len(desc) >= 8 {
+		kind := order.Uint32(desc[0:4])
+		size := int(order.Uint32(desc[4:8]))
+		desc = desc[8:]
+		if size > len(desc) {
+			break
+		}
+
+		var val uint32
+		if size >= 4 {
+			val = order.Uint32(desc[0:4])
+		}
+
+		switch kind {
+		case GNU_PROPERTY_X86_FEATURE_1_AND:
+			props = append(props, "x86 feature: "+propertyBits(val, []string{"IBT", "SHSTK"}))
+		case GNU_PROPERTY_X86_ISA_1_NEEDED:
+			props = append(props, "x86 ISA needed: "+propertyBits(val, []string{"x86-64-baseline", "x86-64-v2", "x86-64-v3", "x86-64-v4"}))
+		case GNU_PROPERTY_X86_ISA_1_USED:
+			props = append(props, "x86 ISA used: "+propertyBits(val, []string{"x86-64-baseline", "x86-64-v2", "x86-64-v3", "x86-64-v4"}))
+		case GNU_PROPERTY_AARCH64_FEATURE_1_AND:
+			props = append(props, "AArch64 feature: "+propertyBits(val, []string{"BTI", "PAC"}))
+		default:
+			props = append(props, fmt.Sprintf("property %#x (size %d)", kind, size))
+		}
+
+		size = (size + align - 1) &^ (align - 1)
+		if size > len(desc) {
+			break
+		}
+		desc = desc[size:]
+	}
+	return props
+}
+
+func makeNoteStrings(info *DepsInfo) []string {
+	var notes []string
+
+	for _, n := range info.notes {
+		var s string
+
+		switch {
+		case n.name == "GNU" && n.kind == NT_GNU_ABI_TAG && len(n.desc) >= 16:
+			osid := info.endian.Uint32(n.desc[0:4])
+			name := fmt.Sprintf("OS %d", osid)
+			if int(osid) < len(abiTagOS) {
+				name = abiTagOS[osid]
+			}
+			s = fmt.Sprintf("ABI tag: %s %d.%d.%d", name,
+				info.endian.Uint32(n.desc[4:8]),
+				info.endian.Uint32(n.desc[8:12]),
+				info.endian.Uint32(n.desc[12:16]))
+		case n.name == "GNU" && n.kind == NT_GNU_BUILD_ID:
+			s = "Build ID: " + hex.EncodeToString(n.desc)
+		case n.name == "GNU" && n.kind == NT_GNU_GOLD_VERSION:
+			s = "Gold version: " + strings.TrimRight(string(n.desc), "\x00")
+		case n.name == "GNU" && n.kind == NT_GNU_PROPERTY:
+			for _, p := range gnuProperties(n.desc, info.endian, info.bits) {
+				notes = append(notes, "  Property: "+p)
+			}
+			continue
+		case n.name == "Go" && n.kind == NT_GO_BUILD_ID:
+			s = "Go build ID: " + strings.TrimRight(string(n.desc), "\x00")
+		case n.name == "stapsdt" && n.kind == NT_STAPSDT:
+			// skip pc, base and semaphore addresses
+			skip := 24
+			if info.bits == elf.ELFCLASS32 {
+				skip = 12
+			}
+			if len(n.desc) < skip {
+				continue
+			}
+			probe := strings.SplitN(string(n.desc[skip:]), "\x00", 3)
+			if len(probe) < 2 {
+				continue
+			}
+			s = "SDT probe: " + probe[0] + ":" + probe[1]
+		default:
+			s = fmt.Sprintf("%s: type %#x, size %d", n.name, n.kind, len(n.desc))
+		}
+		notes = append(notes, "  "+s)
+	}
+	return notes
+}
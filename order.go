@@ -0,0 +1,140 @@
+/*
+ * ELF tree - Tree viewer for ELF library dependency
+ *
+ * Copyright (C) 2017-2018  Namhyung Kim <namhyung@gmail.com>
+ *
+ * Released under MIT license.
+ */
+package main
+
+import (
+	"debug/elf"
+	"fmt"
+	"strings"
+)
+
+// breadth-first, deduplicated list of objects as ld.so loads them.
+// it's the global search scope when started from the executable.
+func loadOrder(name string) []string {
+	seen := map[string]bool{name: true}
+	order := []string{name}
+
+	for i := 0; i < len(order); i++ {
+		for _, lib := range deps[order[i]].libs {
+			if seen[lib] {
+				continue
+			}
+			seen[lib] = true
+			order = append(order, lib)
+		}
+	}
+	return order
+}
+
+func walkInitOrder(name string, visited map[string]bool, order []string) []string {
+	visited[name] = true
+
+	for _, lib := range deps[name].libs {
+		if visited[lib] {
+			continue
+		}
+		order = walkInitOrder(lib, visited, order)
+	}
+	return append(order, name)
+}
+
+// initializers run in reverse topological order: dependencies first.
+// like ld.so (glibc 2.35+), objects are visited in reverse load order.
+func initOrder(name string) []string {
+	visited := make(map[string]bool)
+	load := loadOrder(name)
+
+	var order []string
+	for i := len(load) - 1; i >= 0; i-- {
+		if !visited[load[i]] {
+			order = walkInitOrder(load[i], visited, order)
+		}
+	}
+	return order
+}
+
+// returns names of initializer entries in the object
+func initFuncs(info *DepsInfo) []string {
+	var inits []string
+
+	for _, dyn := range info.dyns {
+		switch dyn.tag {
+		case elf.DT_INIT:
+			inits = append(inits, "DT_INIT")
+		case elf.DT_INIT_ARRAY:
+			inits = append(inits, "DT_INIT_ARRAY")
+		}
+	}
+	return inits
+}
+
+func printLoadOrder(root *DepsNode) {
+	global := loadOrder(root.name)
+
+	fmt.Println("Global search scope:")
+	for i, name := range global {
+		fmt.Printf("  %3d  %s\n", i+1, name)
+	}
+
+	fmt.Println()
+	fmt.Println("Local scope:")
+	for _, name := range global {
+		fmt.Printf("  %s: %s\n", name, strings.Join(loadOrder(name), " "))
+	}
+
+	fmt.Println()
+	fmt.Println("Initializer order:")
+	for i, name := range initOrder(root.name) {
+		info := deps[name]
+		inits := initFuncs(&info)
+
+		if len(inits) > 0 {
+			fmt.Printf("  %3d  %s  (%s)\n", i+1, name, strings.Join(inits, ", "))
+		} else {
+			fmt.Printf("  %3d  %s\n", i+1, name)
+		}
+	}
+
+	fmt.Println()
+	fmt.Println("Constructor order:")
+	for _, s := range makeOrderStrings(constructorOrder(root.name)) {
+		fmt.Println(s)
+	}
+
+	fmt.Println()
+	fmt.Println("Destructor order:")
+	for _, s := range makeOrderStrings(destructorOrder(root.name)) {
+		fmt.Println(s)
+	}
+}
+
+// build a tree of load order and initializer order for TUI
+func makeOrderDeps(root *DepsNode) *DepsNode {
+	top := &DepsNode{name: "ld.so order"}
+
+	scope := &DepsNode{name: "Global search scope", parent: top, depth: 1}
+	for _, name := range loadOrder(root.name) {
+		n := &DepsNode{name: name, parent: scope, depth: 2}
+
+		// local scope except itself
+		for _, lib := range loadOrder(name)[1:] {
+			l := &DepsNode{name: lib, parent: n, depth: 3}
+			n.child = append(n.child, l)
+		}
+		scope.child = append(scope.child, n)
+	}
+
+	inits := &DepsNode{name: "Initializer order", parent: top, depth: 1}
+	for _, name := range initOrder(root.name) {
+		n := &DepsNode{name: name, parent: inits, depth: 2}
+		inits.child = append(inits.child, n)
+	}
+
+	top.child = []*DepsNode{scope, inits}
+	return top
+}
@@ -0,0 +1,61 @@
+/*
+ * ELF tree - Tree viewer for ELF library dependency
+ *
+ * Copyright (C) 2017-2018  Namhyung Kim <namhyung@gmail.com>
+ *
+ * Released under MIT license.
+ */
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// prog needs libp and libq, which need libr and libs in turn
+func setOrderDeps() {
+	deps = map[string]DepsInfo{
+		"prog":                 {libs: []string{"libp.so", "libq.so", "libc.so.6"}},
+		"libp.so":              {libs: []string{"libr.so", "libc.so.6"}},
+		"libq.so":              {libs: []string{"libs.so", "libr.so", "libc.so.6"}},
+		"libr.so":              {libs: []string{"libs.so", "libc.so.6"}},
+		"libs.so":              {libs: []string{"libc.so.6"}},
+		"libc.so.6":            {libs: []string{"ld-linux-x86-64.so.2"}},
+		"ld-linux-x86-64.so.2": {},
+	}
+}
+
+func checkOrder(t *testing.T, what string, got []string, want string) {
+	t.Helper()
+	if s := strings.Join(got, " "); s != want {
+		t.Errorf("%s:\n got: %s\nwant: %s", what, s, want)
+	}
+}
+
+// expected orders are from LD_DEBUG=files of the same objects with glibc 2.36
+func TestLoadOrder(t *testing.T) {
+	setOrderDeps()
+
+	checkOrder(t, "global scope", loadOrder("prog"),
+		"prog libp.so libq.so libc.so.6 libr.so libs.so ld-linux-x86-64.so.2")
+	checkOrder(t, "local scope", loadOrder("libq.so"),
+		"libq.so libs.so libr.so libc.so.6 ld-linux-x86-64.so.2")
+}
+
+func TestInitOrder(t *testing.T) {
+	setOrderDeps()
+
+	// libq runs before libp as it's loaded later
+	checkOrder(t, "initializers", initOrder("prog"),
+		"ld-linux-x86-64.so.2 libc.so.6 libs.so libr.so libq.so libp.so prog")
+}
+
+func TestInitOrderCycle(t *testing.T) {
+	deps = map[string]DepsInfo{
+		"prog":    {libs: []string{"liba.so"}},
+		"liba.so": {libs: []string{"libb.so"}},
+		"libb.so": {libs: []string{"liba.so"}},
+	}
+
+	checkOrder(t, "initializers", initOrder("prog"), "liba.so libb.so prog")
+}
@@ -0,0 +1,341 @@
+/*
+ * ELF tree - Tree viewer for ELF library dependency
+ *
+ * Copyright (C) 2017-2018  Namhyung Kim <namhyung@gmail.com>
+ *
+ * Released under MIT license.
+ */
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var RPM_MAGIC = []byte{0xed, 0xab, 0xee, 0xdb}
+
+const (
+	RPM_LEAD_SIZE  = 96
+	CPIO_HDR_SIZE  = 110
+	CPIO_TRAILER   = "TRAILER!!!"
+	CPIO_MODE_TYPE = 0170000
+	CPIO_MODE_DIR  = 0040000
+	CPIO_MODE_REG  = 0100000
+	CPIO_MODE_LNK  = 0120000
+)
+
+func isDebPackage(data []byte) bool {
+	return bytes.HasPrefix(data, []byte(AR_MAGIC+"debian-binary"))
+}
+
+func isRpmPackage(data []byte) bool {
+	return bytes.HasPrefix(data, RPM_MAGIC)
+}
+
+// read data.tar.* in the .deb package
+func loadDebPackage(data []byte) (*memFS, error) {
+	ar, err := parseArchive(data)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, m := range ar.members {
+		if !strings.HasPrefix(m.name, "data.tar") {
+			continue
+		}
+
+		r, err := decompress(m.data)
+		if err != nil {
+			return nil, err
+		}
+
+		fs := newMemFS()
+		if err := fs.applyLayer(r); err != nil {
+			return nil, err
+		}
+		return fs, nil
+	}
+	return nil, errors.New("no data.tar in the package")
+}
+
+// returns size of the rpm header structure at the offset
+func rpmHeaderSize(data []byte, off int) (int, error) {
+	if off+16 > len(data) || !bytes.HasPrefix(data[off:], []byte{0x8e, 0xad, 0xe8, 0x01}) {
+		return 0, errors.New("bad rpm header")
+	}
+
+	// check before converting to int to avoid overflow
+	nindex := uint64(binary.BigEndian.Uint32(data[off+8:]))
+	hsize := uint64(binary.BigEndian.Uint32(data[off+12:]))
+	avail := uint64(len(data) - off - 16)
+
+	if nindex > avail/16 || hsize > avail-16*nindex {
+		return 0, errors.New("truncated rpm header")
+	}
+	return 16 + int(16*nindex+hsize), nil
+}
+
+// read cpio payload in the .rpm package
+func loadRpmPackage(data []byte) (*memFS, error) {
+	off := RPM_LEAD_SIZE
+
+	// signature header is aligned to 8 bytes
+	size, err := rpmHeaderSize(data, off)
+	if err != nil {
+		return nil, err
+	}
+	off += (size + 7) &^ 7
+
+	size, err = rpmHeaderSize(data, off)
+	if err != nil {
+		return nil, err
+	}
+	off += size
+
+	r, err := decompress(data[off:])
+	if err != nil {
+		return nil, err
+	}
+
+	payload, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	fs := newMemFS()
+	if err := fs.applyCpio(payload); err != nil {
+		return nil, err
+	}
+	return fs, nil
+}
+
+// apply cpio archive in the "newc" format
+func (fs *memFS) applyCpio(data []byte) error {
+	// hard links have data in the last entry only
+	links := make(map[uint64][]*memEntry)
+
+	off := 0
+	for off+CPIO_HDR_SIZE <= len(data) {
+		hdr := data[off : off+CPIO_HDR_SIZE]
+		magic := string(hdr[0:6])
+		if magic != "070701" && magic != "070702" {
+			return errors.New("bad cpio header")
+		}
+
+		var field [13]uint64
+		for i := range field {
+			v, err := strconv.ParseUint(string(hdr[6+i*8:14+i*8]), 16, 32)
+			if err != nil {
+				return err
+			}
+			field[i] = v
+		}
+
+		ino := field[0]
+		mode := field[1]
+		mtime := field[5]
+		filesize := int(field[6])
+		namesize := int(field[11])
+
+		start := off + CPIO_HDR_SIZE
+		if start+namesize > len(data) {
+			return errors.New("truncated cpio archive")
+		}
+		name := strings.TrimRight(string(data[start:start+namesize]), "\x00")
+
+		start = (start + namesize + 3) &^ 3
+		if start+filesize > len(data) {
+			return errors.New("truncated cpio archive")
+		}
+		body := data[start : start+filesize]
+		off = (start + filesize + 3) &^ 3
+
+		if name == CPIO_TRAILER {
+			return nil
+		}
+
+		e := &memEntry{
+			name:  path.Clean("/" + name),
+			mode:  os.FileMode(mode & 0777),
+			mtime: time.Unix(int64(mtime), 0),
+		}
+
+		switch mode & CPIO_MODE_TYPE {
+		case CPIO_MODE_DIR:
+			e.mode |= os.ModeDir
+		case CPIO_MODE_LNK:
+			e.mode |= os.ModeSymlink
+			e.link = string(body)
+		case CPIO_MODE_REG:
+			e.data = body
+			if field[4] > 1 {
+				links[ino] = append(links[ino], e)
+				if filesize > 0 {
+					for _, l := range links[ino] {
+						l.data = body
+					}
+				}
+			}
+		default:
+			continue
+		}
+
+		if e.name != "/" {
+			fs.add(e)
+		}
+	}
+	return errors.New("truncated cpio archive")
+}
+
+func loadPackage(pathname string) (*memFS, error) {
+	data, err := ioutil.ReadFile(pathname)
+	if err != nil {
+		return nil, err
+	}
+
+	if isDebPackage(data) {
+		return loadDebPackage(data)
+	}
+	if isRpmPackage(data) {
+		return loadRpmPackage(data)
+	}
+	return nil, errors.New("unknown package format")
+}
+
+func isPackage(pathname string) bool {
+	f, err := os.Open(pathname)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+
+	buf := make([]byte, len(AR_MAGIC)+len("debian-binary"))
+	if _, err := io.ReadFull(f, buf); err != nil {
+		return false
+	}
+	return isDebPackage(buf) || isRpmPackage(buf)
+}
+
+// returns libraries satisfied by the package and others
+func pkgDeps(pkgfs *memFS, roots []*DepsNode) (internal, external []string) {
+	isRoot := make(map[string]bool)
+	for _, root := range roots {
+		isRoot[root.name] = true
+	}
+
+	var names []string
+	for k := range deps {
+		if !isRoot[k] {
+			names = append(names, k)
+		}
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		p := deps[name].path
+		if _, err := pkgfs.Stat(p); p != "" && err == nil {
+			internal = append(internal, name)
+		} else {
+			external = append(external, name)
+		}
+	}
+	return
+}
+
+func printPkgDeps(pkgfs *memFS, roots []*DepsNode) {
+	internal, external := pkgDeps(pkgfs, roots)
+
+	fmt.Println()
+	fmt.Println("Satisfied by the package:")
+	for _, name := range internal {
+		fmt.Printf("  %s  => %s\n", name, deps[name].path)
+	}
+
+	fmt.Println()
+	fmt.Println("External dependencies:")
+	for _, name := range external {
+		if p := deps[name].path; p != "" {
+			fmt.Printf("  %s  => %s\n", name, p)
+		} else {
+			fmt.Printf("  %s  (not found)\n", name)
+		}
+	}
+}
+
+func pkgMain(pathname string) {
+	pkgfs, err := loadPackage(pathname)
+	if err != nil {
+		fmt.Printf("elftree: %v: %s\n", err, pathname)
+		os.Exit(1)
+	}
+
+	// look up the package contents first, then the host (or --root)
+	var lower FileSystem = hostFS{}
+	if rootDir != "" {
+		lower = rootFS{dir: rootDir}
+	}
+	sysfs = overlayFS{upper: pkgfs, lower: lower}
+	conflib = readLdSoConf("/etc/ld.so.conf", nil)
+
+	var files []string
+	pkgfs.Walk("/", func(p string, fi os.FileInfo, err error) error {
+		if err == nil && fi.Mode().IsRegular() && isElfBinary(p) {
+			files = append(files, p)
+		}
+		return nil
+	})
+	if len(files) == 0 {
+		fmt.Printf("elftree: no ELF binary found in %s\n", pathname)
+		os.Exit(1)
+	}
+
+	// external libraries might not be available
+	allowMissing = true
+
+	var roots []*DepsNode
+	for _, file := range files {
+		roots = append(roots, loadDeps(file, file))
+	}
+
+	depsMarks = make(map[string]rune)
+	depsNotes = make(map[string]string)
+
+	internal, external := pkgDeps(pkgfs, roots)
+	for _, name := range internal {
+		depsNotes[name] = "in the package"
+	}
+	for _, name := range external {
+		depsMarks[name] = '*'
+		depsNotes[name] = "external"
+		if deps[name].path == "" {
+			depsMarks[name] = '?'
+			depsNotes[name] = "not found"
+		}
+	}
+
+	if showTui {
+		if len(roots) > 1 {
+			ShowWithTUI(makeMultiRoot(roots))
+		} else {
+			ShowWithTUI(roots[0])
+		}
+		return
+	}
+
+	for _, root := range roots {
+		f := openElf(root.name)
+		printDepTree(root, f)
+		f.Close()
+	}
+	printPkgDeps(pkgfs, roots)
+}
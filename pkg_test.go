@@ -0,0 +1,151 @@
+/*
+ * ELF tree - Tree viewer for ELF library dependency
+ *
+ * Copyright (C) 2017-2018  Namhyung Kim <namhyung@gmail.com>
+ *
+ * Released under MIT license.
+ */
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+	"testing"
+)
+
+// append a cpio entry in the "newc" format
+func cpioEntry(buf *bytes.Buffer, name string, ino, mode, nlink int, data string) {
+	fmt.Fprintf(buf, "070701%08x%08x%08x%08x%08x%08x%08x%08x%08x%08x%08x%08x%08x",
+		ino, mode, 0, 0, nlink, 0, len(data), 0, 0, 0, 0, len(name)+1, 0)
+	buf.WriteString(name + "\x00")
+	for buf.Len()%4 != 0 {
+		buf.WriteByte(0)
+	}
+	buf.WriteString(data)
+	for buf.Len()%4 != 0 {
+		buf.WriteByte(0)
+	}
+}
+
+func makeCpio() []byte {
+	var buf bytes.Buffer
+	cpioEntry(&buf, ".", 1, CPIO_MODE_DIR|0755, 2, "")
+	cpioEntry(&buf, "./usr/lib", 2, CPIO_MODE_DIR|0755, 2, "")
+	cpioEntry(&buf, "./usr/lib/libfoo.so.1.0", 3, CPIO_MODE_REG|0755, 1, "ELF")
+	cpioEntry(&buf, "./usr/lib/libfoo.so.1", 4, CPIO_MODE_LNK|0777, 1, "libfoo.so.1.0")
+	// hard links have data in the last entry
+	cpioEntry(&buf, "./usr/bin/a", 5, CPIO_MODE_REG|0755, 2, "")
+	cpioEntry(&buf, "./usr/bin/b", 5, CPIO_MODE_REG|0755, 2, "BIN")
+	cpioEntry(&buf, CPIO_TRAILER, 0, 0, 1, "")
+	return buf.Bytes()
+}
+
+func readMemFile(t *testing.T, fs *memFS, name string) string {
+	r, err := fs.Open(name)
+	if err != nil {
+		t.Fatalf("%s: %v", name, err)
+	}
+	defer r.Close()
+
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatalf("%s: %v", name, err)
+	}
+	return string(data)
+}
+
+func TestApplyCpio(t *testing.T) {
+	fs := newMemFS()
+	if err := fs.applyCpio(makeCpio()); err != nil {
+		t.Fatal(err)
+	}
+
+	files := map[string]string{
+		"/usr/lib/libfoo.so.1.0": "ELF",
+		"/usr/lib/libfoo.so.1":   "ELF",
+		"/usr/bin/a":             "BIN",
+		"/usr/bin/b":             "BIN",
+	}
+	for name, want := range files {
+		if got := readMemFile(t, fs, name); got != want {
+			t.Errorf("%s: got %q, want %q", name, got, want)
+		}
+	}
+
+	if fi, err := fs.Stat("/usr/lib"); err != nil || !fi.IsDir() {
+		t.Errorf("/usr/lib is not a directory: %v", err)
+	}
+}
+
+func TestApplyBadCpio(t *testing.T) {
+	data := makeCpio()
+
+	// cut in the middle of a name, of file data and before the trailer
+	for _, n := range []int{CPIO_HDR_SIZE + 1, 369, len(data) - 120} {
+		if err := newMemFS().applyCpio(data[:n]); err == nil {
+			t.Errorf("no error for truncated cpio at %d", n)
+		}
+	}
+
+	bad := append([]byte("070799"), data[6:]...)
+	if err := newMemFS().applyCpio(bad); err == nil {
+		t.Error("no error for bad magic")
+	}
+}
+
+// rpm header structure with the given index count and data size
+func rpmHeader(nindex, hsize uint32) []byte {
+	hdr := []byte{0x8e, 0xad, 0xe8, 0x01, 0, 0, 0, 0}
+	hdr = binary.BigEndian.AppendUint32(hdr, nindex)
+	return binary.BigEndian.AppendUint32(hdr, hsize)
+}
+
+func TestRpmHeaderSize(t *testing.T) {
+	data := append(rpmHeader(2, 8), make([]byte, 2*16+8)...)
+	if size, err := rpmHeaderSize(data, 0); err != nil || size != 16+2*16+8 {
+		t.Errorf("rpmHeaderSize() = %d, %v", size, err)
+	}
+
+	tests := [][]byte{
+		rpmHeader(2, 8)[:12],                                  // truncated header
+		append(rpmHeader(2, 8), make([]byte, 16)...),          // truncated index
+		append(rpmHeader(0xffffffff, 0), make([]byte, 64)...), // too many indices
+		append(rpmHeader(0, 0xffffffff), make([]byte, 64)...), // too big data
+		append(rpmHeader(4, 0xfffffff0), make([]byte, 64)...), // overflow
+	}
+	for i, data := range tests {
+		if _, err := rpmHeaderSize(data, 0); err == nil {
+			t.Errorf("test %d: no error", i)
+		}
+	}
+}
+
+func TestLoadRpmPackage(t *testing.T) {
+	var buf bytes.Buffer
+	buf.Write(RPM_MAGIC)
+	buf.Write(make([]byte, RPM_LEAD_SIZE-len(RPM_MAGIC)))
+
+	// signature (aligned to 8 bytes) and the main header
+	buf.Write(rpmHeader(0, 4))
+	buf.Write(make([]byte, 8))
+	buf.Write(rpmHeader(0, 0))
+
+	zw := gzip.NewWriter(&buf)
+	zw.Write(makeCpio())
+	zw.Close()
+
+	fs, err := loadRpmPackage(buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := readMemFile(t, fs, "/usr/lib/libfoo.so.1"); got != "ELF" {
+		t.Errorf("got %q, want %q", got, "ELF")
+	}
+
+	if _, err := loadRpmPackage(buf.Bytes()[:RPM_LEAD_SIZE+20]); err == nil {
+		t.Error("no error for truncated rpm")
+	}
+}
@@ -0,0 +1,350 @@
+/*
+ * ELF tree - Tree viewer for ELF library dependency
+ *
+ * Copyright (C) 2017-2018  Namhyung Kim <namhyung@gmail.com>
+ *
+ * Released under MIT license.
+ */
+package main
+
+import (
+	"debug/elf"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"sort"
+
+	"golang.org/x/arch/x86/x86asm"
+)
+
+type PltEntry struct {
+	addr uint64 // address of the stub
+	slot uint64 // GOT entry used by the stub
+	name string // imported symbol
+	sect string
+}
+
+var pltSections = []string{".plt", ".plt.sec", ".plt.got"}
+
+// PLT entries of each object
+var pltCache = make(map[string][]PltEntry)
+
+func readSectionData(path string, sect []*elf.Section, idx int) ([]byte, error) {
+	r, err := sectionData(path, sect, idx)
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(r)
+}
+
+func findSection(sect []*elf.Section, name string) int {
+	for i, s := range sect {
+		if s.Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// returns imported symbol names of GOT entries from dynamic relocations
+func readGotSlots(info *DepsInfo) map[uint64]string {
+	slots := make(map[uint64]string)
+
+	var jumpSlot, globDat uint32
+	switch info.mach {
+	case elf.EM_X86_64:
+		jumpSlot = uint32(elf.R_X86_64_JMP_SLOT)
+		globDat = uint32(elf.R_X86_64_GLOB_DAT)
+	case elf.EM_386:
+		jumpSlot = uint32(elf.R_386_JMP_SLOT)
+		globDat = uint32(elf.R_386_GLOB_DAT)
+	case elf.EM_AARCH64:
+		jumpSlot = uint32(elf.R_AARCH64_JUMP_SLOT)
+		globDat = uint32(elf.R_AARCH64_GLOB_DAT)
+	default:
+		return slots
+	}
+
+	for i, s := range info.sect {
+		if s.Type != elf.SHT_REL && s.Type != elf.SHT_RELA {
+			continue
+		}
+		if int(s.Link) >= len(info.sect) || info.sect[s.Link].Type != elf.SHT_DYNSYM {
+			continue
+		}
+
+		relocs, err := readRelocs(info, i)
+		if err != nil {
+			continue
+		}
+
+		for _, r := range relocs {
+			if r.typ != jumpSlot && r.typ != globDat {
+				continue
+			}
+			// DynamicSymbols() skips the first (null) symbol
+			if r.sym == 0 || int(r.sym) > len(info.dsym) {
+				continue
+			}
+			slots[r.off] = info.dsym[r.sym-1].Name
+		}
+	}
+	return slots
+}
+
+// returns GOT entries used by indirect jumps in x86 PLT stubs
+func x86PltSlots(code []byte, addr uint64, mode int, gotBase uint64) ([]uint64, []uint64) {
+	var pcs, slots []uint64
+
+	for off := 0; off < len(code); {
+		inst, err := x86asm.Decode(code[off:], mode)
+		if err != nil {
+			off++
+			continue
+		}
+		pc := addr + uint64(off)
+		off += inst.Len
+
+		if inst.Op != x86asm.JMP {
+			continue
+		}
+		mem, ok := inst.Args[0].(x86asm.Mem)
+		if !ok {
+			continue
+		}
+
+		switch mem.Base {
+		case x86asm.RIP:
+			slots = append(slots, pc+uint64(inst.Len)+uint64(mem.Disp))
+		case x86asm.EBX:
+			// PIC stub in i386 uses GOT address in %ebx
+			slots = append(slots, gotBase+uint64(uint32(mem.Disp)))
+		case 0:
+			slots = append(slots, uint64(uint32(mem.Disp)))
+		default:
+			continue
+		}
+		pcs = append(pcs, pc)
+	}
+	return pcs, slots
+}
+
+// returns GOT entries loaded by "adrp x16; ldr x17, [x16, #off]" in aarch64 PLT stubs
+func arm64PltSlots(code []byte, addr uint64, bo binary.ByteOrder) ([]uint64, []uint64) {
+	var pcs, slots []uint64
+	var page uint64
+	var adrp uint64
+
+	for off := 0; off+4 <= len(code); off += 4 {
+		enc := bo.Uint32(code[off:])
+		pc := addr + uint64(off)
+
+		if enc&0x9f000000 == 0x90000000 && enc&0x1f == 16 {
+			// adrp x16, page
+			imm := int64((enc>>5)&0x7ffff)<<2 | int64((enc>>29)&3)
+			imm = imm << 43 >> 43 // sign extend 21 bits
+			page = pc&^0xfff + uint64(imm<<12)
+			adrp = pc
+			continue
+		}
+
+		if enc&0xffc00000 == 0xf9400000 && (enc>>5)&0x1f == 16 && adrp != 0 {
+			// ldr x17, [x16, #off]
+			pcs = append(pcs, adrp)
+			slots = append(slots, page+uint64((enc>>10)&0xfff)*8)
+			adrp = 0
+		}
+	}
+	return pcs, slots
+}
+
+// returns PLT stubs with their imported symbols
+func readPltEntries(name string) []PltEntry {
+	if plt, ok := pltCache[name]; ok {
+		return plt
+	}
+
+	info := deps[name]
+	gotSlots := readGotSlots(&info)
+
+	var gotBase uint64
+	if i := findSection(info.sect, ".got.plt"); i >= 0 {
+		gotBase = info.sect[i].Addr
+	} else if i := findSection(info.sect, ".got"); i >= 0 {
+		gotBase = info.sect[i].Addr
+	}
+
+	var plt []PltEntry
+	for _, sname := range pltSections {
+		idx := findSection(info.sect, sname)
+		if idx < 0 {
+			continue
+		}
+		s := info.sect[idx]
+
+		code, err := readSectionData(info.path, info.sect, idx)
+		if err != nil {
+			continue
+		}
+
+		var pcs, slots []uint64
+		switch info.mach {
+		case elf.EM_X86_64:
+			pcs, slots = x86PltSlots(code, s.Addr, 64, gotBase)
+		case elf.EM_386:
+			pcs, slots = x86PltSlots(code, s.Addr, 32, gotBase)
+		case elf.EM_AARCH64:
+			pcs, slots = arm64PltSlots(code, s.Addr, info.endian)
+		}
+
+		entsize := s.Entsize
+		if entsize == 0 {
+			entsize = 16
+		}
+
+		for i, pc := range pcs {
+			sym, ok := gotSlots[slots[i]]
+			if !ok {
+				continue // e.g. PLT0 calling the dynamic linker
+			}
+
+			// start of the stub
+			start := s.Addr + (pc-s.Addr)/entsize*entsize
+			plt = append(plt, PltEntry{addr: start, slot: slots[i], name: sym, sect: sname})
+		}
+	}
+
+	sort.Slice(plt, func(i, j int) bool { return plt[i].addr < plt[j].addr })
+	pltCache[name] = plt
+	return plt
+}
+
+// check whether all symbols are bound at load time
+func bindNow(info *DepsInfo) bool {
+	for _, dyn := range info.dyns {
+		switch dyn.tag {
+		case elf.DT_BIND_NOW:
+			return true
+		case elf.DT_FLAGS:
+			if dyn.val.(uint64)&0x8 != 0 { // DF_BIND_NOW
+				return true
+			}
+		case DT_FLAGS_1:
+			if dyn.val.(uint64)&0x1 != 0 { // DF_1_NOW
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// returns entries in the GOT section with their initial values
+func makeGotStrings(name string, info *DepsInfo, idx int, slots map[uint64]string) []string {
+	s := info.sect[idx]
+
+	data, err := readSectionData(info.path, info.sect, idx)
+	if err != nil {
+		return []string{"  error: " + err.Error()}
+	}
+
+	word := 8
+	if info.bits == elf.ELFCLASS32 {
+		word = 4
+	}
+
+	syms := makeSymIndex(name)
+	plt := make(map[string]bool)
+	for _, n := range pltSections {
+		plt[n] = true
+	}
+
+	lines := []string{fmt.Sprintf("  %16s  %16s  %s", "Slot", "Value", "Symbol")}
+	for off := 0; off+word <= len(data); off += word {
+		addr := s.Addr + uint64(off)
+
+		var val uint64
+		if word == 4 {
+			val = uint64(info.endian.Uint32(data[off:]))
+		} else {
+			val = info.endian.Uint64(data[off:])
+		}
+
+		var note string
+		if sym, ok := slots[addr]; ok {
+			note = symName(sym)
+			if t := addrSection(info, val); t != nil && plt[t.Name] {
+				note += " (lazy)"
+			}
+		} else if s.Name == ".got.plt" && off < 3*word {
+			// reserved entries
+			if off == 0 {
+				note = "_DYNAMIC"
+			} else {
+				note = "(reserved for dynamic linker)"
+			}
+		} else if val != 0 {
+			if n := syms.lookup(val); n != "" {
+				note = "<" + n + ">"
+			}
+		}
+
+		lines = append(lines, fmt.Sprintf("  %16x  %16x  %s", addr, val, note))
+	}
+	return lines
+}
+
+func makePltInfo(name string, info *DepsInfo) *FileInfo {
+	root := &TreeItem{node: name}
+
+	if info.endian == nil {
+		AddSubTree("", nil, root)
+		AddSubTree("No PLT info", nil, root)
+		return &FileInfo{Root: root, Top: root, Curr: root}
+	}
+
+	plt := readPltEntries(name)
+	slots := readGotSlots(info)
+
+	binding := "lazy"
+	if bindNow(info) {
+		binding = "immediate (BIND_NOW)"
+	}
+	summary := []string{"  Binding: " + binding}
+	for _, n := range pltSections {
+		if idx := findSection(info.sect, n); idx >= 0 {
+			cnt := 0
+			for _, p := range plt {
+				if p.sect == n {
+					cnt++
+				}
+			}
+			summary = append(summary, fmt.Sprintf("  %-9s %d entries, %d bytes", n+":", cnt, info.sect[idx].Size))
+		}
+	}
+	if len(plt) == 0 && !canDisasm(info.mach) {
+		summary = append(summary, "  PLT is not decoded for "+info.mach.String())
+	}
+
+	AddSubTree("", nil, root)
+	AddSubTree("PLT/GOT Info", summary, root)
+
+	var stubs []string
+	stubs = append(stubs, fmt.Sprintf("  %16s  %-9s %16s  %s", "Stub", "Section", "GOT Slot", "Symbol"))
+	for _, p := range plt {
+		stubs = append(stubs, fmt.Sprintf("  %16x  %-9s %16x  %s", p.addr, p.sect, p.slot, symName(p.name)))
+	}
+	AddSubTree("", nil, root)
+	AddSubTree(fmt.Sprintf("PLT Entries (%d)", len(plt)), stubs, root)
+
+	for _, n := range []string{".got.plt", ".got"} {
+		idx := findSection(info.sect, n)
+		if idx < 0 || info.sect[idx].Type == elf.SHT_NOBITS {
+			continue
+		}
+
+		AddSubTree("", nil, root)
+		AddSubTree(n, makeGotStrings(name, info, idx, slots), root)
+	}
+
+	return &FileInfo{Root: root, Top: root, Curr: root}
+}
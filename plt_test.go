@@ -0,0 +1,106 @@
+/*
+ * ELF tree - Tree viewer for ELF library dependency
+ *
+ * Copyright (C) 2017-2018  Namhyung Kim <namhyung@gmail.com>
+ *
+ * Released under MIT license.
+ */
+package main
+
+import (
+	"debug/elf"
+	"encoding/binary"
+	"reflect"
+	"testing"
+)
+
+func checkSlots(t *testing.T, what string, pcs, slots, wantPcs, wantSlots []uint64) {
+	t.Helper()
+	if !reflect.DeepEqual(pcs, wantPcs) || !reflect.DeepEqual(slots, wantSlots) {
+		t.Errorf("%s: got pcs %#x slots %#x, want %#x %#x", what, pcs, slots, wantPcs, wantSlots)
+	}
+}
+
+func TestX86PltSlots64(t *testing.T) {
+	// .plt entries at 0x1020 using GOT at 0x4000
+	code := []byte{
+		0xff, 0x25, 0xe2, 0x2f, 0x00, 0x00, // jmp *0x2fe2(%rip)
+		0x68, 0x00, 0x00, 0x00, 0x00, // push $0x0
+		0xe9, 0xe0, 0xff, 0xff, 0xff, // jmp 0x1000
+		0xff, 0x25, 0xda, 0x2f, 0x00, 0x00, // jmp *0x2fda(%rip)
+		0x68, 0x01, 0x00, 0x00, 0x00, // push $0x1
+		0xe9, 0xd0, 0xff, 0xff, 0xff, // jmp 0x1000
+	}
+
+	pcs, slots := x86PltSlots(code, 0x1020, 64, 0)
+	checkSlots(t, "x86_64", pcs, slots, []uint64{0x1020, 0x1030}, []uint64{0x4008, 0x4010})
+}
+
+func TestX86PltSlots32(t *testing.T) {
+	pic := []byte{
+		0xff, 0xa3, 0x0c, 0x00, 0x00, 0x00, // jmp *0xc(%ebx)
+		0x68, 0x00, 0x00, 0x00, 0x00, // push $0x0
+		0xe9, 0xe0, 0xff, 0xff, 0xff, // jmp .plt
+	}
+	pcs, slots := x86PltSlots(pic, 0x1030, 32, 0x4000)
+	checkSlots(t, "i386 PIC", pcs, slots, []uint64{0x1030}, []uint64{0x400c})
+
+	abs := []byte{
+		0xff, 0x25, 0x0c, 0xa0, 0x04, 0x08, // jmp *0x804a00c
+		0x68, 0x00, 0x00, 0x00, 0x00, // push $0x0
+		0xe9, 0xe0, 0xff, 0xff, 0xff, // jmp .plt
+	}
+	pcs, slots = x86PltSlots(abs, 0x8049030, 32, 0)
+	checkSlots(t, "i386", pcs, slots, []uint64{0x8049030}, []uint64{0x804a00c})
+}
+
+func arm64Code(insts ...uint32) []byte {
+	code := make([]byte, 4*len(insts))
+	for i, inst := range insts {
+		binary.LittleEndian.PutUint32(code[4*i:], inst)
+	}
+	return code
+}
+
+func TestArm64PltSlots(t *testing.T) {
+	code := arm64Code(
+		0x90000090, // adrp x16, 0x11000
+		0xf947c211, // ldr  x17, [x16, #0xf80]
+		0x913e0210, // add  x16, x16, #0xf80
+		0xd61f0220, // br   x17
+		0x90000090, // adrp x16, 0x11000
+		0xf947c611, // ldr  x17, [x16, #0xf88]
+		0x913e2210, // add  x16, x16, #0xf88
+		0xd61f0220, // br   x17
+	)
+	pcs, slots := arm64PltSlots(code, 0x1000, binary.LittleEndian)
+	checkSlots(t, "aarch64", pcs, slots, []uint64{0x1000, 0x1010}, []uint64{0x11f80, 0x11f88})
+
+	// GOT below the PLT
+	code = arm64Code(
+		0xf0fffff0, // adrp x16, 0x1000
+		0xf9400611, // ldr  x17, [x16, #0x8]
+		0xd61f0220, // br   x17
+	)
+	pcs, slots = arm64PltSlots(code, 0x2000, binary.LittleEndian)
+	checkSlots(t, "aarch64 backward", pcs, slots, []uint64{0x2000}, []uint64{0x1008})
+}
+
+func TestBindNow(t *testing.T) {
+	tests := []struct {
+		dyns []DynInfo
+		want bool
+	}{
+		{nil, false},
+		{[]DynInfo{{elf.DT_NEEDED, "libc.so.6"}, {elf.DT_FLAGS, uint64(0x10)}}, false}, // DF_STATIC_TLS
+		{[]DynInfo{{elf.DT_BIND_NOW, uint64(0)}}, true},
+		{[]DynInfo{{elf.DT_FLAGS, uint64(0x8)}}, true},
+		{[]DynInfo{{DT_FLAGS_1, uint64(DF_1_PIE)}}, false},
+		{[]DynInfo{{DT_FLAGS_1, uint64(DF_1_PIE | 0x1)}}, true}, // -z now
+	}
+	for i, tc := range tests {
+		if got := bindNow(&DepsInfo{dyns: tc.dyns}); got != tc.want {
+			t.Errorf("case %d: got %v, want %v", i, got, tc.want)
+		}
+	}
+}
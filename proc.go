@@ -0,0 +1,267 @@
+/*
+ * ELF tree - Tree viewer for ELF library dependency
+ *
+ * Copyright (C) 2017-2018  Namhyung Kim <namhyung@gmail.com>
+ *
+ * Released under MIT license.
+ */
+package main
+
+import (
+	"bufio"
+	"debug/elf"
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type MappedObj struct {
+	path   string // pathname in the process
+	file   string // pathname to access from here
+	base   uint64
+	soname string
+}
+
+type ProcInfo struct {
+	pid    int
+	exe    string
+	root   string // root directory of the process
+	mapped []MappedObj
+
+	dlopened []MappedObj       // mapped but not predicted
+	moved    map[string]string // predicted but mapped from other path
+	missing  []string          // predicted but not mapped
+}
+
+// optional markers and notes for nodes in TUI
+var (
+	depsMarks map[string]rune
+	depsNotes map[string]string
+)
+
+func readSoname(pathname string) string {
+	f, err := elf.Open(pathname)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+
+	sonames, err := f.DynString(elf.DT_SONAME)
+	if err != nil || len(sonames) == 0 {
+		return ""
+	}
+	return sonames[0]
+}
+
+// paths in /proc/<pid> are seen from our root, convert them to the process root
+func (pi *ProcInfo) inRoot(name string) string {
+	if pi.root == "/" || !strings.HasPrefix(name, pi.root+"/") {
+		return name
+	}
+	return strings.TrimPrefix(name, pi.root)
+}
+
+// returns mapped ELF objects in /proc/<pid>/maps with their load base
+func readProcMaps(pi *ProcInfo) error {
+	f, err := os.Open(fmt.Sprintf("/proc/%d/maps", pi.pid))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	seen := make(map[string]bool)
+
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		// address perms offset dev inode pathname
+		fields := strings.Fields(s.Text())
+		if len(fields) < 6 || !strings.HasPrefix(fields[5], "/") {
+			continue
+		}
+
+		name := strings.Join(fields[5:], " ")
+		name = pi.inRoot(strings.TrimSuffix(name, " (deleted)"))
+
+		if seen[name] || name == pi.exe {
+			continue
+		}
+
+		// the first mapping of an object has the base address
+		addr := strings.Split(fields[0], "-")
+		base, _ := strconv.ParseUint(addr[0], 16, 64)
+		off, _ := strconv.ParseUint(fields[2], 16, 64)
+		if off != 0 {
+			continue
+		}
+
+		file := name
+		if pi.root != "/" {
+			file = path.Join(fmt.Sprintf("/proc/%d/root", pi.pid), name)
+		}
+		if !isDynamicElf(file) {
+			continue
+		}
+		seen[name] = true
+
+		obj := MappedObj{path: name, file: file, base: base, soname: readSoname(file)}
+		pi.mapped = append(pi.mapped, obj)
+	}
+	return s.Err()
+}
+
+func readProcInfo(pid int) (*ProcInfo, error) {
+	pi := &ProcInfo{pid: pid, moved: make(map[string]string)}
+
+	exe, err := os.Readlink(fmt.Sprintf("/proc/%d/exe", pid))
+	if err != nil {
+		return nil, err
+	}
+
+	pi.root, err = os.Readlink(fmt.Sprintf("/proc/%d/root", pid))
+	if err != nil {
+		return nil, err
+	}
+	pi.exe = pi.inRoot(strings.TrimSuffix(exe, " (deleted)"))
+
+	if err := readProcMaps(pi); err != nil {
+		return nil, err
+	}
+	return pi, nil
+}
+
+// compare mapped objects with the predicted dependencies
+func compareProcMaps(pi *ProcInfo, root *DepsNode) {
+	matched := make(map[int]bool)
+
+	var names []string
+	for k := range deps {
+		if k != root.name {
+			names = append(names, k)
+		}
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		found := -1
+		for i, obj := range pi.mapped {
+			if realPath(obj.path) == deps[name].path {
+				found = i
+				break
+			}
+		}
+		if found < 0 {
+			for i, obj := range pi.mapped {
+				if obj.soname == name || path.Base(obj.path) == name {
+					pi.moved[name] = obj.path
+					found = i
+					break
+				}
+			}
+		}
+
+		if found < 0 {
+			pi.missing = append(pi.missing, name)
+		} else {
+			matched[found] = true
+		}
+	}
+
+	for i, obj := range pi.mapped {
+		if !matched[i] {
+			pi.dlopened = append(pi.dlopened, obj)
+		}
+	}
+}
+
+func printProcInfo(pi *ProcInfo) {
+	fmt.Println()
+	fmt.Printf("Process %d: %s\n", pi.pid, pi.exe)
+	if pi.root != "/" {
+		fmt.Printf("  root:  %s\n", pi.root)
+	}
+
+	fmt.Println()
+	fmt.Println("Mapped objects:")
+	for _, obj := range pi.mapped {
+		fmt.Printf("  %#16x  %s\n", obj.base, obj.path)
+	}
+
+	if len(pi.dlopened)+len(pi.moved)+len(pi.missing) == 0 {
+		return
+	}
+
+	fmt.Println()
+	fmt.Println("Discrepancies:")
+	for _, obj := range pi.dlopened {
+		fmt.Printf("  + %s  (dlopen'ed or preloaded)\n", obj.path)
+	}
+	for _, name := range pi.missing {
+		fmt.Printf("  - %s  (not mapped)\n", name)
+	}
+
+	var moved []string
+	for k := range pi.moved {
+		moved = append(moved, k)
+	}
+	sort.Strings(moved)
+	for _, name := range moved {
+		fmt.Printf("  ! %s  => %s  (predicted %s)\n", name, pi.moved[name], deps[name].path)
+	}
+}
+
+func procMain(pid int) {
+	pi, err := readProcInfo(pid)
+	if err != nil {
+		fmt.Printf("elftree: %v\n", err)
+		os.Exit(1)
+	}
+
+	exe := pi.exe
+	if pi.root != "/" {
+		// look up libraries in the root of the process (like -root)
+		sysfs = rootFS{dir: fmt.Sprintf("/proc/%d/root", pid)}
+		conflib = readLdSoConf("/etc/ld.so.conf", nil)
+	} else if _, err := os.Stat(exe); err != nil {
+		exe = fmt.Sprintf("/proc/%d/exe", pid)
+	}
+	root := loadDeps(exe, path.Base(pi.exe))
+
+	compareProcMaps(pi, root)
+
+	depsMarks = make(map[string]rune)
+	depsNotes = make(map[string]string)
+
+	// add dlopen'ed objects as children of the executable
+	for _, obj := range pi.dlopened {
+		dep := &DepsNode{name: obj.path, parent: root, depth: 1}
+		root.child = append(root.child, dep)
+		deps_list = append(deps_list, dep)
+
+		depsMarks[obj.path] = '+'
+		depsNotes[obj.path] = fmt.Sprintf("dlopen'ed at %#x", obj.base)
+	}
+	processDepsList()
+
+	for _, name := range pi.missing {
+		depsMarks[name] = '-'
+		depsNotes[name] = "not mapped"
+	}
+	for name, mapped := range pi.moved {
+		depsMarks[name] = '!'
+		depsNotes[name] = "mapped from " + mapped
+	}
+
+	if showTui {
+		ShowWithTUI(root)
+		return
+	}
+
+	f := openElf(exe)
+	defer f.Close()
+
+	printDepTree(root, f)
+	printProcInfo(pi)
+}
@@ -0,0 +1,76 @@
+/*
+ * ELF tree - Tree viewer for ELF library dependency
+ *
+ * Copyright (C) 2017-2018  Namhyung Kim <namhyung@gmail.com>
+ *
+ * Released under MIT license.
+ */
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestReadProcInfo(t *testing.T) {
+	pi, err := readProcInfo(os.Getpid())
+	if err != nil {
+		t.Skip("no procfs:", err)
+	}
+
+	exe, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pi.exe != realPath(exe) || pi.root != "/" {
+		t.Errorf("exe %s (want %s), root %s", pi.exe, exe, pi.root)
+	}
+	for _, obj := range pi.mapped {
+		if obj.path == pi.exe || !filepath.IsAbs(obj.path) {
+			t.Errorf("bad mapped object: %s", obj.path)
+		}
+	}
+}
+
+func TestCompareProcMaps(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"liba.so.1", "libb.so.1", "libx.so"} {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	other := filepath.Join(dir, "other")
+	os.Mkdir(other, 0755)
+	os.WriteFile(filepath.Join(other, "libb.so.1"), nil, 0644)
+
+	deps = map[string]DepsInfo{
+		"prog":      {path: "/usr/bin/prog"},
+		"liba.so.1": {path: filepath.Join(dir, "liba.so.1")},
+		"libb.so.1": {path: filepath.Join(dir, "libb.so.1")},
+		"libc.so.6": {path: "/nonexistent/libc.so.6"},
+	}
+
+	mapped := func(p string) MappedObj {
+		return MappedObj{path: p, file: p, base: 0x7f0000000000}
+	}
+	pi := &ProcInfo{pid: 1, exe: "/usr/bin/prog", root: "/", moved: make(map[string]string)}
+	pi.mapped = []MappedObj{
+		mapped(filepath.Join(dir, "liba.so.1")),
+		mapped(filepath.Join(other, "libb.so.1")),
+		mapped(filepath.Join(dir, "libx.so")),
+	}
+
+	compareProcMaps(pi, &DepsNode{name: "prog"})
+
+	if want := []string{"libc.so.6"}; !reflect.DeepEqual(pi.missing, want) {
+		t.Errorf("missing: got %v, want %v", pi.missing, want)
+	}
+	if want := map[string]string{"libb.so.1": filepath.Join(other, "libb.so.1")}; !reflect.DeepEqual(pi.moved, want) {
+		t.Errorf("moved: got %v, want %v", pi.moved, want)
+	}
+	if len(pi.dlopened) != 1 || pi.dlopened[0].path != filepath.Join(dir, "libx.so") {
+		t.Errorf("dlopened: got %v", pi.dlopened)
+	}
+}
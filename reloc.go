@@ -0,0 +1,375 @@
+/*
+ * ELF tree - Tree viewer for ELF library dependency
+ *
+ * Copyright (C) 2017-2018  Namhyung Kim <namhyung@gmail.com>
+ *
+ * Released under MIT license.
+ */
+package main
+
+import (
+	"debug/elf"
+	"fmt"
+	"sort"
+)
+
+const SHT_RELR = elf.SectionType(19)
+
+type Reloc struct {
+	off    uint64
+	typ    uint32
+	sym    uint32
+	addend int64
+	rela   bool // has explicit addend
+}
+
+func relocTypeName(mach elf.Machine, typ uint32) string {
+	t := int(typ)
+
+	switch mach {
+	case elf.EM_X86_64:
+		return elf.R_X86_64(t).String()
+	case elf.EM_386:
+		return elf.R_386(t).String()
+	case elf.EM_AARCH64:
+		return elf.R_AARCH64(t).String()
+	case elf.EM_ARM:
+		return elf.R_ARM(t).String()
+	case elf.EM_PPC64:
+		return elf.R_PPC64(t).String()
+	case elf.EM_PPC:
+		return elf.R_PPC(t).String()
+	case elf.EM_RISCV:
+		return elf.R_RISCV(t).String()
+	case elf.EM_S390:
+		return elf.R_390(t).String()
+	case elf.EM_MIPS:
+		return elf.R_MIPS(t).String()
+	case elf.EM_LOONGARCH:
+		return elf.R_LARCH(t).String()
+	case elf.EM_SPARCV9:
+		return elf.R_SPARC(t).String()
+	}
+	return fmt.Sprintf("<%d>", typ)
+}
+
+// type of relative relocations (used by RELR)
+func relativeType(mach elf.Machine) uint32 {
+	switch mach {
+	case elf.EM_X86_64:
+		return uint32(elf.R_X86_64_RELATIVE)
+	case elf.EM_386:
+		return uint32(elf.R_386_RELATIVE)
+	case elf.EM_AARCH64:
+		return uint32(elf.R_AARCH64_RELATIVE)
+	case elf.EM_ARM:
+		return uint32(elf.R_ARM_RELATIVE)
+	case elf.EM_PPC64:
+		return uint32(elf.R_PPC64_RELATIVE)
+	case elf.EM_PPC:
+		return uint32(elf.R_PPC_RELATIVE)
+	case elf.EM_RISCV:
+		return uint32(elf.R_RISCV_RELATIVE)
+	case elf.EM_S390:
+		return uint32(elf.R_390_RELATIVE)
+	case elf.EM_LOONGARCH:
+		return uint32(elf.R_LARCH_RELATIVE)
+	case elf.EM_SPARCV9:
+		return uint32(elf.R_SPARC_RELATIVE)
+	}
+	return 0
+}
+
+func isRelocSection(s *elf.Section) bool {
+	return s.Type == elf.SHT_REL || s.Type == elf.SHT_RELA || s.Type == SHT_RELR
+}
+
+// decode RELR: an address followed by bitmaps of next words to relocate
+func decodeRelr(data []byte, word int, readWord func(int) uint64, typ uint32) []Reloc {
+	var relocs []Reloc
+	var next uint64
+
+	for off := 0; off+word <= len(data); off += word {
+		entry := readWord(off)
+		if entry&1 == 0 {
+			relocs = append(relocs, Reloc{off: entry, typ: typ})
+			next = entry + uint64(word)
+			continue
+		}
+
+		bits := word*8 - 1
+		for i := 0; i < bits; i++ {
+			if (entry>>uint(i+1))&1 != 0 {
+				relocs = append(relocs, Reloc{off: next + uint64(i*word), typ: typ})
+			}
+		}
+		next += uint64(bits * word)
+	}
+	return relocs
+}
+
+// decode relocation entries in the section
+func readRelocs(info *DepsInfo, idx int) ([]Reloc, error) {
+	s := info.sect[idx]
+
+	data, err := readSectionData(info.path, info.sect, idx)
+	if err != nil {
+		return nil, err
+	}
+
+	bo := info.endian
+	is32 := info.bits == elf.ELFCLASS32
+
+	word := 8
+	if is32 {
+		word = 4
+	}
+	readWord := func(off int) uint64 {
+		if is32 {
+			return uint64(bo.Uint32(data[off:]))
+		}
+		return bo.Uint64(data[off:])
+	}
+
+	if s.Type == SHT_RELR {
+		return decodeRelr(data, word, readWord, relativeType(info.mach)), nil
+	}
+
+	size := 2 * word
+	if s.Type == elf.SHT_RELA {
+		size += word
+	}
+
+	var relocs []Reloc
+
+	for off := 0; off+size <= len(data); off += size {
+		r := Reloc{off: readWord(off), rela: s.Type == elf.SHT_RELA}
+
+		rinfo := readWord(off + word)
+		if is32 {
+			r.typ, r.sym = uint32(rinfo&0xff), uint32(rinfo>>8)
+		} else {
+			r.typ, r.sym = uint32(rinfo), uint32(rinfo>>32)
+		}
+
+		if r.rela {
+			if is32 {
+				r.addend = int64(int32(readWord(off + 2*word)))
+			} else {
+				r.addend = int64(readWord(off + 2*word))
+			}
+		}
+		relocs = append(relocs, r)
+	}
+	return relocs, nil
+}
+
+// returns the symbol referenced by the relocation
+func relocSymbol(info *DepsInfo, s *elf.Section, r *Reloc) string {
+	if r.sym == 0 || int(s.Link) >= len(info.sect) {
+		return ""
+	}
+
+	var syms []elf.Symbol
+	switch info.sect[s.Link].Type {
+	case elf.SHT_DYNSYM:
+		syms = info.dsym
+	case elf.SHT_SYMTAB:
+		syms = info.syms
+	}
+
+	// the first (null) symbol is not included
+	if int(r.sym) > len(syms) {
+		return fmt.Sprintf("<sym %d>", r.sym)
+	}
+
+	sym := &syms[r.sym-1]
+	if elf.ST_TYPE(sym.Info) == elf.STT_SECTION && int(sym.Section) < len(info.sect) {
+		return info.sect[sym.Section].Name
+	}
+	return symName(sym.Name)
+}
+
+// returns the section containing the address
+func addrSection(info *DepsInfo, addr uint64) *elf.Section {
+	for _, s := range info.sect {
+		if s.Flags&elf.SHF_ALLOC == 0 || s.Type == elf.SHT_NULL {
+			continue
+		}
+		// .tbss doesn't occupy the address space
+		if s.Flags&elf.SHF_TLS != 0 && s.Type == elf.SHT_NOBITS {
+			continue
+		}
+		if s.Addr <= addr && addr < s.Addr+s.Size {
+			return s
+		}
+	}
+	return nil
+}
+
+// pager shows relocation entries
+type RelocPager struct {
+	info   *DepsInfo
+	sect   *elf.Section
+	target *elf.Section // for relocatable objects
+	relocs []Reloc
+	syms   SymIndex
+	pos    int
+}
+
+func (p *RelocPager) next() []string {
+	var lines []string
+
+	if p.pos == 0 {
+		lines = append(lines, fmt.Sprintf("  %16s  %-24s %-40s %s",
+			"Offset", "Type", "Symbol + Addend", "Section"))
+	}
+
+	for len(lines) < DUMP_LINES && p.pos < len(p.relocs) {
+		r := &p.relocs[p.pos]
+		p.pos++
+
+		sym := relocSymbol(p.info, p.sect, r)
+		if r.rela || r.addend != 0 {
+			if sym == "" {
+				sym = fmt.Sprintf("%#x", r.addend)
+				if s := p.syms.lookup(uint64(r.addend)); s != "" {
+					sym += " <" + s + ">"
+				}
+			} else if r.addend != 0 {
+				sym += fmt.Sprintf(" %+#x", r.addend)
+			}
+		}
+
+		target := p.target
+		if target == nil {
+			target = addrSection(p.info, r.off)
+		}
+		tname := ""
+		if target != nil {
+			tname = target.Name
+		}
+
+		lines = append(lines, fmt.Sprintf("  %16x  %-24s %-40s %s", r.off,
+			relocTypeName(p.info.mach, r.typ), sym, tname))
+	}
+	return lines
+}
+
+func (p *RelocPager) more() string {
+	return fmt.Sprintf("  ... %d of %d entries (press ENTER for more)", p.pos, len(p.relocs))
+}
+
+func (p *RelocPager) done() bool {
+	return p.pos >= len(p.relocs)
+}
+
+func relocSectionLabel(s *elf.Section, n int) string {
+	kind := "REL"
+	switch s.Type {
+	case elf.SHT_RELA:
+		kind = "RELA"
+	case SHT_RELR:
+		kind = "RELR"
+	}
+	return fmt.Sprintf("%s (%s, %d entries)", s.Name, kind, n)
+}
+
+func makeRelocInfo(name string, info *DepsInfo) *FileInfo {
+	root := &TreeItem{node: name}
+
+	if info.endian == nil {
+		AddSubTree("", nil, root)
+		AddSubTree("No relocation info", nil, root)
+		return &FileInfo{Root: root, Top: root, Curr: root}
+	}
+
+	count := make(map[uint32]int)
+	textrel := make(map[string]int)
+	total := 0
+	relative := relativeType(info.mach)
+	dynamic := info.kind != elf.ET_REL
+
+	var tables []*RelocPager
+	for i, s := range info.sect {
+		if !isRelocSection(s) {
+			continue
+		}
+
+		relocs, err := readRelocs(info, i)
+		if err != nil {
+			AddSubTree("", nil, root)
+			AddSubTree(fmt.Sprintf("%s: %v", s.Name, err), nil, root)
+			continue
+		}
+
+		for _, r := range relocs {
+			count[r.typ]++
+			total++
+
+			if !dynamic {
+				continue
+			}
+			// dynamic relocations in read-only sections need TEXTREL
+			if t := addrSection(info, r.off); t != nil && t.Flags&elf.SHF_WRITE == 0 {
+				textrel[t.Name]++
+			}
+		}
+
+		pager := &RelocPager{info: info, sect: s, relocs: relocs, syms: makeSymIndex(name)}
+		if !dynamic && int(s.Info) < len(info.sect) && s.Flags&elf.SHF_INFO_LINK != 0 {
+			pager.target = info.sect[s.Info]
+		}
+
+		tables = append(tables, pager)
+	}
+
+	// summary of relocation types
+	var types []uint32
+	for t := range count {
+		types = append(types, t)
+	}
+	sort.Slice(types, func(i, j int) bool {
+		if count[types[i]] != count[types[j]] {
+			return count[types[i]] > count[types[j]]
+		}
+		return types[i] < types[j]
+	})
+
+	var summary []string
+	for _, t := range types {
+		summary = append(summary, fmt.Sprintf("  %-32s %8d", relocTypeName(info.mach, t), count[t]))
+	}
+	summary = append(summary, fmt.Sprintf("  %-32s %8d", "Total", total))
+	if dynamic && total > 0 {
+		summary = append(summary, fmt.Sprintf("  %-32s %8d (%d%%)", "Relative", count[relative],
+			count[relative]*100/total))
+	}
+
+	var names []string
+	for n := range textrel {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	for _, n := range names {
+		summary = append(summary, fmt.Sprintf("  TEXTREL: %d relocations in read-only %s", textrel[n], n))
+	}
+
+	AddSubTree("", nil, root)
+	AddSubTree("Relocation Summary", summary, root)
+
+	// entries are loaded when expanded
+	for _, pager := range tables {
+		AddSubTree("", nil, root)
+		AddSubTree(relocSectionLabel(pager.sect, len(pager.relocs)), nil, root)
+
+		if len(pager.relocs) > 0 {
+			ti := lastChild(root)
+			ti.folded = true
+			ti.paged = true
+			ti.load = func(ti *TreeItem) { loadPage(ti, pager) }
+		}
+	}
+
+	return &FileInfo{Root: root, Top: root, Curr: root}
+}
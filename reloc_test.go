@@ -0,0 +1,136 @@
+/*
+ * ELF tree - Tree viewer for ELF library dependency
+ *
+ * Copyright (C) 2017-2018  Namhyung Kim <namhyung@gmail.com>
+ *
+ * Released under MIT license.
+ */
+package main
+
+import (
+	"debug/elf"
+	"encoding/binary"
+	"testing"
+)
+
+const FIXTURE = "testdata/libfixture.so.1"
+
+// load the fixture as a root object
+func loadFixture(t *testing.T, pathname string) *DepsInfo {
+	deps = make(map[string]DepsInfo)
+	root := loadDeps(pathname, "fixture")
+
+	info := deps[root.name]
+	if info.endian == nil {
+		t.Fatalf("cannot load %s", pathname)
+	}
+	return &info
+}
+
+func relrOffsets(words []uint64, is32 bool) []uint64 {
+	word := 8
+	if is32 {
+		word = 4
+	}
+
+	data := make([]byte, len(words)*word)
+	for i, w := range words {
+		if is32 {
+			binary.LittleEndian.PutUint32(data[i*word:], uint32(w))
+		} else {
+			binary.LittleEndian.PutUint64(data[i*word:], w)
+		}
+	}
+	readWord := func(off int) uint64 {
+		if is32 {
+			return uint64(binary.LittleEndian.Uint32(data[off:]))
+		}
+		return binary.LittleEndian.Uint64(data[off:])
+	}
+
+	var offs []uint64
+	for _, r := range decodeRelr(data, word, readWord, 8) {
+		offs = append(offs, r.off)
+	}
+	return offs
+}
+
+func equalOffsets(a, b []uint64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestDecodeRelr(t *testing.T) {
+	tests := []struct {
+		words []uint64
+		is32  bool
+		want  []uint64
+	}{
+		{nil, false, nil},
+		// an address only
+		{[]uint64{0x1000}, false, []uint64{0x1000}},
+		// bitmap after an address: bit 1 and 3 are next words 0 and 2
+		{[]uint64{0x1000, 0xb}, false, []uint64{0x1000, 0x1008, 0x1018}},
+		// consecutive bitmaps cover 63 words each
+		{[]uint64{0x1000, 0x3, 0x3}, false, []uint64{0x1000, 0x1008, 0x1008 + 63*8}},
+		// all bits set
+		{[]uint64{0x2000, 1<<64 - 1}, false, append([]uint64{0x2000}, seqOffsets(0x2008, 8, 63)...)},
+		// a new address restarts the sequence
+		{[]uint64{0x1000, 0x3, 0x3000, 0x5}, false, []uint64{0x1000, 0x1008, 0x3000, 0x3010}},
+		// 32-bit bitmaps cover 31 words
+		{[]uint64{0x1000, 0x80000001, 0x3}, true, []uint64{0x1000, 0x1004 + 30*4, 0x1004 + 31*4}},
+	}
+
+	for i, tt := range tests {
+		if got := relrOffsets(tt.words, tt.is32); !equalOffsets(got, tt.want) {
+			t.Errorf("test %d: got %#x, want %#x", i, got, tt.want)
+		}
+	}
+}
+
+func seqOffsets(start, step uint64, n int) []uint64 {
+	var offs []uint64
+	for i := 0; i < n; i++ {
+		offs = append(offs, start+uint64(i)*step)
+	}
+	return offs
+}
+
+func TestReadRelrFixture(t *testing.T) {
+	info := loadFixture(t, FIXTURE)
+
+	idx := -1
+	for i, s := range info.sect {
+		if s.Type == SHT_RELR {
+			idx = i
+		}
+	}
+	if idx < 0 {
+		t.Fatal("no RELR section in the fixture")
+	}
+
+	relocs, err := readRelocs(info, idx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// from readelf -r
+	want := []uint64{0x2000, 0x2020, 0x2028, 0x2038, 0x2040}
+	var got []uint64
+	for _, r := range relocs {
+		if r.typ != uint32(elf.R_X86_64_RELATIVE) {
+			t.Errorf("bad relocation type: %d", r.typ)
+		}
+		got = append(got, r.off)
+	}
+	if !equalOffsets(got, want) {
+		t.Errorf("got %#x, want %#x", got, want)
+	}
+}
@@ -0,0 +1,406 @@
+/*
+ * ELF tree - Tree viewer for ELF library dependency
+ *
+ * Copyright (C) 2017-2018  Namhyung Kim <namhyung@gmail.com>
+ *
+ * Released under MIT license.
+ */
+package main
+
+import (
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+type Matcher struct {
+	pattern string
+	re      *regexp.Regexp // nil for substring match
+}
+
+// search state of each tree view
+type SearchState struct {
+	search Matcher
+	filter Matcher   // hide items not matching
+	orig   *TreeItem // unfiltered tree
+}
+
+// search prompt in the status line
+type Prompt struct {
+	active bool
+	kind   rune // '/' for search, '&' for filter
+	text   string
+	regex  bool
+	tv     *TreeView
+	saved  FileInfo // position before the search
+}
+
+var prompt Prompt
+
+// message shown in the status line until the next key
+var statusMsg string
+
+// set when search (or filter) skipped unloaded pages
+var pagesSkipped bool
+
+func itemText(ti *TreeItem) string {
+	switch node := ti.node.(type) {
+	case *DepsNode:
+		return node.name
+	case string:
+		return node
+	}
+	return ""
+}
+
+func (m *Matcher) compile(pattern string, regex bool) error {
+	m.pattern = pattern
+	m.re = nil
+
+	if regex {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			m.pattern = ""
+			return err
+		}
+		m.re = re
+	}
+	return nil
+}
+
+func (m *Matcher) same(other *Matcher) bool {
+	return m.pattern == other.pattern && (m.re == nil) == (other.re == nil)
+}
+
+func (m *Matcher) match(ti *TreeItem) bool {
+	if m.pattern == "" {
+		return false
+	}
+	s := itemText(ti)
+	if m.re != nil {
+		return m.re.MatchString(s)
+	}
+	return strings.Contains(s, m.pattern)
+}
+
+// load children to search, except pages which can be huge (e.g. disassembly)
+func (ti *TreeItem) loadToSearch() {
+	if ti.paged && ti.load != nil {
+		pagesSkipped = true
+		return
+	}
+	ti.loadChildren()
+}
+
+func notFound(pattern string) string {
+	if pagesSkipped {
+		return "Pattern not found: " + pattern + " (unloaded pages are not searched)"
+	}
+	return "Pattern not found: " + pattern
+}
+
+// next item in pre-order regardless of folding
+func (ti *TreeItem) walkNext() *TreeItem {
+	ti.loadToSearch()
+	if ti.child != nil {
+		return ti.child
+	}
+	for ti != nil {
+		if ti.next != nil {
+			return ti.next
+		}
+		ti = ti.parent
+	}
+	return nil
+}
+
+// previous item in pre-order regardless of folding
+func (ti *TreeItem) walkPrev() *TreeItem {
+	if ti.prev == nil {
+		return ti.parent
+	}
+
+	ti = ti.prev
+	for ti.loadToSearch(); ti.child != nil; ti.loadToSearch() {
+		ti = ti.child
+		for ti.next != nil {
+			ti = ti.next
+		}
+	}
+	return ti
+}
+
+func (ti *TreeItem) lastItem() *TreeItem {
+	for ti.loadToSearch(); ti.child != nil; ti.loadToSearch() {
+		ti = ti.child
+		for ti.next != nil {
+			ti = ti.next
+		}
+	}
+	return ti
+}
+
+// unfold parents to show the item
+func (ti *TreeItem) reveal() {
+	var parents []*TreeItem
+	for p := ti.parent; p != nil; p = p.parent {
+		parents = append(parents, p)
+	}
+
+	// expand from the top so that totals are updated correctly
+	for i := len(parents) - 1; i >= 0; i-- {
+		parents[i].expand()
+	}
+}
+
+// move cursor to the (visible) item
+func (tv *TreeView) moveTo(target *TreeItem) {
+	idx := 0
+	for ti := tv.Root; ti != nil && ti != target; ti = ti.nextItem() {
+		idx++
+	}
+
+	tv.idx = idx
+	tv.Curr = target
+
+	if idx < tv.off || idx >= tv.off+tv.rows {
+		// put it in the middle of the window
+		tv.off = idx - tv.rows/2
+		if tv.off < 0 {
+			tv.off = 0
+		}
+	}
+
+	tv.Top = tv.Root
+	for i := 0; i < tv.off; i++ {
+		tv.Top = tv.Top.nextItem()
+	}
+}
+
+// find next (or previous) match from the item and move to it
+func (tv *TreeView) searchFrom(start *TreeItem, forward bool) bool {
+	m := &tv.search.search
+	if m.pattern == "" || start == nil {
+		return false
+	}
+
+	ti := start
+	for {
+		if m.match(ti) {
+			ti.reveal()
+			tv.moveTo(ti)
+			return true
+		}
+
+		if forward {
+			ti = ti.walkNext()
+			if ti == nil {
+				ti = tv.Root // wrap around
+			}
+		} else {
+			ti = ti.walkPrev()
+			if ti == nil {
+				ti = tv.Root.lastItem()
+			}
+		}
+
+		if ti == start {
+			return false
+		}
+	}
+}
+
+func (tv *TreeView) searchNext(forward bool) {
+	pattern := tv.search.search.pattern
+	if pattern == "" {
+		statusMsg = "No search pattern"
+		return
+	}
+
+	pagesSkipped = false
+
+	var start *TreeItem
+	if forward {
+		start = tv.Curr.walkNext()
+		if start == nil {
+			start = tv.Root
+		}
+	} else {
+		start = tv.Curr.walkPrev()
+		if start == nil {
+			start = tv.Root.lastItem()
+		}
+	}
+	if !tv.searchFrom(start, forward) {
+		statusMsg = notFound(pattern)
+	}
+}
+
+// copy items matching the pattern with their parents (expanded)
+func copyMatching(m *Matcher, ti, parent *TreeItem) *TreeItem {
+	item := &TreeItem{node: ti.node, parent: parent}
+
+	// lazy items might have matching children
+	ti.loadToSearch()
+
+	var prev *TreeItem
+	for c := ti.child; c != nil; c = c.next {
+		n := copyMatching(m, c, item)
+		if n == nil {
+			continue
+		}
+
+		if item.child == nil {
+			item.child = n
+		}
+		if prev != nil {
+			prev.next = n
+			n.prev = prev
+		}
+		prev = n
+
+		item.total += n.total + 1
+	}
+
+	if item.child == nil && !m.match(ti) {
+		return nil
+	}
+	return item
+}
+
+// hide items not matching the pattern
+func (tv *TreeView) applyFilter() {
+	ss := &tv.search
+	if ss.orig == nil {
+		ss.orig = tv.Root
+	}
+
+	pagesSkipped = false
+	root := copyMatching(&ss.filter, ss.orig, nil)
+	if root == nil {
+		root = &TreeItem{node: ss.orig.node}
+	}
+
+	tv.Root = root
+	tv.Top = root
+	tv.Curr = root
+	tv.idx = 0
+	tv.off = 0
+}
+
+// show all items again, returns whether it was filtered
+func (tv *TreeView) clearFilter() bool {
+	ss := &tv.search
+	if ss.orig == nil {
+		return false
+	}
+
+	tv.Root = ss.orig
+	tv.Top = ss.orig
+	tv.Curr = ss.orig
+	tv.idx = 0
+	tv.off = 0
+
+	ss.orig = nil
+	return true
+}
+
+func (p *Prompt) String() string {
+	s := string(p.kind)
+	if p.regex {
+		s += "(regex) "
+	}
+	return s + p.text
+}
+
+func (p *Prompt) start(kind rune, tv *TreeView) {
+	p.active = true
+	p.kind = kind
+	p.text = ""
+	p.regex = tv.search.search.re != nil
+	p.tv = tv
+	p.saved = FileInfo{Root: tv.Root, Top: tv.Top, Curr: tv.Curr,
+		idx: tv.idx, off: tv.off, pos: tv.pos}
+}
+
+// returns key name for the prompt
+func promptKey(path string) string {
+	key := strings.TrimPrefix(path, "/sys/kbd/")
+	switch key {
+	case "<space>":
+		return " "
+	case "C-8":
+		return "<backspace>"
+	}
+	return key
+}
+
+// handle a key in the prompt, update the view for incremental search
+func (p *Prompt) input(key string) {
+	tv := p.tv
+
+	switch key {
+	case "<escape>", "C-g":
+		p.active = false
+		if p.kind == '/' {
+			// go back to the original position
+			tv.off = p.saved.off
+			tv.moveTo(p.saved.Curr)
+		}
+		return
+	case "<enter>":
+		p.active = false
+		p.accept(tv)
+		return
+	case "<backspace>":
+		_, size := utf8.DecodeLastRuneInString(p.text)
+		p.text = p.text[:len(p.text)-size]
+	case "C-r":
+		p.regex = !p.regex
+	default:
+		if utf8.RuneCountInString(key) != 1 {
+			return // ignore special keys
+		}
+		p.text += key
+	}
+
+	if p.kind != '/' {
+		return
+	}
+
+	// incremental search from the original position
+	if err := tv.search.search.compile(p.text, p.regex); err != nil {
+		return // incomplete regex
+	}
+	pagesSkipped = false
+	if !tv.searchFrom(p.saved.Curr, true) && p.text != "" {
+		statusMsg = notFound(p.text)
+	}
+}
+
+func (p *Prompt) accept(tv *TreeView) {
+	m := &tv.search.search
+	if p.kind == '&' {
+		m = &tv.search.filter
+	}
+
+	if err := m.compile(p.text, p.regex); err != nil {
+		statusMsg = "Invalid regex: " + err.Error()
+		return
+	}
+
+	if p.kind == '&' {
+		tv.clearFilter()
+		if p.text != "" {
+			tv.applyFilter()
+			if pagesSkipped {
+				statusMsg = "Unloaded pages are not filtered"
+			}
+		}
+		return
+	}
+
+	if p.text != "" && !m.match(tv.Curr) {
+		statusMsg = notFound(p.text)
+	}
+}
@@ -0,0 +1,167 @@
+/*
+ * ELF tree - Tree viewer for ELF library dependency
+ *
+ * Copyright (C) 2017-2018  Namhyung Kim <namhyung@gmail.com>
+ *
+ * Released under MIT license.
+ */
+package main
+
+import (
+	"testing"
+)
+
+// a tree with a normal subtree and a lazy one
+func makeSearchTree(loaded *int) *TreeItem {
+	root := &TreeItem{node: "root"}
+
+	AddSubTree("Normal", []string{"  foo", "  bar"}, root)
+	AddSubTree("Lazy", nil, root)
+
+	ti := lastChild(root)
+	ti.folded = true
+	ti.load = func(ti *TreeItem) {
+		*loaded++
+		appendChild(ti, "  baz")
+		appendChild(ti, "  foo2")
+	}
+	return root
+}
+
+// number of items shown under the item
+func countShown(ti *TreeItem) int {
+	n := 0
+	for c := ti.nextItem(); c != nil; c = c.nextItem() {
+		n++
+	}
+	return n
+}
+
+func itemNames(ti *TreeItem) []string {
+	var names []string
+	for ; ti != nil; ti = ti.walkNext() {
+		names = append(names, itemText(ti))
+	}
+	return names
+}
+
+func TestFilterLazyItems(t *testing.T) {
+	loaded := 0
+	tv := &TreeView{Root: makeSearchTree(&loaded)}
+	tv.search.filter.compile("foo", false)
+	tv.applyFilter()
+
+	want := []string{"root", "Normal", "  foo", "Lazy", "  foo2"}
+	got := itemNames(tv.Root)
+	if len(got) != len(want) {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			t.Fatalf("got %q, want %q", got, want)
+		}
+	}
+
+	// matching items are shown
+	if n := countShown(tv.Root); n != len(want)-1 || tv.Root.total != n {
+		t.Errorf("shown %d items, total %d", n, tv.Root.total)
+	}
+
+	// the original tree is loaded only once
+	tv.clearFilter()
+	tv.applyFilter()
+	if loaded != 1 {
+		t.Errorf("loaded %d times", loaded)
+	}
+}
+
+func TestSearchLazyItems(t *testing.T) {
+	loaded := 0
+	tv := &TreeView{Root: makeSearchTree(&loaded), rows: 10}
+	tv.Top, tv.Curr = tv.Root, tv.Root
+
+	tv.search.search.compile("baz", false)
+	if !tv.searchFrom(tv.Root, true) {
+		t.Fatal("not found in the lazy item")
+	}
+	if itemText(tv.Curr) != "  baz" || tv.Curr.parent.folded {
+		t.Errorf("bad match: %q (folded: %v)", itemText(tv.Curr), tv.Curr.parent.folded)
+	}
+
+	// root, Normal, foo, bar, Lazy and baz
+	if tv.idx != 5 {
+		t.Errorf("cursor at %d, want 5", tv.idx)
+	}
+	if n := countShown(tv.Root); tv.Root.total != n {
+		t.Errorf("total %d, shown %d", tv.Root.total, n)
+	}
+
+	// backward search wraps around
+	tv.search.search.compile("bar", false)
+	if !tv.searchFrom(tv.Curr, false) || itemText(tv.Curr) != "  bar" {
+		t.Errorf("bad match: %q", itemText(tv.Curr))
+	}
+	if loaded != 1 {
+		t.Errorf("loaded %d times", loaded)
+	}
+}
+
+func TestInsertAfterFolded(t *testing.T) {
+	root := &TreeItem{node: "root"}
+	AddSubTree("Items", []string{"  a"}, root)
+
+	ti := lastChild(root)
+	ti.fold()
+
+	total := root.total
+	insertAfter(ti.child, []string{"  b", "  c"})
+	if root.total != total {
+		t.Errorf("total of root changed from %d to %d", total, root.total)
+	}
+
+	ti.expand()
+	if n := countShown(root); root.total != n || n != 4 {
+		t.Errorf("total %d, shown %d", root.total, n)
+	}
+}
+
+// pages like hex dump are not loaded by search
+func TestSearchSkipsPages(t *testing.T) {
+	loaded := 0
+	root := makeSearchTree(&loaded)
+
+	AddSubTree("Hex Dump", nil, root)
+	ti := lastChild(root)
+	ti.folded = true
+	ti.paged = true
+	ti.load = func(ti *TreeItem) {
+		loaded++
+		appendChild(ti, "  00001000  hidden")
+	}
+
+	tv := &TreeView{Root: root, Top: root, Curr: root, rows: 10}
+	tv.search.search.compile("hidden", false)
+	tv.searchNext(true)
+
+	if loaded != 1 || ti.child != nil {
+		t.Errorf("pages loaded (loaded: %d)", loaded)
+	}
+	if statusMsg != "Pattern not found: hidden (unloaded pages are not searched)" {
+		t.Errorf("status: %q", statusMsg)
+	}
+
+	tv.search.filter.compile("hidden", false)
+	tv.applyFilter()
+	if !pagesSkipped || ti.child != nil {
+		t.Errorf("pages filtered (skipped: %v)", pagesSkipped)
+	}
+
+	// search the page once it's loaded
+	tv.clearFilter()
+	ti.loadChildren()
+	tv.searchNext(true)
+	if itemText(tv.Curr) != "  00001000  hidden" {
+		t.Errorf("bad match: %q", itemText(tv.Curr))
+	}
+	statusMsg = ""
+}
@@ -0,0 +1,121 @@
+/*
+ * ELF tree - Tree viewer for ELF library dependency
+ *
+ * Copyright (C) 2017-2018  Namhyung Kim <namhyung@gmail.com>
+ *
+ * Released under MIT license.
+ */
+package main
+
+import (
+	"bytes"
+	"debug/elf"
+	"regexp"
+	"strings"
+)
+
+const DF_1_PIE = 0x8000000
+
+// returns the program interpreter (dynamic linker) if any
+func readInterp(f *elf.File) string {
+	for _, p := range f.Progs {
+		if p.Type != elf.PT_INTERP {
+			continue
+		}
+
+		data := make([]byte, p.Filesz)
+		if _, err := p.ReadAt(data, 0); err != nil {
+			return ""
+		}
+		return strings.TrimRight(string(data), "\x00")
+	}
+	return ""
+}
+
+// returns "dynamic" or "static-pie" for a file with .dynamic section
+func linkType(f *elf.File, info *DepsInfo) string {
+	if info.interp != "" || f.Type != elf.ET_DYN {
+		return "dynamic"
+	}
+
+	needed, soname := false, false
+	for _, d := range info.dyns {
+		switch d.tag {
+		case elf.DT_NEEDED:
+			needed = true
+		case elf.DT_SONAME:
+			soname = true
+		case DT_FLAGS_1:
+			if d.val.(uint64)&DF_1_PIE != 0 {
+				return "static-pie"
+			}
+		}
+	}
+
+	// old linkers don't set DF_1_PIE: shared libraries have a SONAME usually
+	if !needed && !soname && f.Entry != 0 {
+		return "static-pie"
+	}
+	return "dynamic"
+}
+
+var glibcVersion = regexp.MustCompile(`GNU C Library [^\n]*release version ([0-9.]+)`)
+
+// guess C library linked into a static binary
+func detectLibc(f *elf.File, syms []elf.Symbol) string {
+	has := make(map[string]bool)
+	for _, s := range syms {
+		has[s.Name] = true
+	}
+
+	switch {
+	case has["runtime.main"] || f.Section(".go.buildinfo") != nil:
+		if has["__libc_start_main"] {
+			break // cgo binary links a libc too
+		}
+		return "none (Go runtime)"
+	case has["__uClibc_main"]:
+		return "uClibc"
+	case has["__libc_init"] && has["__libc_preinit"]:
+		return "bionic"
+	case has["__init_libc"] || has["__libc_start_init"]:
+		return "musl"
+	}
+
+	// check strings embedded in read-only data
+	var data []byte
+	for _, name := range []string{".rodata", ".rodata.str1.1", ".rodata.str1.8"} {
+		if s := f.Section(name); s != nil && s.Type != elf.SHT_NOBITS {
+			if d, err := s.Data(); err == nil {
+				data = append(data, d...)
+			}
+		}
+	}
+
+	if m := glibcVersion.FindSubmatch(data); m != nil {
+		return "glibc " + string(m[1])
+	}
+	switch {
+	case bytes.Contains(data, []byte("GNU C Library")) || has["__libc_setup_tls"]:
+		return "glibc"
+	case bytes.Contains(data, []byte("uClibc")):
+		return "uClibc"
+	case bytes.Contains(data, []byte("No error information")):
+		// musl's strerror() message for unknown errors
+		return "musl"
+	case has["__libc_start_main"]:
+		return "glibc"
+	}
+	return "unknown"
+}
+
+// check whether the file is an executable or shared library regardless of linkage
+func isElfBinary(pathname string) bool {
+	f, err := sysfs.OpenElf(pathname)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+
+	return f.Type == elf.ET_EXEC || f.Type == elf.ET_DYN
+}
@@ -0,0 +1,72 @@
+/*
+ * ELF tree - Tree viewer for ELF library dependency
+ *
+ * Copyright (C) 2017-2018  Namhyung Kim <namhyung@gmail.com>
+ *
+ * Released under MIT license.
+ */
+package main
+
+import (
+	"debug/elf"
+	"testing"
+)
+
+func TestLinkType(t *testing.T) {
+	tests := []struct {
+		desc   string
+		typ    elf.Type
+		entry  uint64
+		interp string
+		dyns   []DynInfo
+		want   string
+	}{
+		{"PIE", elf.ET_DYN, 0x1040, "/lib64/ld-linux-x86-64.so.2",
+			[]DynInfo{{DT_FLAGS_1, uint64(DF_1_PIE)}}, "dynamic"},
+		{"executable", elf.ET_EXEC, 0x401040, "/lib64/ld-linux-x86-64.so.2", nil, "dynamic"},
+		{"static-pie", elf.ET_DYN, 0x1040, "",
+			[]DynInfo{{DT_FLAGS_1, uint64(DF_1_PIE | 0x1)}}, "static-pie"},
+		{"static-pie by old linker", elf.ET_DYN, 0x1040, "",
+			[]DynInfo{{elf.DT_RELA, uint64(0x400)}}, "static-pie"},
+		{"library", elf.ET_DYN, 0x1040, "",
+			[]DynInfo{{elf.DT_NEEDED, "libc.so.6"}, {elf.DT_SONAME, "libfoo.so.1"}}, "dynamic"},
+		{"library without SONAME", elf.ET_DYN, 0x1040, "",
+			[]DynInfo{{elf.DT_NEEDED, "libc.so.6"}}, "dynamic"},
+		{"library without entry", elf.ET_DYN, 0, "", nil, "dynamic"},
+	}
+
+	for _, tt := range tests {
+		f := &elf.File{FileHeader: elf.FileHeader{Type: tt.typ, Entry: tt.entry}}
+		info := &DepsInfo{interp: tt.interp, dyns: tt.dyns}
+
+		if got := linkType(f, info); got != tt.want {
+			t.Errorf("%s: got %s, want %s", tt.desc, got, tt.want)
+		}
+	}
+}
+
+func TestDetectLibc(t *testing.T) {
+	tests := []struct {
+		syms []string
+		want string
+	}{
+		{[]string{"runtime.main", "main.main"}, "none (Go runtime)"},
+		{[]string{"runtime.main", "__libc_start_main"}, "glibc"}, // cgo
+		{[]string{"__uClibc_main"}, "uClibc"},
+		{[]string{"__libc_init", "__libc_preinit"}, "bionic"},
+		{[]string{"__libc_start_main", "__init_libc"}, "musl"},
+		{[]string{"__libc_start_main", "__libc_setup_tls"}, "glibc"},
+		{[]string{"main"}, "unknown"},
+	}
+
+	for _, tt := range tests {
+		var syms []elf.Symbol
+		for _, s := range tt.syms {
+			syms = append(syms, elf.Symbol{Name: s})
+		}
+
+		if got := detectLibc(&elf.File{}, syms); got != tt.want {
+			t.Errorf("%v: got %s, want %s", tt.syms, got, tt.want)
+		}
+	}
+}
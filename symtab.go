@@ -0,0 +1,271 @@
+/*
+ * ELF tree - Tree viewer for ELF library dependency
+ *
+ * Copyright (C) 2017-2018  Namhyung Kim <namhyung@gmail.com>
+ *
+ * Released under MIT license.
+ */
+package main
+
+import (
+	"debug/elf"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// columns in the symbol table
+const (
+	SYM_COL_VALUE = iota
+	SYM_COL_SIZE
+	SYM_COL_TYPE
+	SYM_COL_BIND
+	SYM_COL_VIS
+	SYM_COL_NDX
+	SYM_COL_VERSION
+	SYM_COL_NAME
+	SYM_COL_MAX
+)
+
+var symColNames = []string{"Value", "Size", "Type", "Bind", "Vis", "Ndx", "Version", "Name"}
+
+// filter values (empty string matches all)
+var (
+	symTypeFilters = []string{"", "FUNC", "OBJECT", "TLS"}
+	symBindFilters = []string{"", "GLOBAL", "WEAK", "LOCAL"}
+	symDefFilters  = []string{"", "defined", "undefined"}
+)
+
+// current sort and filter setting of the symbol view
+var (
+	symSortCol = -1 // file order
+	symSortRev bool
+	symTypeIdx int
+	symBindIdx int
+	symDefIdx  int
+)
+
+func symTypeName(sym *elf.Symbol) string {
+	switch t := elf.ST_TYPE(sym.Info); t {
+	case elf.STT_NOTYPE:
+		return "NOTYPE"
+	case elf.STT_OBJECT:
+		return "OBJECT"
+	case elf.STT_FUNC:
+		return "FUNC"
+	case elf.STT_SECTION:
+		return "SECTION"
+	case elf.STT_FILE:
+		return "FILE"
+	case elf.STT_COMMON:
+		return "COMMON"
+	case elf.STT_TLS:
+		return "TLS"
+	case elf.STT_LOOS:
+		return "IFUNC" // STT_GNU_IFUNC
+	default:
+		return fmt.Sprintf("<%d>", int(t))
+	}
+}
+
+func symBindName(sym *elf.Symbol) string {
+	switch b := elf.ST_BIND(sym.Info); b {
+	case elf.STB_LOCAL:
+		return "LOCAL"
+	case elf.STB_GLOBAL:
+		return "GLOBAL"
+	case elf.STB_WEAK:
+		return "WEAK"
+	case elf.STB_LOOS:
+		return "UNIQUE" // STB_GNU_UNIQUE
+	default:
+		return fmt.Sprintf("<%d>", int(b))
+	}
+}
+
+func symVisName(sym *elf.Symbol) string {
+	switch elf.ST_VISIBILITY(sym.Other) {
+	case elf.STV_DEFAULT:
+		return "DEFAULT"
+	case elf.STV_INTERNAL:
+		return "INTERNAL"
+	case elf.STV_HIDDEN:
+		return "HIDDEN"
+	default:
+		return "PROTECTED"
+	}
+}
+
+func symNdxName(sym *elf.Symbol) string {
+	switch sym.Section {
+	case elf.SHN_UNDEF:
+		return "UND"
+	case elf.SHN_ABS:
+		return "ABS"
+	case elf.SHN_COMMON:
+		return "COM"
+	}
+	return fmt.Sprintf("%d", int(sym.Section))
+}
+
+// "@" for references and "@@" for definitions like readelf
+func symVersion(sym *elf.Symbol) string {
+	if sym.Version == "" {
+		return ""
+	}
+	if sym.Section == elf.SHN_UNDEF {
+		return "@" + sym.Version
+	}
+	return "@@" + sym.Version
+}
+
+func symColumn(sym *elf.Symbol, col int) string {
+	switch col {
+	case SYM_COL_TYPE:
+		return symTypeName(sym)
+	case SYM_COL_BIND:
+		return symBindName(sym)
+	case SYM_COL_VIS:
+		return symVisName(sym)
+	case SYM_COL_VERSION:
+		return symVersion(sym)
+	case SYM_COL_NAME:
+		return symName(sym.Name)
+	}
+	return ""
+}
+
+func symLess(a, b *elf.Symbol, col int) bool {
+	switch col {
+	case SYM_COL_VALUE:
+		return a.Value < b.Value
+	case SYM_COL_SIZE:
+		return a.Size < b.Size
+	case SYM_COL_NDX:
+		return a.Section < b.Section
+	}
+	return symColumn(a, col) < symColumn(b, col)
+}
+
+func symFiltered(sym *elf.Symbol) bool {
+	if t := symTypeFilters[symTypeIdx]; t != "" && symTypeName(sym) != t {
+		return true
+	}
+	if b := symBindFilters[symBindIdx]; b != "" && symBindName(sym) != b {
+		return true
+	}
+	switch symDefFilters[symDefIdx] {
+	case "defined":
+		return sym.Section == elf.SHN_UNDEF
+	case "undefined":
+		return sym.Section != elf.SHN_UNDEF
+	}
+	return false
+}
+
+func makeSymbolHeader() string {
+	var cols []string
+	for i, c := range symColNames {
+		if i == symSortCol {
+			if symSortRev {
+				c += "-"
+			} else {
+				c += "+"
+			}
+		}
+		cols = append(cols, c)
+	}
+	return fmt.Sprintf("  %16s %6s %-7s %-6s %-9s %4s  %-20s %s",
+		cols[0], cols[1], cols[2], cols[3], cols[4], cols[5], cols[6], cols[7])
+}
+
+func makeSymbolString(sym *elf.Symbol) string {
+	return fmt.Sprintf("  %16x %6d %-7s %-6s %-9s %4s  %-20s %s",
+		sym.Value, sym.Size, symTypeName(sym), symBindName(sym),
+		symVisName(sym), symNdxName(sym), symVersion(sym), symName(sym.Name))
+}
+
+// returns the table rows with a summary of the symbols (and symbols in the rows)
+func makeSymbolTable(title string, syms []elf.Symbol) (string, []string, []*elf.Symbol) {
+	var shown []*elf.Symbol
+	var funcs, objs, tls, undef int
+
+	for i := range syms {
+		sym := &syms[i]
+
+		switch elf.ST_TYPE(sym.Info) {
+		case elf.STT_FUNC, elf.STT_LOOS:
+			funcs++
+		case elf.STT_OBJECT, elf.STT_COMMON:
+			objs++
+		case elf.STT_TLS:
+			tls++
+		}
+		if sym.Section == elf.SHN_UNDEF {
+			undef++
+		}
+
+		if !symFiltered(sym) {
+			shown = append(shown, sym)
+		}
+	}
+
+	if symSortCol >= 0 {
+		sort.SliceStable(shown, func(i, j int) bool {
+			if symSortRev {
+				return symLess(shown[j], shown[i], symSortCol)
+			}
+			return symLess(shown[i], shown[j], symSortCol)
+		})
+	}
+
+	rows := []string{makeSymbolHeader()}
+	for _, sym := range shown {
+		rows = append(rows, makeSymbolString(sym))
+	}
+
+	head := fmt.Sprintf("%s (%d", title, len(syms))
+	if len(shown) != len(syms) {
+		head += fmt.Sprintf(", %d shown", len(shown))
+	}
+	head += fmt.Sprintf(": %d func, %d object, %d tls, %d undefined)",
+		funcs, objs, tls, undef)
+	return head, rows, shown
+}
+
+// description of current sort and filter setting
+func symSettingString() string {
+	var s []string
+	if symSortCol >= 0 {
+		order := "ascending"
+		if symSortRev {
+			order = "descending"
+		}
+		s = append(s, fmt.Sprintf("sort: %s (%s)", symColNames[symSortCol], order))
+	}
+	if t := symTypeFilters[symTypeIdx]; t != "" {
+		s = append(s, "type: "+t)
+	}
+	if b := symBindFilters[symBindIdx]; b != "" {
+		s = append(s, "bind: "+b)
+	}
+	if d := symDefFilters[symDefIdx]; d != "" {
+		s = append(s, d)
+	}
+	return strings.Join(s, ", ")
+}
+
+// sort by the column, or reverse the order if it's already sorted by it
+func sortSymbols(col int) {
+	if col < 0 || col >= SYM_COL_MAX {
+		symSortCol = -1
+		symSortRev = false
+		return
+	}
+	if symSortCol == col {
+		symSortRev = !symSortRev
+	} else {
+		symSortCol = col
+		symSortRev = false
+	}
+}
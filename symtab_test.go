@@ -0,0 +1,117 @@
+/*
+ * ELF tree - Tree viewer for ELF library dependency
+ *
+ * Copyright (C) 2017-2018  Namhyung Kim <namhyung@gmail.com>
+ *
+ * Released under MIT license.
+ */
+package main
+
+import (
+	"debug/elf"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var testSymbols = []elf.Symbol{
+	{Name: "main", Info: elf.ST_INFO(elf.STB_GLOBAL, elf.STT_FUNC), Section: 14, Value: 0x1140, Size: 42},
+	{Name: "puts", Info: elf.ST_INFO(elf.STB_GLOBAL, elf.STT_FUNC), Section: elf.SHN_UNDEF, Version: "GLIBC_2.2.5"},
+	{Name: "counter", Info: elf.ST_INFO(elf.STB_LOCAL, elf.STT_OBJECT), Section: 25, Value: 0x4010, Size: 4},
+	{Name: "errno", Info: elf.ST_INFO(elf.STB_GLOBAL, elf.STT_TLS), Section: elf.SHN_UNDEF},
+	{Name: "__gmon_start__", Info: elf.ST_INFO(elf.STB_WEAK, elf.STT_NOTYPE), Section: elf.SHN_UNDEF},
+	{Name: "helper", Info: elf.ST_INFO(elf.STB_LOCAL, elf.STT_FUNC), Section: 14, Value: 0x1120, Size: 16},
+}
+
+func resetSymbolView() {
+	symSortCol = -1
+	symSortRev = false
+	symTypeIdx = 0
+	symBindIdx = 0
+	symDefIdx = 0
+}
+
+// symbol names in the table rows (without the header)
+func symbolNames(rows []string) []string {
+	var names []string
+	for _, r := range rows[1:] {
+		f := strings.Fields(r)
+		names = append(names, f[len(f)-1])
+	}
+	return names
+}
+
+func TestSymbolTable(t *testing.T) {
+	resetSymbolView()
+	defer resetSymbolView()
+
+	head, rows, _ := makeSymbolTable("Symbols", testSymbols)
+	if head != "Symbols (6: 3 func, 1 object, 1 tls, 3 undefined)" {
+		t.Errorf("head: got %q", head)
+	}
+	want := []string{"main", "puts", "counter", "errno", "__gmon_start__", "helper"}
+	if got := symbolNames(rows); !reflect.DeepEqual(got, want) {
+		t.Errorf("file order: got %v", got)
+	}
+	if !strings.Contains(rows[2], "@GLIBC_2.2.5") || !strings.Contains(rows[2], " UND ") {
+		t.Errorf("undefined symbol: got %q", rows[2])
+	}
+
+	// functions defined in the file, by address
+	symTypeIdx = 1
+	symDefIdx = 1
+	sortSymbols(SYM_COL_VALUE)
+
+	head, rows, shown := makeSymbolTable("Symbols", testSymbols)
+	if !strings.HasPrefix(head, "Symbols (6, 2 shown:") {
+		t.Errorf("head: got %q", head)
+	}
+	if got := symbolNames(rows); !reflect.DeepEqual(got, []string{"helper", "main"}) {
+		t.Errorf("sorted by value: got %v", got)
+	}
+	if len(shown) != 2 || shown[0] != &testSymbols[5] {
+		t.Errorf("shown symbols: got %v", shown)
+	}
+	if !strings.Contains(rows[0], "Value+") {
+		t.Errorf("header: got %q", rows[0])
+	}
+	if s := symSettingString(); s != "sort: Value (ascending), type: FUNC, defined" {
+		t.Errorf("setting: got %q", s)
+	}
+}
+
+func TestSortSymbols(t *testing.T) {
+	resetSymbolView()
+	defer resetSymbolView()
+
+	sortSymbols(SYM_COL_NAME)
+	_, rows, _ := makeSymbolTable("Symbols", testSymbols)
+	want := []string{"__gmon_start__", "counter", "errno", "helper", "main", "puts"}
+	if got := symbolNames(rows); !reflect.DeepEqual(got, want) {
+		t.Errorf("ascending: got %v", got)
+	}
+
+	// same column again reverses the order
+	sortSymbols(SYM_COL_NAME)
+	_, rows, _ = makeSymbolTable("Symbols", testSymbols)
+	want = []string{"puts", "main", "helper", "errno", "counter", "__gmon_start__"}
+	if got := symbolNames(rows); !reflect.DeepEqual(got, want) {
+		t.Errorf("descending: got %v", got)
+	}
+	if s := symSettingString(); s != "sort: Name (descending)" {
+		t.Errorf("setting: got %q", s)
+	}
+
+	// weak symbols by size (stable for the same size)
+	sortSymbols(SYM_COL_SIZE)
+	symBindIdx = 2
+	_, rows, _ = makeSymbolTable("Symbols", testSymbols)
+	if got := symbolNames(rows); !reflect.DeepEqual(got, []string{"__gmon_start__"}) {
+		t.Errorf("weak: got %v", got)
+	}
+
+	sortSymbols(-1)
+	if symSortCol != -1 || symSortRev {
+		t.Errorf("got col %d rev %v after reset", symSortCol, symSortRev)
+	}
+}
@@ -0,0 +1,17 @@
+/*
+ * test fixture for elftree, built with:
+ *
+ *   gcc -shared -fPIC -O2 -nostdlib -s -Wl,-soname,libfixture.so.1 \
+ *       -Wl,-z,pack-relative-relocs -Wl,-z,noseparate-code -Wl,-z,max-page-size=4096 \
+ *       -Wl,--build-id=none -Wl,--hash-style=both -o libfixture.so.1 fixture.c
+ *
+ * libfixture-sysv.so.1 is the same but with -Wl,--hash-style=sysv
+ */
+static int a, b, c, d;
+int *ptrs[] = { &a, &b, 0, &c, &d };
+int *far_ptr = &a;
+
+int foo(void) { return a; }
+int bar(void) { return b; }
+int baz(void) { return c + d; }
+int printf_wrapper(int x) { return x * 2; }
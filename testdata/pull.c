@@ -0,0 +1,23 @@
+/*
+ * test archive for elftree, built with:
+ *
+ *   for m in a b c; do
+ *     gcc -c -O2 -DMEMBER_$m -o $m.o pull.c
+ *   done
+ *   ar rcs libpull.a a.o b.o c.o
+ */
+#ifdef MEMBER_a
+int b_func(int);
+int a_func(int x) { return b_func(x) + 1; }
+#endif
+
+#ifdef MEMBER_b
+int c_func(int);
+int puts(const char *);
+int b_func(int x) { puts("b"); return c_func(x) * 2; }
+#endif
+
+#ifdef MEMBER_c
+int c_data = 42;
+int c_func(int x) { return x + c_data; }
+#endif
@@ -0,0 +1,23 @@
+/*
+ * TLS and init/fini fixture for elftree, built with:
+ *
+ *   gcc -shared -fPIC -O2 -nostdlib -s -Wl,-soname,libtls.so.1 \
+ *       -Wl,-z,noseparate-code -Wl,-z,max-page-size=4096 \
+ *       -Wl,--build-id=none -o libtls.so.1 tls.c
+ */
+__thread int tls_data = 1;
+__thread int tls_bss;
+__thread int tls_ie __attribute__((tls_model("initial-exec")));
+
+int get_data(void) { return tls_data; }
+int get_bss(void) { return tls_bss; }
+int get_ie(void) { return tls_ie; }
+
+static int state;
+
+__attribute__((constructor(101))) static void ctor_first(void) { state = 1; }
+__attribute__((constructor(200))) static void ctor_second(void) { state *= 2; }
+__attribute__((constructor)) static void ctor_default(void) { state += 3; }
+__attribute__((destructor)) static void dtor(void) { state = 0; }
+
+int get_state(void) { return state; }
@@ -0,0 +1,317 @@
+/*
+ * ELF tree - Tree viewer for ELF library dependency
+ *
+ * Copyright (C) 2017-2018  Namhyung Kim <namhyung@gmail.com>
+ *
+ * Released under MIT license.
+ */
+package main
+
+import (
+	"debug/elf"
+	"fmt"
+	"strings"
+)
+
+// TLS access models
+const (
+	TLS_GD   = iota // general dynamic
+	TLS_LD          // local dynamic
+	TLS_DESC        // TLS descriptors (a variant of general dynamic)
+	TLS_IE          // initial exec: needs static TLS
+	TLS_LE          // local exec: only in executables
+	TLS_MAX
+)
+
+var tlsModelNames = []string{"general-dynamic", "local-dynamic", "TLS descriptor", "initial-exec", "local-exec"}
+
+// optional static TLS which glibc reserves for dlopen (rtld.optional_static_tls)
+const TLS_SURPLUS = 512
+
+// libraries that glibc reserves static TLS for
+var tlsSystemLibs = []string{"libc.so", "libm.so", "libpthread.so", "libdl.so", "librt.so",
+	"libresolv.so", "libutil.so", "ld-linux", "ld64.so", "ld-musl", "libc.musl"}
+
+type TlsInfo struct {
+	size   uint64 // memory size of PT_TLS (.tdata + .tbss)
+	init   uint64 // size of initialized data (.tdata)
+	align  uint64
+	static bool // DF_STATIC_TLS
+	models [TLS_MAX]int
+}
+
+var tlsCache = make(map[string]*TlsInfo)
+
+// objects loaded at startup by the executables (see startupObjects)
+var tlsStartup map[string]bool
+
+const TLS_HEURISTIC = "libraries not loaded at startup by the given executables are assumed to be dlopen'ed"
+
+// returns the TLS model of the relocation type (or -1 if it's not for TLS)
+func tlsModel(info *DepsInfo, typ uint32) int {
+	name := relocTypeName(info.mach, typ)
+	has := func(subs ...string) bool {
+		for _, s := range subs {
+			if strings.Contains(name, s) {
+				return true
+			}
+		}
+		return false
+	}
+
+	switch {
+	case has("DESC"):
+		return TLS_DESC
+	case has("TLSGD", "TLS_GD", "DTPMOD"):
+		return TLS_GD
+	case has("DTPOFF", "DTPREL") && info.kind != elf.ET_REL:
+		// paired with DTPMOD in dynamic relocations
+		return -1
+	case has("TLSLD", "TLS_LDM", "TLS_LDO", "DTPOFF", "DTPREL"):
+		return TLS_LD
+	case has("GOTTPOFF", "GOTTPREL", "GOT_TPREL", "TLSIE", "TLS_IE", "TLS_GOTIE", "TLS_GOT"):
+		return TLS_IE
+	case has("TPOFF", "TPREL", "TLSLE", "TLS_LE"):
+		// dynamic relocations with TP offsets need static TLS
+		if info.kind != elf.ET_REL {
+			return TLS_IE
+		}
+		return TLS_LE
+	}
+	return -1
+}
+
+func readTlsInfo(name string) *TlsInfo {
+	if ti, ok := tlsCache[name]; ok {
+		return ti
+	}
+
+	info := deps[name]
+	ti := &TlsInfo{}
+	tlsCache[name] = ti
+
+	if info.endian == nil {
+		return ti
+	}
+
+	for _, p := range info.prog {
+		if p.Type == elf.PT_TLS {
+			ti.size, ti.init, ti.align = p.Memsz, p.Filesz, p.Align
+		}
+	}
+	if len(info.prog) == 0 {
+		// relocatable objects have TLS sections only
+		for _, s := range info.sect {
+			if s.Flags&elf.SHF_TLS == 0 {
+				continue
+			}
+			ti.size += s.Size
+			if s.Type != elf.SHT_NOBITS {
+				ti.init += s.Size
+			}
+			if s.Addralign > ti.align {
+				ti.align = s.Addralign
+			}
+		}
+	}
+
+	for _, d := range info.dyns {
+		if d.tag == elf.DT_FLAGS && d.val.(uint64)&0x10 != 0 { // DF_STATIC_TLS
+			ti.static = true
+		}
+	}
+
+	for i, s := range info.sect {
+		if !isRelocSection(s) || s.Type == SHT_RELR {
+			continue
+		}
+
+		relocs, err := readRelocs(&info, i)
+		if err != nil {
+			continue
+		}
+		for _, r := range relocs {
+			if m := tlsModel(&info, r.typ); m >= 0 {
+				ti.models[m]++
+			}
+		}
+	}
+	return ti
+}
+
+// static TLS block size with the alignment
+func (ti *TlsInfo) staticSize() uint64 {
+	if ti.align <= 1 {
+		return ti.size
+	}
+	return (ti.size + ti.align - 1) / ti.align * ti.align
+}
+
+func (ti *TlsInfo) used() bool {
+	if ti.size > 0 || ti.static {
+		return true
+	}
+	for _, n := range ti.models {
+		if n > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func (ti *TlsInfo) modelString() string {
+	var models []string
+	for m, n := range ti.models {
+		if n > 0 {
+			models = append(models, fmt.Sprintf("%s (%d)", tlsModelNames[m], n))
+		}
+	}
+	if len(models) == 0 {
+		return "none"
+	}
+	return strings.Join(models, ", ")
+}
+
+func isExecutable(info *DepsInfo) bool {
+	return info.kind == elf.ET_EXEC || info.interp != "" || strings.HasPrefix(info.link, "static")
+}
+
+func isSystemLib(name string) bool {
+	for _, l := range tlsSystemLibs {
+		if strings.HasPrefix(name, l) {
+			return true
+		}
+	}
+	return false
+}
+
+// objects loaded at startup by the executables among the roots.
+// others are reachable only from libraries (like plugins) so they
+// are likely dlopen'ed.
+func startupObjects(roots []*DepsNode) map[string]bool {
+	loaded := make(map[string]bool)
+
+	for _, root := range roots {
+		info, ok := deps[root.name]
+		if !ok {
+			// top of multiple roots
+			for k := range startupObjects(root.child) {
+				loaded[k] = true
+			}
+			continue
+		}
+		if !isExecutable(&info) {
+			continue
+		}
+		for _, name := range loadOrder(root.name) {
+			loaded[name] = true
+		}
+	}
+	return loaded
+}
+
+// check whether the object needs static TLS when it's dlopen'ed
+func tlsWarning(name string) string {
+	info := deps[name]
+	ti := readTlsInfo(name)
+
+	if info.kind != elf.ET_DYN || isExecutable(&info) || isSystemLib(name) {
+		return ""
+	}
+	// objects loaded at startup get static TLS from ld.so
+	if tlsStartup[name] {
+		return ""
+	}
+	if ti.models[TLS_IE] == 0 && !ti.static {
+		return ""
+	}
+
+	msg := fmt.Sprintf("%s uses initial-exec TLS", name)
+	if ti.static {
+		msg += " (DF_STATIC_TLS)"
+	}
+	msg += " but is likely dlopen'ed"
+	if ti.staticSize() > TLS_SURPLUS {
+		msg += fmt.Sprintf(": %d bytes exceed %d bytes of static TLS surplus", ti.staticSize(), TLS_SURPLUS)
+	}
+	return msg
+}
+
+// total static TLS of objects loaded with the root
+func staticTlsSize(root string) (uint64, int) {
+	var total uint64
+	var count int
+
+	for _, name := range loadOrder(root) {
+		if ti := readTlsInfo(name); ti.size > 0 {
+			total += ti.staticSize()
+			count++
+		}
+	}
+	return total, count
+}
+
+func makeTlsStrings(name string) []string {
+	ti := readTlsInfo(name)
+	if !ti.used() {
+		return []string{"  (none)"}
+	}
+
+	lines := []string{
+		fmt.Sprintf("  Size: %d bytes (%d initialized, %d zeroed), align %d",
+			ti.size, ti.init, ti.size-ti.init, ti.align),
+		"  Models: " + ti.modelString(),
+	}
+	if ti.static {
+		lines = append(lines, "  Flags: STATIC_TLS")
+	}
+
+	total, count := staticTlsSize(name)
+	lines = append(lines, fmt.Sprintf("  Static TLS of the closure: %d bytes (%d objects)", total, count))
+
+	warned := false
+	for _, lib := range loadOrder(name) {
+		if w := tlsWarning(lib); w != "" {
+			lines = append(lines, "  warning: "+w)
+			warned = true
+		}
+	}
+	if warned {
+		lines = append(lines, "  ("+TLS_HEURISTIC+")")
+	}
+	return lines
+}
+
+func printTlsUsage(root *DepsNode) {
+	fmt.Println("TLS usage:")
+	fmt.Printf("  %-24s %8s %8s %5s  %-6s  %s\n", "Object", "Size", "Static", "Align", "Flags", "Models")
+
+	for _, name := range loadOrder(root.name) {
+		ti := readTlsInfo(name)
+		if !ti.used() {
+			continue
+		}
+
+		flags := ""
+		if ti.static {
+			flags = "STATIC"
+		}
+		fmt.Printf("  %-24s %8d %8d %5d  %-6s  %s\n", name, ti.size, ti.staticSize(), ti.align,
+			flags, ti.modelString())
+	}
+
+	total, count := staticTlsSize(root.name)
+	fmt.Println()
+	fmt.Printf("Static TLS: %d bytes (%d objects)\n", total, count)
+
+	warned := false
+	for _, name := range loadOrder(root.name) {
+		if w := tlsWarning(name); w != "" {
+			fmt.Println("warning: " + w)
+			warned = true
+		}
+	}
+	if warned {
+		fmt.Println("note: " + TLS_HEURISTIC)
+	}
+}
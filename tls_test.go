@@ -0,0 +1,102 @@
+/*
+ * ELF tree - Tree viewer for ELF library dependency
+ *
+ * Copyright (C) 2017-2018  Namhyung Kim <namhyung@gmail.com>
+ *
+ * Released under MIT license.
+ */
+package main
+
+import (
+	"debug/elf"
+	"testing"
+)
+
+func TestTlsModel(t *testing.T) {
+	tests := []struct {
+		mach elf.Machine
+		kind elf.Type
+		typ  uint32
+		want int
+	}{
+		// x86_64 code relocations in objects
+		{elf.EM_X86_64, elf.ET_REL, uint32(elf.R_X86_64_TLSGD), TLS_GD},
+		{elf.EM_X86_64, elf.ET_REL, uint32(elf.R_X86_64_TLSLD), TLS_LD},
+		{elf.EM_X86_64, elf.ET_REL, uint32(elf.R_X86_64_DTPOFF32), TLS_LD},
+		{elf.EM_X86_64, elf.ET_REL, uint32(elf.R_X86_64_GOTPC32_TLSDESC), TLS_DESC},
+		{elf.EM_X86_64, elf.ET_REL, uint32(elf.R_X86_64_GOTTPOFF), TLS_IE},
+		{elf.EM_X86_64, elf.ET_REL, uint32(elf.R_X86_64_TPOFF32), TLS_LE},
+		{elf.EM_X86_64, elf.ET_REL, uint32(elf.R_X86_64_PC32), -1},
+		// x86_64 dynamic relocations
+		{elf.EM_X86_64, elf.ET_DYN, uint32(elf.R_X86_64_DTPMOD64), TLS_GD},
+		{elf.EM_X86_64, elf.ET_DYN, uint32(elf.R_X86_64_DTPOFF64), -1},
+		{elf.EM_X86_64, elf.ET_DYN, uint32(elf.R_X86_64_TPOFF64), TLS_IE},
+		{elf.EM_X86_64, elf.ET_DYN, uint32(elf.R_X86_64_TLSDESC), TLS_DESC},
+		{elf.EM_X86_64, elf.ET_DYN, uint32(elf.R_X86_64_RELATIVE), -1},
+		// i386
+		{elf.EM_386, elf.ET_REL, uint32(elf.R_386_TLS_GD), TLS_GD},
+		{elf.EM_386, elf.ET_REL, uint32(elf.R_386_TLS_LDM), TLS_LD},
+		{elf.EM_386, elf.ET_REL, uint32(elf.R_386_TLS_LDO_32), TLS_LD},
+		{elf.EM_386, elf.ET_REL, uint32(elf.R_386_TLS_IE), TLS_IE},
+		{elf.EM_386, elf.ET_REL, uint32(elf.R_386_TLS_GOTIE), TLS_IE},
+		{elf.EM_386, elf.ET_REL, uint32(elf.R_386_TLS_LE), TLS_LE},
+		{elf.EM_386, elf.ET_DYN, uint32(elf.R_386_TLS_DTPMOD32), TLS_GD},
+		{elf.EM_386, elf.ET_DYN, uint32(elf.R_386_TLS_TPOFF), TLS_IE},
+		// aarch64
+		{elf.EM_AARCH64, elf.ET_REL, uint32(elf.R_AARCH64_TLSGD_ADR_PAGE21), TLS_GD},
+		{elf.EM_AARCH64, elf.ET_REL, uint32(elf.R_AARCH64_TLSDESC_ADR_PAGE21), TLS_DESC},
+		{elf.EM_AARCH64, elf.ET_REL, uint32(elf.R_AARCH64_TLSIE_ADR_GOTTPREL_PAGE21), TLS_IE},
+		{elf.EM_AARCH64, elf.ET_REL, uint32(elf.R_AARCH64_TLSLE_ADD_TPREL_HI12), TLS_LE},
+		{elf.EM_AARCH64, elf.ET_DYN, uint32(elf.R_AARCH64_TLS_TPREL64), TLS_IE},
+		{elf.EM_AARCH64, elf.ET_DYN, uint32(elf.R_AARCH64_TLSDESC), TLS_DESC},
+		{elf.EM_AARCH64, elf.ET_DYN, uint32(elf.R_AARCH64_TLS_DTPREL64), -1},
+	}
+	for _, tc := range tests {
+		info := &DepsInfo{mach: tc.mach, kind: tc.kind}
+		if got := tlsModel(info, tc.typ); got != tc.want {
+			t.Errorf("%s %s: got %d, want %d", tc.kind, relocTypeName(tc.mach, tc.typ), got, tc.want)
+		}
+	}
+}
+
+func TestReadTlsInfo(t *testing.T) {
+	deps = make(map[string]DepsInfo)
+	tlsCache = make(map[string]*TlsInfo)
+	root := loadDeps(TLS_FIXTURE, "libtls.so.1")
+
+	ti := readTlsInfo(root.name)
+	if ti.size != 12 || ti.init != 4 || ti.staticSize() != 12 || !ti.static {
+		t.Errorf("got size %d init %d static %v", ti.size, ti.init, ti.static)
+	}
+	// two DTPMOD64 for tls_data and tls_bss, TPOFF64 for tls_ie
+	if ti.models[TLS_GD] != 2 || ti.models[TLS_IE] != 1 || ti.models[TLS_LE] != 0 {
+		t.Errorf("got models %v", ti.models)
+	}
+}
+
+func TestTlsWarning(t *testing.T) {
+	deps = make(map[string]DepsInfo)
+	tlsCache = make(map[string]*TlsInfo)
+	lib := loadDeps(TLS_FIXTURE, "libtls.so.1")
+
+	// a library on its own is a plugin
+	tlsStartup = startupObjects([]*DepsNode{lib})
+	if w := tlsWarning(lib.name); w == "" {
+		t.Errorf("no warning for a plugin")
+	}
+
+	// loaded at startup by an executable in the other root
+	deps["prog"] = DepsInfo{kind: elf.ET_EXEC, libs: []string{lib.name}}
+	prog := &DepsNode{name: "prog"}
+	tlsStartup = startupObjects([]*DepsNode{makeMultiRoot([]*DepsNode{lib, prog})})
+	if w := tlsWarning(lib.name); w != "" {
+		t.Errorf("warning for a library loaded at startup: %s", w)
+	}
+
+	// the warning is shown with the heuristic
+	tlsStartup = nil
+	lines := makeTlsStrings(lib.name)
+	if last := lines[len(lines)-1]; last != "  ("+TLS_HEURISTIC+")" {
+		t.Errorf("got %q", last)
+	}
+}
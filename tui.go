@@ -9,6 +9,8 @@ package main
 
 import (
 	"fmt"
+	"strings"
+
 	tui "github.com/airking05/termui" // for termui bug #177
 )
 
@@ -19,7 +21,9 @@ type TreeItem struct {
 	next   *TreeItem
 	child  *TreeItem // pointer to first child
 	folded bool
-	total  int // number of (shown) children (not count itself)
+	total  int             // number of (shown) children (not count itself)
+	load   func(*TreeItem) // add children lazily when it's expanded
+	paged  bool            // contents read page by page, not loaded by search
 }
 
 type TreeView struct {
@@ -39,6 +43,10 @@ type TreeView struct {
 
 	rows int
 	cols int
+
+	Marks map[string]rune // optional marker in front of node name
+
+	search SearchState
 }
 
 type FileInfo struct {
@@ -48,6 +56,9 @@ type FileInfo struct {
 	idx  int
 	off  int
 	pos  int
+
+	orig   *TreeItem // unfiltered tree if Root is filtered
+	filter Matcher   // the filter Root was made with
 }
 
 const (
@@ -55,6 +66,10 @@ const (
 	MODE_SYMBOL
 	MODE_DYNAMIC
 	MODE_SECTION
+	MODE_GO
+	MODE_RELOC
+	MODE_PLT
+	MODE_SEGMENT
 )
 
 var (
@@ -63,12 +78,18 @@ var (
 	yinfo map[string]*FileInfo
 	dinfo map[string]*FileInfo
 	sinfo map[string]*FileInfo
+	ginfo map[string]*FileInfo
+	rinfo map[string]*FileInfo
+	pinfo map[string]*FileInfo
+	hinfo map[string]*FileInfo
 	focus *TreeView
 )
 
 type StatusLine struct {
 	tui.Block // embedded
 	tv        *TreeView
+
+	Notes map[string]string // optional notes after node name
 }
 
 func NewTreeView() *TreeView {
@@ -163,6 +184,14 @@ func (ti *TreeItem) toggle() {
 	}
 }
 
+// add children of lazy items (once)
+func (ti *TreeItem) loadChildren() {
+	if load := ti.load; load != nil {
+		ti.load = nil
+		load(ti)
+	}
+}
+
 func (tv *TreeView) drawDepsNode(buf tui.Buffer, dn *DepsNode, i, printed int, folded bool) {
 	fg := tv.ItemFgColor
 	bg := tv.ItemBgColor
@@ -183,7 +212,22 @@ func (tv *TreeView) drawDepsNode(buf tui.Buffer, dn *DepsNode, i, printed int, f
 		text_width = 0
 	}
 
-	cs := tui.DefaultTxBuilder.Build(dn.name, fg, bg)
+	name := dn.name
+	if tv.Marks != nil {
+		mark, ok := tv.Marks[dn.name]
+		if !ok {
+			mark = ' '
+		}
+		name = string(mark) + " " + name
+	}
+	if isBackEdge(dn) {
+		name += " " + CYCLE_MARK
+	}
+	if dn.seen {
+		name += " " + SEEN_MARK
+	}
+
+	cs := tui.DefaultTxBuilder.Build(name, fg, bg)
 	cs = tui.DTrimTxCls(cs, text_width)
 
 	j := 0
@@ -191,7 +235,7 @@ func (tv *TreeView) drawDepsNode(buf tui.Buffer, dn *DepsNode, i, printed int, f
 		// draw current line cursor from the beginning
 		for j < indent {
 			if j+1 > tv.pos {
-				buf.Set(j+1-tv.pos, printed+1, tui.Cell{' ', fg, bg})
+				buf.Set(tv.X+j+1-tv.pos, printed+1, tui.Cell{' ', fg, bg})
 			}
 			j++
 		}
@@ -201,20 +245,20 @@ func (tv *TreeView) drawDepsNode(buf tui.Buffer, dn *DepsNode, i, printed int, f
 
 	if j+1 > tv.pos {
 		if folded {
-			buf.Set(j+1-tv.pos, printed+1, tui.Cell{'+', fg, bg})
+			buf.Set(tv.X+j+1-tv.pos, printed+1, tui.Cell{'+', fg, bg})
 		} else {
-			buf.Set(j+1-tv.pos, printed+1, tui.Cell{'-', fg, bg})
+			buf.Set(tv.X+j+1-tv.pos, printed+1, tui.Cell{'-', fg, bg})
 		}
 	}
 	if j+2 > tv.pos {
-		buf.Set(j+2-tv.pos, printed+1, tui.Cell{' ', fg, bg})
+		buf.Set(tv.X+j+2-tv.pos, printed+1, tui.Cell{' ', fg, bg})
 	}
 	j += 2
 
 	for _, vv := range cs {
 		w := vv.Width()
 		if j+1 > tv.pos {
-			buf.Set(j+1-tv.pos, printed+1, vv)
+			buf.Set(tv.X+j+1-tv.pos, printed+1, vv)
 		}
 		j += w
 	}
@@ -226,7 +270,7 @@ func (tv *TreeView) drawDepsNode(buf tui.Buffer, dn *DepsNode, i, printed int, f
 	// draw current line cursor to the end
 	for j < tv.cols+tv.pos {
 		if j+1 > tv.pos {
-			buf.Set(j+1-tv.pos, printed+1, tui.Cell{' ', fg, bg})
+			buf.Set(tv.X+j+1-tv.pos, printed+1, tui.Cell{' ', fg, bg})
 		}
 		j++
 	}
@@ -444,6 +488,7 @@ func (tv *TreeView) Right(i int) {
 }
 
 func (tv *TreeView) Toggle() {
+	tv.Curr.loadChildren()
 	tv.Curr.toggle()
 }
 
@@ -454,7 +499,11 @@ func (sl *StatusLine) Buffer() tui.Buffer {
 	var line string
 
 	curr := sl.tv.Curr
-	if curr != nil {
+	if prompt.active {
+		line = prompt.String()
+	} else if statusMsg != "" {
+		line = statusMsg
+	} else if curr != nil {
 		node := curr.node.(*DepsNode)
 		line = node.name
 
@@ -464,6 +513,13 @@ func (sl *StatusLine) Buffer() tui.Buffer {
 
 			n = n.parent
 		}
+
+		if note, ok := sl.Notes[node.name]; ok {
+			line += "  [" + note + "]"
+		}
+		if focus != nil && focus.search.filter.pattern != "" {
+			line += "  (filter: " + focus.search.filter.pattern + ")"
+		}
 	} else {
 		line = "ELF tree"
 	}
@@ -550,25 +606,33 @@ func AddSubTree(name string, items []string, parent *TreeItem) {
 	parent.parent.total += len(items) + 1
 }
 
+func lastChild(ti *TreeItem) *TreeItem {
+	c := ti.child
+	for c.next != nil {
+		c = c.next
+	}
+	return c
+}
+
 func makeFileInfo(name string, info *DepsInfo) *FileInfo {
 	root := &TreeItem{node: name}
 
 	// general file info
 	AddSubTree("", nil, root)
-	AddSubTree("File Info", []string{"  Path: " + info.path,
-		"  Type: " + info.kind.String() + ", " + info.mach.String(),
-		"  Data: " + info.bits.String() + ", " + info.endian.String()},
-		root)
-
-	// program headers
-	var phdr []string
-	for _, v := range info.prog {
-		phdr = append(phdr, "  "+progHdrString(v))
+	file := []string{"  Path: " + info.path}
+	if info.endian != nil {
+		// missing objects have no ELF info
+		file = append(file, "  Type: "+info.kind.String()+", "+info.mach.String(),
+			"  Data: "+info.bits.String()+", "+info.endian.String())
 	}
-	AddSubTree("", nil, root)
-	AddSubTree(fmt.Sprintf("%-16s   %s %18s  %10s  %8s",
-		"Program Info", "Flags", "Vaddr", "Size", "Align"),
-		phdr, root)
+	file = append(file, "  Link: "+info.link)
+	if info.interp != "" {
+		file = append(file, "  Interp: "+info.interp)
+	}
+	if info.libc != "" {
+		file = append(file, "  Libc: "+info.libc)
+	}
+	AddSubTree("File Info", file, root)
 
 	// dependent libraries
 	var libs []string
@@ -578,27 +642,76 @@ func makeFileInfo(name string, info *DepsInfo) *FileInfo {
 	AddSubTree("", nil, root)
 	AddSubTree("Dependencies", libs, root)
 
+	// kernel module info
+	if info.link == "kernel module" {
+		var modinfo []string
+		for _, v := range info.modinfo {
+			modinfo = append(modinfo, "  "+strings.Replace(v, "=", ": ", 1))
+		}
+		AddSubTree("", nil, root)
+		AddSubTree("Module Info", modinfo, root)
+	}
+
+	// undefined symbols of relocatable objects
+	if info.link == "relocatable" || info.link == "kernel module" {
+		AddSubTree("", nil, root)
+		AddSubTree("Undefined Symbols", makeUndefStrings(info), root)
+	}
+
+	// init/fini functions are resolved when expanded
+	if info.endian != nil {
+		AddSubTree("", nil, root)
+		AddSubTree("Init/Fini Functions", nil, root)
+
+		ti := lastChild(root)
+		ti.folded = true
+		ti.load = func(ti *TreeItem) {
+			for _, s := range makeInitFuncStrings(name) {
+				appendChild(ti, s)
+			}
+		}
+	}
+
+	// TLS usage needs relocations
+	if info.endian != nil {
+		AddSubTree("", nil, root)
+		AddSubTree("TLS Info", nil, root)
+
+		ti := lastChild(root)
+		ti.folded = true
+		ti.load = func(ti *TreeItem) {
+			for _, s := range makeTlsStrings(name) {
+				appendChild(ti, s)
+			}
+		}
+	}
+
+	// notes
+	AddSubTree("", nil, root)
+	AddSubTree("Notes", makeNoteStrings(info), root)
+
 	return &FileInfo{Root: root, Top: root, Curr: root}
 }
 
 func makeSymbolInfo(name string, info *DepsInfo) *FileInfo {
 	root := &TreeItem{node: name}
 
+	if s := symSettingString(); s != "" {
+		AddSubTree("", nil, root)
+		AddSubTree("  ("+s+")", nil, root)
+	}
+
 	// dynamic symbols
 	AddSubTree("", nil, root)
-	var dsym []string
-	for _, v := range info.dsym {
-		dsym = append(dsym, makeSymbolString(v))
-	}
-	AddSubTree("Dynamic Symbols", dsym, root)
+	head, rows, shown := makeSymbolTable("Dynamic Symbols", info.dsym)
+	AddSubTree(head, rows, root)
+	setSymbolDisasm(lastChild(root), name, shown)
 
 	// normal symbols
 	AddSubTree("", nil, root)
-	var nsym []string
-	for _, v := range info.syms {
-		nsym = append(nsym, makeSymbolString(v))
-	}
-	AddSubTree("Symbols", nsym, root)
+	head, rows, shown = makeSymbolTable("Symbols", info.syms)
+	AddSubTree(head, rows, root)
+	setSymbolDisasm(lastChild(root), name, shown)
 
 	return &FileInfo{Root: root, Top: root, Curr: root}
 }
@@ -610,6 +723,21 @@ func makeDynamicInfo(name string, info *DepsInfo) *FileInfo {
 	AddSubTree("", nil, root)
 	AddSubTree("Dynamic Info", makeDynamicStrings(info), root)
 
+	// hash tables are read when expanded
+	if len(info.dyns) > 0 {
+		AddSubTree("", nil, root)
+		AddSubTree("Hash Tables", nil, root)
+
+		ti := lastChild(root)
+		ti.folded = true
+		ti.load = func(ti *TreeItem) {
+			info := deps[name]
+			for _, s := range makeHashStrings(name, &info) {
+				appendChild(ti, s)
+			}
+		}
+	}
+
 	return &FileInfo{Root: root, Top: root, Curr: root}
 }
 
@@ -626,29 +754,147 @@ func makeSectionInfo(name string, info *DepsInfo) *FileInfo {
 	}
 	AddSubTree("Section Info", sect, root)
 
+	// section contents (skip the header line)
+	i := 0
+	for ti := lastChild(root).child.next; ti != nil; ti = ti.next {
+		setSectionDump(ti, info.path, info.sect, i)
+		i++
+	}
+
 	return &FileInfo{Root: root, Top: root, Curr: root}
 }
 
-func saveInfoView(tv, iv *TreeView) {
-	if focus != tv {
-		return
+func makeSegmentInfo(name string, info *DepsInfo) *FileInfo {
+	root := &TreeItem{node: name}
+
+	if len(info.prog) == 0 {
+		AddSubTree("", nil, root)
+		AddSubTree("No program headers", nil, root)
+		return &FileInfo{Root: root, Top: root, Curr: root}
 	}
 
-	curr := tv.Curr
-	node := curr.node.(*DepsNode)
+	// program headers
+	phdr := []string{progHdrHeader()}
+	for _, v := range info.prog {
+		phdr = append(phdr, progHdrString(info.mach, v))
+	}
+	AddSubTree("", nil, root)
+	AddSubTree(fmt.Sprintf("Program Headers (%d)", len(info.prog)), phdr, root)
+
+	// segment contents (skip the header line)
+	i := 0
+	for ti := lastChild(root).child.next; ti != nil; ti = ti.next {
+		setSegmentDump(ti, info.path, info.prog, i)
+		i++
+	}
+
+	// section to segment mapping
+	var mapping []string
+	for i, v := range info.prog {
+		mapping = append(mapping, fmt.Sprintf("  %02d  %-18s %s", i,
+			progTypeName(info.mach, v.Type), strings.Join(segmentSections(info, v), " ")))
+	}
+	AddSubTree("", nil, root)
+	AddSubTree("Section to Segment Mapping", mapping, root)
+
+	return &FileInfo{Root: root, Top: root, Curr: root}
+}
+
+func makeGoInfo(name string, info *DepsInfo) *FileInfo {
+	root := &TreeItem{node: name}
+
+	if info.goinfo == nil {
+		AddSubTree("", nil, root)
+		AddSubTree("Not a Go binary", nil, root)
+		return &FileInfo{Root: root, Top: root, Curr: root}
+	}
 
-	var info *FileInfo
+	summary, mods, settings, cgo := makeGoStrings(name)
+
+	AddSubTree("", nil, root)
+	AddSubTree("Go Build Info", summary, root)
+	AddSubTree("", nil, root)
+	AddSubTree(fmt.Sprintf("Modules (%d)", len(mods)), mods, root)
+	AddSubTree("", nil, root)
+	AddSubTree("Build Settings", settings, root)
+	if len(cgo) > 0 {
+		AddSubTree("", nil, root)
+		AddSubTree("Cgo Libraries", cgo, root)
+	}
+
+	return &FileInfo{Root: root, Top: root, Curr: root}
+}
+
+// returns saved info view of the node in current mode
+func currInfo(name string) *FileInfo {
+	var infos map[string]*FileInfo
 
 	if mode == MODE_FILE {
-		info = finfo[node.name]
+		infos = finfo
 	} else if mode == MODE_SYMBOL {
-		info = yinfo[node.name]
+		infos = yinfo
 	} else if mode == MODE_DYNAMIC {
-		info = dinfo[node.name]
+		infos = dinfo
 	} else if mode == MODE_SECTION {
-		info = sinfo[node.name]
+		infos = sinfo
+	} else if mode == MODE_GO {
+		infos = ginfo
+	} else if mode == MODE_RELOC {
+		infos = rinfo
+	} else if mode == MODE_PLT {
+		infos = pinfo
+	} else if mode == MODE_SEGMENT {
+		infos = hinfo
+	}
+
+	info, ok := infos[name]
+	if ok {
+		return info
+	}
+
+	// some views are made when it's shown first (or again after a change)
+	if v, found := deps[name]; found {
+		switch mode {
+		case MODE_SYMBOL:
+			info = makeSymbolInfo(name, &v)
+		case MODE_RELOC:
+			info = makeRelocInfo(name, &v)
+		case MODE_PLT:
+			info = makePltInfo(name, &v)
+		}
+	}
+	if info == nil {
+		// no info for non-ELF nodes like headings
+		root := &TreeItem{node: ""}
+		info = &FileInfo{Root: root, Top: root, Curr: root}
+	}
+	infos[name] = info
+	return info
+}
+
+func makeSummaryInfo(name string, roots []*DepsNode) *FileInfo {
+	root := &TreeItem{node: name}
+
+	used, unused := makeSummaryStrings(roots)
+
+	AddSubTree("", nil, root)
+	AddSubTree(fmt.Sprintf("Used Libraries (by %d binaries)", len(roots)), used, root)
+	AddSubTree("", nil, root)
+	AddSubTree("Unused Libraries", unused, root)
+
+	return &FileInfo{Root: root, Top: root, Curr: root}
+}
+
+func saveInfoView(tv, iv *TreeView) {
+	if focus != tv {
+		return
 	}
 
+	curr := tv.Curr
+	node := curr.node.(*DepsNode)
+
+	info := currInfo(node.name)
+
 	info.Root = iv.Root
 	info.Top = iv.Top
 	info.Curr = iv.Curr
@@ -656,6 +902,10 @@ func saveInfoView(tv, iv *TreeView) {
 	info.off = iv.off
 	info.idx = iv.idx
 	info.pos = iv.pos
+
+	// keep the filtered view as long as the filter is same
+	info.orig = iv.search.orig
+	info.filter = iv.search.filter
 }
 
 func restoreInfoView(tv, iv *TreeView) {
@@ -666,17 +916,7 @@ func restoreInfoView(tv, iv *TreeView) {
 	curr := tv.Curr
 	node := curr.node.(*DepsNode)
 
-	var info *FileInfo
-
-	if mode == MODE_FILE {
-		info = finfo[node.name]
-	} else if mode == MODE_SYMBOL {
-		info = yinfo[node.name]
-	} else if mode == MODE_DYNAMIC {
-		info = dinfo[node.name]
-	} else if mode == MODE_SECTION {
-		info = sinfo[node.name]
-	}
+	info := currInfo(node.name)
 
 	iv.Root = info.Root
 	iv.Top = info.Top
@@ -685,6 +925,64 @@ func restoreInfoView(tv, iv *TreeView) {
 	iv.off = info.off
 	iv.idx = info.idx
 	iv.pos = info.pos
+
+	// the filter is kept for other nodes
+	iv.search.orig = info.orig
+	if info.orig != nil && info.filter.same(&iv.search.filter) {
+		return
+	}
+
+	iv.clearFilter()
+	if iv.search.filter.pattern != "" {
+		iv.applyFilter()
+	}
+}
+
+// saved state of the normal dependency tree
+var normalView *FileInfo
+
+// replace the dependency tree with another view
+func switchDepsView(tv *TreeView, dep *DepsNode, label string) *TreeItem {
+	tv.clearFilter()
+	if normalView == nil {
+		normalView = &FileInfo{Root: tv.Root, Top: tv.Top, Curr: tv.Curr,
+			idx: tv.idx, off: tv.off, pos: tv.pos}
+	}
+
+	tv.Root = makeDepsItems(dep, nil)
+	tv.Top = tv.Root
+	tv.Curr = tv.Root
+	tv.idx = 0
+	tv.off = 0
+	tv.pos = 0
+
+	tv.BorderLabel = label
+	if tv.search.filter.pattern != "" {
+		tv.applyFilter()
+	}
+	return tv.Root
+}
+
+// go back to the normal dependency tree
+func restoreDepsView(tv *TreeView) {
+	if normalView == nil {
+		return
+	}
+	tv.clearFilter()
+
+	tv.Root = normalView.Root
+	tv.Top = normalView.Top
+	tv.Curr = normalView.Curr
+	tv.idx = normalView.idx
+	tv.off = normalView.off
+	tv.pos = normalView.pos
+
+	tv.BorderLabel = "ELF Tree"
+	normalView = nil
+
+	if tv.search.filter.pattern != "" {
+		tv.applyFilter()
+	}
 }
 
 func resize(tv, iv *TreeView, sl *StatusLine) {
@@ -712,6 +1010,9 @@ func ShowWithTUI(dep *DepsNode) {
 	}
 	defer tui.Close()
 
+	if tlsStartup == nil {
+		tlsStartup = startupObjects([]*DepsNode{dep})
+	}
 	root := makeDepsItems(dep, nil)
 
 	tv := NewTreeView()
@@ -732,16 +1033,29 @@ func ShowWithTUI(dep *DepsNode) {
 
 	sl := NewStatusLine(tv)
 
+	tv.Marks = depsMarks
+	sl.Notes = depsNotes
+
 	finfo = make(map[string]*FileInfo)
 	yinfo = make(map[string]*FileInfo)
 	dinfo = make(map[string]*FileInfo)
 	sinfo = make(map[string]*FileInfo)
+	ginfo = make(map[string]*FileInfo)
+	rinfo = make(map[string]*FileInfo)
+	pinfo = make(map[string]*FileInfo)
+	hinfo = make(map[string]*FileInfo)
 
 	for k, v := range deps {
 		finfo[k] = makeFileInfo(k, &v)
 		yinfo[k] = makeSymbolInfo(k, &v)
 		dinfo[k] = makeDynamicInfo(k, &v)
 		sinfo[k] = makeSectionInfo(k, &v)
+		hinfo[k] = makeSegmentInfo(k, &v)
+		ginfo[k] = makeGoInfo(k, &v)
+	}
+	if _, ok := deps[dep.name]; !ok {
+		// summary for multiple binaries
+		finfo[dep.name] = makeSummaryInfo(dep.name, dep.child)
 	}
 	mode = MODE_FILE
 	focus = tv
@@ -754,8 +1068,27 @@ func ShowWithTUI(dep *DepsNode) {
 	tui.Render(iv)
 	tui.Render(sl)
 
+	// keys go to the search prompt while it's active
+	handleKey := func(key string, f func(tui.Event)) {
+		tui.Handle("/sys/kbd/"+key, func(e tui.Event) {
+			statusMsg = ""
+			if !prompt.active {
+				f(e)
+				return
+			}
+
+			saveInfoView(tv, iv)
+			prompt.input(promptKey(e.Path))
+			restoreInfoView(tv, iv)
+
+			tui.Render(tv)
+			tui.Render(iv)
+			tui.Render(sl)
+		})
+	}
+
 	// handle key pressing
-	tui.Handle("/sys/kbd/q", func(tui.Event) {
+	handleKey("q", func(tui.Event) {
 		// press q to quit
 		tui.StopLoop()
 	})
@@ -764,7 +1097,7 @@ func ShowWithTUI(dep *DepsNode) {
 		tui.StopLoop()
 	})
 
-	tui.Handle("/sys/kbd/f", func(tui.Event) {
+	handleKey("f", func(tui.Event) {
 		if focus == tv {
 			mode = MODE_FILE
 			restoreInfoView(tv, iv)
@@ -773,7 +1106,7 @@ func ShowWithTUI(dep *DepsNode) {
 		tui.Render(iv)
 		tui.Render(sl)
 	})
-	tui.Handle("/sys/kbd/y", func(tui.Event) {
+	handleKey("y", func(tui.Event) {
 		if focus == tv {
 			mode = MODE_SYMBOL
 			restoreInfoView(tv, iv)
@@ -782,7 +1115,7 @@ func ShowWithTUI(dep *DepsNode) {
 		tui.Render(iv)
 		tui.Render(sl)
 	})
-	tui.Handle("/sys/kbd/d", func(tui.Event) {
+	handleKey("d", func(tui.Event) {
 		if focus == tv {
 			mode = MODE_DYNAMIC
 			restoreInfoView(tv, iv)
@@ -791,7 +1124,7 @@ func ShowWithTUI(dep *DepsNode) {
 		tui.Render(iv)
 		tui.Render(sl)
 	})
-	tui.Handle("/sys/kbd/s", func(tui.Event) {
+	handleKey("s", func(tui.Event) {
 		if focus == tv {
 			mode = MODE_SECTION
 			restoreInfoView(tv, iv)
@@ -801,7 +1134,124 @@ func ShowWithTUI(dep *DepsNode) {
 		tui.Render(sl)
 	})
 
-	tui.Handle("/sys/kbd/<down>", func(tui.Event) {
+	handleKey("h", func(tui.Event) {
+		if focus == tv {
+			mode = MODE_SEGMENT
+			restoreInfoView(tv, iv)
+		}
+
+		tui.Render(iv)
+		tui.Render(sl)
+	})
+	handleKey("g", func(tui.Event) {
+		if focus == tv {
+			mode = MODE_GO
+			restoreInfoView(tv, iv)
+		}
+
+		tui.Render(iv)
+		tui.Render(sl)
+	})
+
+	handleKey("l", func(tui.Event) {
+		if focus == tv {
+			mode = MODE_RELOC
+			restoreInfoView(tv, iv)
+		}
+
+		tui.Render(iv)
+		tui.Render(sl)
+	})
+
+	handleKey("p", func(tui.Event) {
+		if focus == tv {
+			mode = MODE_PLT
+			restoreInfoView(tv, iv)
+		}
+
+		tui.Render(iv)
+		tui.Render(sl)
+	})
+
+	handleKey("m", func(tui.Event) {
+		// toggle mangled and demangled symbol names
+		demangleNames = !demangleNames
+
+		yinfo = make(map[string]*FileInfo)
+		rinfo = make(map[string]*FileInfo)
+		pinfo = make(map[string]*FileInfo)
+		symIndexCache = make(map[string]SymIndex)
+		for k, v := range deps {
+			if v.link == "relocatable" || v.link == "kernel module" {
+				finfo[k] = makeFileInfo(k, &v)
+			}
+		}
+
+		// info view is rebuilt even if it has the focus
+		saved := focus
+		focus = tv
+		restoreInfoView(tv, iv)
+		focus = saved
+
+		tui.Render(iv)
+		tui.Render(sl)
+	})
+
+	// alternative views of the dependency tree
+	const (
+		VIEW_TREE = iota
+		VIEW_REVERSE
+		VIEW_ORDER
+	)
+	view := VIEW_TREE
+
+	handleKey("r", func(tui.Event) {
+		if focus != tv {
+			return
+		}
+
+		if view != VIEW_REVERSE {
+			node := tv.Curr.node.(*DepsNode)
+			rev := makeReverseDeps(node.name, nil, make(map[string]bool))
+
+			switchDepsView(tv, rev, "Dependents of "+node.name)
+			view = VIEW_REVERSE
+		} else {
+			restoreDepsView(tv)
+			view = VIEW_TREE
+		}
+		restoreInfoView(tv, iv)
+
+		tui.Render(tv)
+		tui.Render(iv)
+		tui.Render(sl)
+	})
+	handleKey("o", func(tui.Event) {
+		if focus != tv {
+			return
+		}
+
+		if view != VIEW_ORDER {
+			root := switchDepsView(tv, makeOrderDeps(dep), "Load Order")
+			finfo["Initializer order"] = makeInitOrderInfo(dep.name)
+
+			// fold local scope of each object
+			for c := root.child.child; c != nil; c = c.next {
+				c.fold()
+			}
+			view = VIEW_ORDER
+		} else {
+			restoreDepsView(tv)
+			view = VIEW_TREE
+		}
+		restoreInfoView(tv, iv)
+
+		tui.Render(tv)
+		tui.Render(iv)
+		tui.Render(sl)
+	})
+
+	handleKey("<down>", func(tui.Event) {
 		saveInfoView(tv, iv)
 		focus.Down()
 		restoreInfoView(tv, iv)
@@ -811,7 +1261,7 @@ func ShowWithTUI(dep *DepsNode) {
 		tui.Render(sl)
 
 	})
-	tui.Handle("/sys/kbd/<up>", func(tui.Event) {
+	handleKey("<up>", func(tui.Event) {
 		saveInfoView(tv, iv)
 		focus.Up()
 		restoreInfoView(tv, iv)
@@ -821,27 +1271,27 @@ func ShowWithTUI(dep *DepsNode) {
 		tui.Render(sl)
 
 	})
-	tui.Handle("/sys/kbd/<left>", func(tui.Event) {
+	handleKey("<left>", func(tui.Event) {
 		focus.Left(1)
 		tui.Render(focus)
 		// no need to redraw sl
 	})
-	tui.Handle("/sys/kbd/<right>", func(tui.Event) {
+	handleKey("<right>", func(tui.Event) {
 		focus.Right(1)
 		tui.Render(focus)
 		// no need to redraw sl
 	})
-	tui.Handle("/sys/kbd/<", func(tui.Event) {
+	handleKey("<", func(tui.Event) {
 		focus.Left(3)
 		tui.Render(focus)
 		// no need to redraw sl
 	})
-	tui.Handle("/sys/kbd/>", func(tui.Event) {
+	handleKey(">", func(tui.Event) {
 		focus.Right(3)
 		tui.Render(focus)
 		// no need to redraw sl
 	})
-	tui.Handle("/sys/kbd/<next>", func(tui.Event) {
+	handleKey("<next>", func(tui.Event) {
 		saveInfoView(tv, iv)
 		focus.PageDown()
 		restoreInfoView(tv, iv)
@@ -850,7 +1300,7 @@ func ShowWithTUI(dep *DepsNode) {
 		tui.Render(iv)
 		tui.Render(sl)
 	})
-	tui.Handle("/sys/kbd/<previous>", func(tui.Event) {
+	handleKey("<previous>", func(tui.Event) {
 		saveInfoView(tv, iv)
 		focus.PageUp()
 		restoreInfoView(tv, iv)
@@ -859,7 +1309,7 @@ func ShowWithTUI(dep *DepsNode) {
 		tui.Render(iv)
 		tui.Render(sl)
 	})
-	tui.Handle("/sys/kbd/<home>", func(tui.Event) {
+	handleKey("<home>", func(tui.Event) {
 		saveInfoView(tv, iv)
 		focus.Home()
 		restoreInfoView(tv, iv)
@@ -868,7 +1318,7 @@ func ShowWithTUI(dep *DepsNode) {
 		tui.Render(iv)
 		tui.Render(sl)
 	})
-	tui.Handle("/sys/kbd/<end>", func(tui.Event) {
+	handleKey("<end>", func(tui.Event) {
 		saveInfoView(tv, iv)
 		focus.End()
 		restoreInfoView(tv, iv)
@@ -878,14 +1328,14 @@ func ShowWithTUI(dep *DepsNode) {
 		tui.Render(sl)
 	})
 
-	tui.Handle("/sys/kbd/<enter>", func(tui.Event) {
+	handleKey("<enter>", func(tui.Event) {
 		focus.Toggle()
 		tui.Render(tv)
 		tui.Render(iv)
 		tui.Render(sl)
 	})
 
-	tui.Handle("/sys/kbd/<tab>", func(tui.Event) {
+	handleKey("<tab>", func(tui.Event) {
 		if focus == tv {
 			focus = iv
 		} else {
@@ -897,6 +1347,85 @@ func ShowWithTUI(dep *DepsNode) {
 		tui.Render(sl)
 	})
 
+	// sort and filter symbols
+	updateSymbols := func() {
+		yinfo = make(map[string]*FileInfo)
+		restoreInfoView(tv, iv)
+
+		tui.Render(iv)
+		tui.Render(sl)
+	}
+	for i := 0; i <= SYM_COL_MAX; i++ {
+		col := i - 1 // 0 is for the file order
+		handleKey(fmt.Sprint(i), func(tui.Event) {
+			if mode == MODE_SYMBOL && focus == tv {
+				sortSymbols(col)
+				updateSymbols()
+			}
+		})
+	}
+	handleKey("t", func(tui.Event) {
+		if mode == MODE_SYMBOL && focus == tv {
+			symTypeIdx = (symTypeIdx + 1) % len(symTypeFilters)
+			updateSymbols()
+		}
+	})
+	handleKey("b", func(tui.Event) {
+		if mode == MODE_SYMBOL && focus == tv {
+			symBindIdx = (symBindIdx + 1) % len(symBindFilters)
+			updateSymbols()
+		}
+	})
+	handleKey("u", func(tui.Event) {
+		if mode == MODE_SYMBOL && focus == tv {
+			symDefIdx = (symDefIdx + 1) % len(symDefFilters)
+			updateSymbols()
+		}
+	})
+
+	handleKey("/", func(tui.Event) {
+		prompt.start('/', focus)
+		tui.Render(sl)
+	})
+	handleKey("&", func(tui.Event) {
+		prompt.start('&', focus)
+		tui.Render(sl)
+	})
+	handleKey("n", func(tui.Event) {
+		saveInfoView(tv, iv)
+		focus.searchNext(true)
+		restoreInfoView(tv, iv)
+
+		tui.Render(tv)
+		tui.Render(iv)
+		tui.Render(sl)
+	})
+	handleKey("N", func(tui.Event) {
+		saveInfoView(tv, iv)
+		focus.searchNext(false)
+		restoreInfoView(tv, iv)
+
+		tui.Render(tv)
+		tui.Render(iv)
+		tui.Render(sl)
+	})
+	// other keys are used only in the prompt
+	tui.Handle("/sys/kbd", func(e tui.Event) {
+		statusMsg = ""
+		if !prompt.active {
+			tui.Render(sl)
+			return
+		}
+
+		saveInfoView(tv, iv)
+		prompt.input(promptKey(e.Path))
+		restoreInfoView(tv, iv)
+
+		tui.Render(tv)
+		tui.Render(iv)
+		tui.Render(sl)
+	})
+
 	tui.Handle("/sys/wnd/resize", func(tui.Event) {
 		resize(tv, iv, sl)
 
@@ -907,3 +1436,144 @@ func ShowWithTUI(dep *DepsNode) {
 
 	tui.Loop()
 }
+
+func resizeDiff(ov, nv *TreeView, sl *StatusLine) {
+	ov.Height = tui.TermHeight() - 1
+	ov.Width = tui.TermWidth() / 2
+
+	ov.rows = ov.Height - 2
+	ov.cols = ov.Width - 2
+
+	nv.Height = tui.TermHeight() - 1
+	nv.Width = tui.TermWidth() - ov.Width
+	nv.X = ov.Width
+
+	nv.rows = nv.Height - 2
+	nv.cols = nv.Width - 2
+
+	sl.Height = 1
+	sl.Width = tui.TermWidth()
+	sl.Y = tui.TermHeight() - 1
+}
+
+// show old and new dependency trees side by side
+func ShowDiffWithTUI(oldDep, newDep *DepsNode, d *DepsDiff) {
+	if err := tui.Init(); err != nil {
+		panic(err)
+	}
+	defer tui.Close()
+
+	ov := NewTreeView()
+	ov.Root = makeDepsItems(oldDep, nil)
+	ov.Curr = ov.Root
+	ov.Top = ov.Root
+	ov.BorderLabel = "Old: " + d.Old
+
+	nv := NewTreeView()
+	nv.Root = makeDepsItems(newDep, nil)
+	nv.Curr = nv.Root
+	nv.Top = nv.Root
+	nv.BorderLabel = "New: " + d.New
+
+	sl := NewStatusLine(ov)
+
+	ov.Marks = make(map[string]rune)
+	nv.Marks = make(map[string]rune)
+	sl.Notes = make(map[string]string)
+
+	for _, v := range d.Removed {
+		ov.Marks[v.Name] = '-'
+		sl.Notes[v.Name] = "removed"
+	}
+	for _, v := range d.Added {
+		nv.Marks[v.Name] = '+'
+		sl.Notes[v.Name] = "added"
+	}
+	for _, od := range d.Changed {
+		if od.Root {
+			// root binaries are compared regardless of their names
+			ov.Marks[oldDep.name] = '~'
+			sl.Notes[oldDep.name] = diffNote(&od)
+		}
+		ov.Marks[od.Name] = '~'
+		nv.Marks[od.Name] = '~'
+		sl.Notes[od.Name] = diffNote(&od)
+	}
+
+	for _, tv := range []*TreeView{ov, nv} {
+		tv.FocusFgColor = tui.ColorYellow
+		tv.FocusBgColor = tui.ColorBlue
+	}
+	focus = ov
+
+	resizeDiff(ov, nv, sl)
+
+	render := func() {
+		tui.Render(ov)
+		tui.Render(nv)
+		tui.Render(sl)
+	}
+	render()
+
+	tui.Handle("/sys/kbd/q", func(tui.Event) {
+		tui.StopLoop()
+	})
+	tui.Handle("/sys/kbd/C-c", func(tui.Event) {
+		tui.StopLoop()
+	})
+
+	tui.Handle("/sys/kbd/<down>", func(tui.Event) {
+		focus.Down()
+		render()
+	})
+	tui.Handle("/sys/kbd/<up>", func(tui.Event) {
+		focus.Up()
+		render()
+	})
+	tui.Handle("/sys/kbd/<left>", func(tui.Event) {
+		focus.Left(1)
+		tui.Render(focus)
+	})
+	tui.Handle("/sys/kbd/<right>", func(tui.Event) {
+		focus.Right(1)
+		tui.Render(focus)
+	})
+	tui.Handle("/sys/kbd/<next>", func(tui.Event) {
+		focus.PageDown()
+		render()
+	})
+	tui.Handle("/sys/kbd/<previous>", func(tui.Event) {
+		focus.PageUp()
+		render()
+	})
+	tui.Handle("/sys/kbd/<home>", func(tui.Event) {
+		focus.Home()
+		render()
+	})
+	tui.Handle("/sys/kbd/<end>", func(tui.Event) {
+		focus.End()
+		render()
+	})
+	tui.Handle("/sys/kbd/<enter>", func(tui.Event) {
+		focus.Toggle()
+		render()
+	})
+
+	tui.Handle("/sys/kbd/<tab>", func(tui.Event) {
+		if focus == ov {
+			focus = nv
+		} else {
+			focus = ov
+		}
+		sl.tv = focus
+
+		render()
+	})
+
+	tui.Handle("/sys/wnd/resize", func(tui.Event) {
+		resizeDiff(ov, nv, sl)
+		render()
+	})
+
+	tui.Loop()
+}
@@ -0,0 +1,198 @@
+/*
+ * ELF tree - Tree viewer for ELF library dependency
+ *
+ * Copyright (C) 2017-2018  Namhyung Kim <namhyung@gmail.com>
+ *
+ * Released under MIT license.
+ */
+package main
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+)
+
+// check whether the library matches to the given soname or path
+func matchDep(name, target string) bool {
+	if name == target {
+		return true
+	}
+
+	info, ok := deps[name]
+	if !ok || info.path == "" {
+		return false
+	}
+
+	if strings.Contains(target, "/") {
+		return info.path == realPath(target)
+	}
+	return path.Base(info.path) == target
+}
+
+// returns names of objects which need the given library directly
+func findDependents(name string) []string {
+	var users []string
+
+	for k, v := range deps {
+		for _, lib := range v.libs {
+			if lib == name {
+				users = append(users, k)
+				break
+			}
+		}
+	}
+	sort.Strings(users)
+	return users
+}
+
+// chains more than this are counted only
+const WHY_MAX_CHAINS = 32
+
+// returns names of objects which can reach the target through dependencies
+func reachTarget(target string) map[string]bool {
+	users := make(map[string][]string)
+	var queue []string
+
+	for k, v := range deps {
+		for _, lib := range v.libs {
+			users[lib] = append(users[lib], k)
+		}
+		if matchDep(k, target) {
+			queue = append(queue, k)
+		}
+	}
+
+	reach := make(map[string]bool)
+	for _, k := range queue {
+		reach[k] = true
+	}
+	for len(queue) > 0 {
+		name := queue[0]
+		queue = queue[1:]
+
+		for _, u := range users[name] {
+			if !reach[u] {
+				reach[u] = true
+				queue = append(queue, u)
+			}
+		}
+	}
+	return reach
+}
+
+func walkChains(name, target string, chain []string, visited, reach map[string]bool, chains [][]string) [][]string {
+	chain = append(chain, name)
+
+	if matchDep(name, target) && len(chain) > 1 {
+		c := make([]string, len(chain))
+		copy(c, chain)
+		return append(chains, c)
+	}
+
+	// do not follow dependency cycles
+	visited[name] = true
+	for _, lib := range deps[name].libs {
+		if len(chains) >= WHY_MAX_CHAINS {
+			break
+		}
+		if visited[lib] || !reach[lib] {
+			continue
+		}
+		chains = walkChains(lib, target, chain, visited, reach, chains)
+	}
+	visited[name] = false
+
+	return chains
+}
+
+// number of chains from the object (it's not exact if there's a cycle)
+func countChains(name, target string, top bool, visited map[string]bool, count map[string]int) int {
+	if matchDep(name, target) && !top {
+		return 1
+	}
+	if n, ok := count[name]; ok {
+		return n
+	}
+
+	n := 0
+	visited[name] = true
+	for _, lib := range deps[name].libs {
+		if !visited[lib] {
+			n += countChains(lib, target, false, visited, count)
+		}
+	}
+	visited[name] = false
+
+	if !top {
+		count[name] = n
+	}
+	return n
+}
+
+// find dependency chains from the root to the target library (up to
+// WHY_MAX_CHAINS), and returns the total number of chains
+func findChains(root *DepsNode, target string) ([][]string, int) {
+	reach := reachTarget(target)
+	if !reach[root.name] {
+		return nil, 0
+	}
+
+	chains := walkChains(root.name, target, nil, make(map[string]bool), reach, nil)
+	total := countChains(root.name, target, true, make(map[string]bool), make(map[string]int))
+	if total < len(chains) {
+		total = len(chains)
+	}
+	return chains, total
+}
+
+func printWhy(root *DepsNode, target string) {
+	chains, total := findChains(root, target)
+	if len(chains) == 0 {
+		fmt.Printf("elftree: `%s` is not needed by %s\n", target, root.name)
+		return
+	}
+
+	for _, c := range chains {
+		fmt.Println(strings.Join(c, " > "))
+	}
+	if total > len(chains) {
+		fmt.Printf("... and %d more chains\n", total-len(chains))
+	}
+}
+
+// shown after objects which are expanded already in the reverse tree
+const SEEN_MARK = "(see above)"
+
+// build an inverted tree of objects which depend on the given node.
+// each object is expanded once, otherwise it'd be exponential.
+func makeReverseDeps(name string, parent *DepsNode, visited map[string]bool) *DepsNode {
+	dep := &DepsNode{name: name, parent: parent}
+	if parent != nil {
+		dep.depth = parent.depth + 1
+	}
+
+	visited[name] = true
+	for _, user := range findDependents(name) {
+		if visited[user] {
+			if !isAncestor(dep, user) {
+				seen := &DepsNode{name: user, parent: dep, depth: dep.depth + 1, seen: true}
+				dep.child = append(dep.child, seen)
+			}
+			continue
+		}
+		dep.child = append(dep.child, makeReverseDeps(user, dep, visited))
+	}
+
+	return dep
+}
+
+func isAncestor(dn *DepsNode, name string) bool {
+	for ; dn != nil; dn = dn.parent {
+		if dn.name == name {
+			return true
+		}
+	}
+	return false
+}
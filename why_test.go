@@ -0,0 +1,141 @@
+/*
+ * ELF tree - Tree viewer for ELF library dependency
+ *
+ * Copyright (C) 2017-2018  Namhyung Kim <namhyung@gmail.com>
+ *
+ * Released under MIT license.
+ */
+package main
+
+import (
+	"fmt"
+	"testing"
+)
+
+// chain of diamonds: each level has two libraries needing both of the next level
+func makeDiamonds(levels int) {
+	deps = make(map[string]DepsInfo)
+
+	lib := func(l, i int) string {
+		return fmt.Sprintf("lib%d-%d.so", l, i)
+	}
+
+	deps["root"] = DepsInfo{libs: []string{lib(0, 0), lib(0, 1)}}
+	for l := 0; l < levels; l++ {
+		next := []string{lib(l+1, 0), lib(l+1, 1)}
+		if l == levels-1 {
+			next = []string{"target.so"}
+		}
+		deps[lib(l, 0)] = DepsInfo{libs: next}
+		deps[lib(l, 1)] = DepsInfo{libs: next}
+	}
+	deps["target.so"] = DepsInfo{libs: []string{"unrelated.so"}}
+	deps["unrelated.so"] = DepsInfo{}
+}
+
+func TestFindChains(t *testing.T) {
+	makeDiamonds(2)
+
+	chains, total := findChains(&DepsNode{name: "root"}, "target.so")
+	if len(chains) != 4 || total != 4 {
+		t.Fatalf("got %d chains (total %d), want 4", len(chains), total)
+	}
+	for _, c := range chains {
+		if len(c) != 4 || c[0] != "root" || c[3] != "target.so" {
+			t.Errorf("bad chain: %v", c)
+		}
+	}
+
+	if chains, total := findChains(&DepsNode{name: "root"}, "nosuch.so"); len(chains) != 0 || total != 0 {
+		t.Errorf("got %d chains (total %d) for missing library", len(chains), total)
+	}
+}
+
+func TestFindChainsMany(t *testing.T) {
+	// 2^40 chains should not be enumerated
+	makeDiamonds(40)
+
+	chains, total := findChains(&DepsNode{name: "root"}, "target.so")
+	if len(chains) != WHY_MAX_CHAINS {
+		t.Errorf("got %d chains, want %d", len(chains), WHY_MAX_CHAINS)
+	}
+	if total != 1<<40 {
+		t.Errorf("got %d total chains, want %d", total, 1<<40)
+	}
+}
+
+func TestFindChainsCycle(t *testing.T) {
+	deps = map[string]DepsInfo{
+		"root":  {libs: []string{"a.so"}},
+		"a.so":  {libs: []string{"b.so"}},
+		"b.so":  {libs: []string{"a.so", "c.so"}},
+		"c.so":  {},
+		"x.so":  {libs: []string{"c.so"}},
+		"y.so":  {libs: []string{"root"}},
+		"z.so":  {},
+		"zz.so": {libs: []string{"z.so"}},
+	}
+
+	chains, total := findChains(&DepsNode{name: "root"}, "c.so")
+	if len(chains) != 1 || total != 1 {
+		t.Fatalf("got %v (total %d)", chains, total)
+	}
+	if len(chains[0]) != 4 || chains[0][3] != "c.so" {
+		t.Errorf("bad chain: %v", chains[0])
+	}
+}
+
+func TestReverseDeps(t *testing.T) {
+	// 2^40 paths from the target to the root
+	makeDiamonds(40)
+
+	expanded := make(map[string]bool)
+	nodes, seen := 0, 0
+
+	var walk func(dn *DepsNode)
+	walk = func(dn *DepsNode) {
+		nodes++
+		if dn.seen {
+			seen++
+			if len(dn.child) != 0 {
+				t.Errorf("%s is seen but expanded", dn.name)
+			}
+			return
+		}
+		if expanded[dn.name] {
+			t.Errorf("%s is expanded twice", dn.name)
+		}
+		expanded[dn.name] = true
+
+		for _, c := range dn.child {
+			walk(c)
+		}
+	}
+	walk(makeReverseDeps("target.so", nil, make(map[string]bool)))
+
+	// target, 80 libraries and the root
+	if len(expanded) != 82 {
+		t.Errorf("expanded %d objects, want 82", len(expanded))
+	}
+	if nodes != len(expanded)+seen || seen == 0 {
+		t.Errorf("got %d nodes with %d seen", nodes, seen)
+	}
+}
+
+func TestReverseDepsCycle(t *testing.T) {
+	deps = map[string]DepsInfo{
+		"root": {libs: []string{"a.so"}},
+		"a.so": {libs: []string{"b.so"}},
+		"b.so": {libs: []string{"a.so"}},
+	}
+
+	// b.so <- a.so <- root (and b.so itself, which is skipped)
+	rev := makeReverseDeps("b.so", nil, make(map[string]bool))
+	if len(rev.child) != 1 || rev.child[0].name != "a.so" {
+		t.Fatalf("got %v", rev.child)
+	}
+	a := rev.child[0]
+	if len(a.child) != 1 || a.child[0].name != "root" || a.child[0].seen {
+		t.Errorf("got %v", a.child)
+	}
+}